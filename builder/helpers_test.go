@@ -0,0 +1,144 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+	"gopkg.in/yaml.v2"
+)
+
+var testCurrency = &types.Currency{Symbol: ckb.CkbSymbol, Decimals: 8}
+
+// testConfigYAML holds the mainnet scripts the builders use, it is independent of the shipped config.yaml
+const testConfigYAML = `
+network: Mainnet
+secp256k1Blake160:
+  deps:
+    - txHash: 0xf8de3bb47d055cdf460d93a2a6e1b05f7432f9777c8c474abf4eec1d4aee5d37
+      index: 0
+      depType: dep_group
+  script:
+    codeHash: 0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8
+    hashType: type
+secp256k1Blake160Mutisig:
+  deps:
+    - txHash: 0xf8de3bb47d055cdf460d93a2a6e1b05f7432f9777c8c474abf4eec1d4aee5d37
+      index: 1
+      depType: dep_group
+  script:
+    codeHash: 0x5c5069eb0857efc65e1bca0c07df34c31663b3622fd3876c876320fc9634e2a8
+    hashType: type
+acp:
+  deps:
+    - txHash: 0xa05f28c9b867f8c5682039c10d8e864cf661685252aa74a008d255c33813bb81
+      index: 0
+      depType: dep_group
+  script:
+    codeHash: 0x0fb343953ee78c9986b091defb6252154e0bb51044fd2879fde5b27314506111
+    hashType: data
+dao:
+  deps:
+    - txHash: 0xe2fb199810d49a4d8beec56718ba2593b665db9d52299a0f9e6e75416d73ff5c
+      index: 2
+      depType: code
+  script:
+    codeHash: 0x82d76d1b75fe2fd9a27dfbaa65a039221a380d76c926f378d3f81cf3e7e13f2e
+    hashType: type
+udt:
+  deps:
+    - txHash: 0xc7813f6a415144643970c2e88e0bb6ca6a8edc5dd7c1022746f628284a9936d5
+      index: 0
+      depType: code
+  script:
+    codeHash: 0x5e7a36a77e68eecc013dfa2fe6a23f3b6c344b04005808694ae6dd45eea4cfd5
+    hashType: type
+`
+
+func testConfig(t *testing.T) *config.Config {
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(testConfigYAML), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return &cfg
+}
+
+func testPubKeyHash(i int) string {
+	return hexutil.Encode(bytes.Repeat([]byte{byte(i)}, 20))
+}
+
+func testAddress(t *testing.T, script *ckbTypes.Script) string {
+	addr, err := address.Generate(address.Mainnet, script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func testSighashAddress(t *testing.T, i int) string {
+	return testAddress(t, &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{byte(i)}, 20),
+	})
+}
+
+func testCoinIdentifier(i int) string {
+	return fmt.Sprintf("0x%064x:%d", i+1, i)
+}
+
+func testMultisigInput(t *testing.T, multisigScript *ckb.MultisigScript, i int) *types.Operation {
+	args, err := multisigScript.Args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := testAddress(t, &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(transaction.SECP256K1_BLAKE160_MULTISIG_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     args,
+	})
+	operation := testInput(addr, "-10000000000", i)
+	operation.Metadata = testMetadata(t, &ckb.OperationMetadata{MultisigScript: multisigScript})
+	return operation
+}
+
+func testInput(addr string, value string, i int) *types.Operation {
+	return &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+		Type:                ckb.InputOpType,
+		Account:             &types.AccountIdentifier{Address: addr},
+		Amount:              &types.Amount{Value: value, Currency: testCurrency},
+		CoinChange: &types.CoinChange{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: testCoinIdentifier(i)},
+			CoinAction:     types.CoinSpent,
+		},
+	}
+}
+
+func testOutput(addr string, value string) *types.Operation {
+	return &types.Operation{
+		Type:    ckb.OutputOpType,
+		Account: &types.AccountIdentifier{Address: addr},
+		Amount:  &types.Amount{Value: value, Currency: testCurrency},
+	}
+}
+
+func testMetadata(t *testing.T, metadata *ckb.OperationMetadata) map[string]interface{} {
+	m, err := types.MarshalMap(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testSince(since uint64) *hexutil.Uint64 {
+	s := hexutil.Uint64(since)
+	return &s
+}
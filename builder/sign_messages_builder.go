@@ -1,7 +1,11 @@
 package builder
 
 import (
+	"bytes"
+	"encoding/binary"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-sdk-go/crypto/blake2b"
 	"github.com/nervosnetwork/ckb-sdk-go/transaction"
 	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
 )
@@ -31,3 +35,99 @@ func (s SignMessagesBuilderSecp256k1Blake160) BuildSignMessages(tx *ckbTypes.Tra
 	}
 	return messages, nil
 }
+
+func NewSignMessagesBuilderSecp256k1Blake160Multisig() *SignMessagesBuilderSecp256k1Blake160Multisig {
+	return &SignMessagesBuilderSecp256k1Blake160Multisig{}
+}
+
+type SignMessagesBuilderSecp256k1Blake160Multisig struct{}
+
+func (s SignMessagesBuilderSecp256k1Blake160Multisig) BuildSignMessages(tx *ckbTypes.Transaction, inputOperations []*types.Operation) ([][]byte, error) {
+	indexGroups, err := BuildIndexGroups(inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	var messages [][]byte
+	for _, indexGroup := range indexGroups {
+		multisigScript, err := GetMultisigScript(inputOperations[indexGroup[0]])
+		if err != nil {
+			return nil, err
+		}
+		witnessArgs, err := BuildMultisigWitnessArgs(multisigScript)
+		if err != nil {
+			return nil, err
+		}
+		message, err := transaction.SingleSegmentSignMessage(tx, indexGroup[0], indexGroup[0]+len(indexGroup), witnessArgs)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func NewSignMessagesBuilderACP() *SignMessagesBuilderACP {
+	return &SignMessagesBuilderACP{}
+}
+
+// SignMessagesBuilderACP skips the anyone-can-pay lock groups which are unlocked without signature
+type SignMessagesBuilderACP struct{}
+
+func (s SignMessagesBuilderACP) BuildSignMessages(tx *ckbTypes.Transaction, inputOperations []*types.Operation) ([][]byte, error) {
+	indexGroups, err := BuildSigningIndexGroups(tx, inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	var messages [][]byte
+	for _, indexGroup := range indexGroups {
+		message, err := transaction.SingleSegmentSignMessage(tx, indexGroup[0], indexGroup[0]+len(indexGroup), transaction.EmptyWitnessArg)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func NewSignMessagesBuilderDAO() *SignMessagesBuilderDAO {
+	return &SignMessagesBuilderDAO{}
+}
+
+// SignMessagesBuilderDAO hashes every witness of the lock group since the witnesses of withdrawing cells carry
+// the deposit header index, the 85 bytes placeholder of a plain group is hashed as the empty witness args.
+type SignMessagesBuilderDAO struct{}
+
+func (s SignMessagesBuilderDAO) BuildSignMessages(tx *ckbTypes.Transaction, inputOperations []*types.Operation) ([][]byte, error) {
+	indexGroups, err := BuildIndexGroups(inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	txHash, err := tx.ComputeHash()
+	if err != nil {
+		return nil, err
+	}
+	emptyWitnessArg, err := transaction.EmptyWitnessArg.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	var messages [][]byte
+	for _, indexGroup := range indexGroups {
+		message := txHash.Bytes()
+		for i, index := range indexGroup {
+			witness := tx.Witnesses[index]
+			if i == 0 && bytes.Equal(witness, make([]byte, len(emptyWitnessArg))) {
+				witness = emptyWitnessArg
+			}
+			length := make([]byte, 8)
+			binary.LittleEndian.PutUint64(length, uint64(len(witness)))
+			message = append(message, length...)
+			message = append(message, witness...)
+		}
+		hash, err := blake2b.Blake256(message)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, hash)
+	}
+	return messages, nil
+}
@@ -2,8 +2,17 @@ package builder
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
 	ckbRpc "github.com/nervosnetwork/ckb-sdk-go/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
 	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
 )
 
@@ -23,12 +32,24 @@ func (c SignedTxCombinerSecp256k1Blake160) Combine(unsignedTxStr string, signatu
 		return "", err
 	}
 	emptyWitnessArg := make([]byte, 85)
+	emptySignature := make([]byte, ckb.SignatureSize)
 	sIndex := 0
 	for i, witness := range unsignedTx.Witnesses {
+		var witnessArgs *ckbTypes.WitnessArgs
 		if bytes.Compare(witness, emptyWitnessArg) == 0 {
-			witnessArgs := &ckbTypes.WitnessArgs{
-				Lock: signatures[sIndex].Bytes,
+			witnessArgs = &ckbTypes.WitnessArgs{}
+		} else if len(witness) > 0 {
+			// a witness args placeholder whose other fields are kept, e.g. the input_type of a withdrawing cell
+			parsedWitnessArgs, err := ParseWitnessArgs(witness)
+			if err == nil && bytes.Equal(parsedWitnessArgs.Lock, emptySignature) {
+				witnessArgs = parsedWitnessArgs
+			}
+		}
+		if witnessArgs != nil {
+			if sIndex >= len(signatures) {
+				return "", fmt.Errorf("missing signature for witness %d", i)
 			}
+			witnessArgs.Lock = signatures[sIndex].Bytes
 			serializedWitness, err := witnessArgs.Serialize()
 			if err != nil {
 				return "", err
@@ -43,3 +64,137 @@ func (c SignedTxCombinerSecp256k1Blake160) Combine(unsignedTxStr string, signatu
 	}
 	return signedTxStr, nil
 }
+
+func NewSignedTxCombinerSecp256k1Blake160Multisig() *SignedTxCombinerSecp256k1Blake160Multisig {
+	return &SignedTxCombinerSecp256k1Blake160Multisig{}
+}
+
+// SignedTxCombinerSecp256k1Blake160Multisig fills every multisig witness placeholder with the signatures of its signing message.
+// The signing message of each lock group is recomputed from the unsigned transaction and matched to the signing payloads, so
+// the order of the signatures does not matter.
+type SignedTxCombinerSecp256k1Blake160Multisig struct{}
+
+func (c SignedTxCombinerSecp256k1Blake160Multisig) Combine(unsignedTxStr string, signatures []*types.Signature) (string, error) {
+	unsignedTx, err := ckbRpc.TransactionFromString(unsignedTxStr)
+	if err != nil {
+		return "", err
+	}
+	signatureGroups := make(map[string][]*types.Signature)
+	for _, signature := range signatures {
+		message := hex.EncodeToString(signature.SigningPayload.Bytes)
+		signatureGroups[message] = append(signatureGroups[message], signature)
+	}
+
+	emptyWitnesses := 0
+	for _, witness := range unsignedTx.Witnesses {
+		if len(witness) == 0 {
+			emptyWitnesses++
+		}
+	}
+	for i, witness := range unsignedTx.Witnesses {
+		if len(witness) == 0 {
+			emptyWitnesses--
+			continue
+		}
+		witnessArgs, err := ParseWitnessArgs(witness)
+		if err != nil {
+			return "", fmt.Errorf("invalid multisig witness placeholder %d: %v", i, err)
+		}
+		// the signing message only depends on the placeholder and the size of the lock group, whose other
+		// witnesses are the empty ones following the placeholder
+		var groupSignatures []*types.Signature
+		for groupSize := 1; groupSize <= emptyWitnesses+1 && groupSignatures == nil; groupSize++ {
+			message, err := transaction.SingleSegmentSignMessage(unsignedTx, i, i+groupSize, witnessArgs)
+			if err != nil {
+				return "", err
+			}
+			groupSignatures = signatureGroups[hex.EncodeToString(message)]
+		}
+		if groupSignatures == nil {
+			return "", fmt.Errorf("missing signatures for witness %d", i)
+		}
+		lock, err := multisigLock(witness, groupSignatures)
+		if err != nil {
+			return "", err
+		}
+		witnessArgs = &ckbTypes.WitnessArgs{
+			Lock: lock,
+		}
+		serializedWitness, err := witnessArgs.Serialize()
+		if err != nil {
+			return "", err
+		}
+		unsignedTx.Witnesses[i] = serializedWitness
+	}
+	signedTxStr, err := ckbRpc.TransactionString(unsignedTx)
+	if err != nil {
+		return "", err
+	}
+	return signedTxStr, nil
+}
+
+// multisigLock reads the multisig script from the witness placeholder and appends threshold signatures ordered by cosigner index
+func multisigLock(placeholder []byte, signatures []*types.Signature) ([]byte, error) {
+	if len(placeholder) < 20 {
+		return nil, errors.New("invalid multisig witness placeholder")
+	}
+	lockOffset := binary.LittleEndian.Uint32(placeholder[4:8])
+	if uint32(len(placeholder)) < lockOffset+4 {
+		return nil, errors.New("invalid multisig witness placeholder")
+	}
+	lockSize := binary.LittleEndian.Uint32(placeholder[lockOffset : lockOffset+4])
+	lock := placeholder[lockOffset+4:]
+	if uint32(len(lock)) < lockSize || lockSize < 4 {
+		return nil, errors.New("invalid multisig witness placeholder")
+	}
+	threshold := int(lock[2])
+	pubKeyHashesSize := int(lock[3])
+	scriptSize := 4 + 20*pubKeyHashesSize
+	if len(lock) < scriptSize {
+		return nil, errors.New("invalid multisig witness placeholder")
+	}
+	serializedScript := lock[:scriptSize]
+
+	cosignerIndexes := make(map[string]int)
+	for i := 0; i < pubKeyHashesSize; i++ {
+		cosignerIndexes[hex.EncodeToString(serializedScript[4+20*i:4+20*(i+1)])] = i
+	}
+	signaturesByCosigner := make(map[int]*types.Signature)
+	var indexes []int
+	for _, signature := range signatures {
+		accountIdentifier := signature.SigningPayload.AccountIdentifier
+		if accountIdentifier == nil || accountIdentifier.SubAccount == nil {
+			return nil, errors.New("multisig signature without cosigner sub account")
+		}
+		index, ok := cosignerIndexes[strings.ToLower(strings.TrimPrefix(accountIdentifier.SubAccount.Address, "0x"))]
+		if !ok {
+			return nil, fmt.Errorf("%s is not a cosigner of the multisig script", accountIdentifier.SubAccount.Address)
+		}
+		if _, ok := signaturesByCosigner[index]; ok {
+			return nil, fmt.Errorf("duplicate signatures of cosigner %s", accountIdentifier.SubAccount.Address)
+		}
+		signaturesByCosigner[index] = signature
+		indexes = append(indexes, index)
+	}
+	if len(indexes) < threshold {
+		return nil, fmt.Errorf("require %d signatures but got %d", threshold, len(indexes))
+	}
+	sort.Ints(indexes)
+	requireFirstN := int(lock[1])
+	for i := 0; i < requireFirstN; i++ {
+		if indexes[i] != i {
+			return nil, fmt.Errorf("require the signatures of the first %d cosigners", requireFirstN)
+		}
+	}
+
+	result := make([]byte, 0, scriptSize+threshold*ckb.SignatureSize)
+	result = append(result, serializedScript...)
+	for _, index := range indexes[:threshold] {
+		signature := signaturesByCosigner[index]
+		if len(signature.Bytes) != ckb.SignatureSize {
+			return nil, fmt.Errorf("invalid signature size %d", len(signature.Bytes))
+		}
+		result = append(result, signature.Bytes...)
+	}
+	return result, nil
+}
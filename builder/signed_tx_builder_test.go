@@ -0,0 +1,128 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	ckbRpc "github.com/nervosnetwork/ckb-sdk-go/rpc"
+)
+
+func TestSignedTxCombinerSecp256k1Blake160MultisigCombine(t *testing.T) {
+	cfg := testConfig(t)
+	scriptA := &ckb.MultisigScript{Threshold: 2, PubKeyHashes: []string{testPubKeyHash(1), testPubKeyHash(2), testPubKeyHash(3)}}
+	scriptB := &ckb.MultisigScript{RequireFirstN: 1, Threshold: 1, PubKeyHashes: []string{testPubKeyHash(4), testPubKeyHash(5)}}
+	// the lock groups are interleaved, A owns the inputs 0 and 2
+	inputOperations := []*types.Operation{
+		testMultisigInput(t, scriptA, 0),
+		testMultisigInput(t, scriptB, 1),
+		testMultisigInput(t, scriptA, 2),
+	}
+	outputOperations := []*types.Operation{testOutput(testSighashAddress(t, 6), "10000000000")}
+	tx, err := NewUnsignedTxBuilderSecp256k1Multisig(cfg, inputOperations, outputOperations).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsignedTxStr, err := ckbRpc.TransactionString(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := NewSigningPayloadBuilderSecp256k1Blake160Multisig(ckb.TransferCKBFromMultisig, NewSignMessagesBuilderSecp256k1Blake160Multisig()).BuildSigningPayload(inputOperations, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// payloads are A1, A2, A3, B4, B5
+	sign := func(i int) *types.Signature {
+		return &types.Signature{
+			SigningPayload: payloads[i],
+			SignatureType:  types.EcdsaRecovery,
+			Bytes:          bytes.Repeat([]byte{byte(i + 1)}, ckb.SignatureSize),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		signatures []*types.Signature
+		locks      map[int][]int // witness index to the payloads whose signatures follow the script
+		wantErr    bool
+	}{
+		{
+			name:       "payload order",
+			signatures: []*types.Signature{sign(0), sign(1), sign(3)},
+			locks:      map[int][]int{0: {0, 1}, 1: {3}},
+		},
+		{
+			name:       "reversed order",
+			signatures: []*types.Signature{sign(3), sign(2), sign(0)},
+			locks:      map[int][]int{0: {0, 2}, 1: {3}},
+		},
+		{
+			name:       "more signatures than threshold",
+			signatures: []*types.Signature{sign(2), sign(3), sign(1), sign(0)},
+			locks:      map[int][]int{0: {0, 1}, 1: {3}},
+		},
+		{
+			name:       "duplicate cosigner",
+			signatures: []*types.Signature{sign(0), sign(0), sign(3)},
+			wantErr:    true,
+		},
+		{
+			name:       "below threshold",
+			signatures: []*types.Signature{sign(1), sign(3)},
+			wantErr:    true,
+		},
+		{
+			name:       "missing lock group",
+			signatures: []*types.Signature{sign(0), sign(1)},
+			wantErr:    true,
+		},
+		{
+			name:       "require first cosigner",
+			signatures: []*types.Signature{sign(0), sign(1), sign(4)},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedTxStr, err := SignedTxCombinerSecp256k1Blake160Multisig{}.Combine(unsignedTxStr, tt.signatures)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			signedTx, err := ckbRpc.TransactionFromString(signedTxStr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(signedTx.Witnesses[2]) != 0 {
+				t.Fatalf("witness 2 = %x, want empty", signedTx.Witnesses[2])
+			}
+			for index, signers := range tt.locks {
+				script := scriptA
+				if index == 1 {
+					script = scriptB
+				}
+				want, err := script.Serialize()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, signer := range signers {
+					want = append(want, sign(signer).Bytes...)
+				}
+				witnessArgs, err := ParseWitnessArgs(signedTx.Witnesses[index])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(witnessArgs.Lock, want) {
+					t.Errorf("witness %d lock = %s, want %s", index, hex.EncodeToString(witnessArgs.Lock), hex.EncodeToString(want))
+				}
+			}
+		})
+	}
+}
@@ -45,3 +45,99 @@ func (b SigningPayloadBuilderSecp256k1Blake160) BuildSigningPayload(inputOperati
 
 	return payloads, nil
 }
+
+func NewSigningPayloadBuilderSecp256k1Blake160Multisig(constructionType string, signMessagesBuilder SignMessagesBuilder) *SigningPayloadBuilderSecp256k1Blake160Multisig {
+	return &SigningPayloadBuilderSecp256k1Blake160Multisig{constructionType, signMessagesBuilder}
+}
+
+// SigningPayloadBuilderSecp256k1Blake160Multisig builds a payload for every cosigner of each multisig lock group,
+// the cosigner is identified by the sub account whose address is its pubkey hash.
+type SigningPayloadBuilderSecp256k1Blake160Multisig struct {
+	ConstructionType    string
+	signMessagesBuilder SignMessagesBuilder
+}
+
+func (b SigningPayloadBuilderSecp256k1Blake160Multisig) BuildSigningPayload(inputOperations []*types.Operation, unsignedTx *ckbTypes.Transaction) ([]*types.SigningPayload, error) {
+	payloads := make([]*types.SigningPayload, 0)
+	indexGroups, err := BuildIndexGroups(inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := b.signMessagesBuilder.BuildSignMessages(unsignedTx, inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
+		LockType: ckb.Secp256k1Blake160Multisig.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, message := range messages {
+		index := indexGroups[i][0]
+		operation := inputOperations[index]
+		multisigScript, err := GetMultisigScript(operation)
+		if err != nil {
+			return nil, err
+		}
+		for _, pubKeyHash := range multisigScript.PubKeyHashes {
+			payloads = append(payloads, &types.SigningPayload{
+				AccountIdentifier: &types.AccountIdentifier{
+					Address: operation.Account.Address,
+					SubAccount: &types.SubAccountIdentifier{
+						Address: pubKeyHash,
+					},
+					Metadata: metadata,
+				},
+				Bytes:         message,
+				SignatureType: types.EcdsaRecovery,
+			})
+		}
+	}
+
+	return payloads, nil
+}
+
+func NewSigningPayloadBuilderACP(constructionType string, signMessagesBuilder SignMessagesBuilder) *SigningPayloadBuilderACP {
+	return &SigningPayloadBuilderACP{constructionType, signMessagesBuilder}
+}
+
+// SigningPayloadBuilderACP builds payloads for the secp256k1_blake160_sighash_all lock groups paying the top up
+type SigningPayloadBuilderACP struct {
+	ConstructionType    string
+	signMessagesBuilder SignMessagesBuilder
+}
+
+func (b SigningPayloadBuilderACP) BuildSigningPayload(inputOperations []*types.Operation, unsignedTx *ckbTypes.Transaction) ([]*types.SigningPayload, error) {
+	payloads := make([]*types.SigningPayload, 0)
+	indexGroups, err := BuildSigningIndexGroups(unsignedTx, inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := b.signMessagesBuilder.BuildSignMessages(unsignedTx, inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
+		LockType: ckb.Secp256k1Blake160Lock.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, message := range messages {
+		index := indexGroups[i][0]
+		operation := inputOperations[index]
+		payloads = append(payloads, &types.SigningPayload{
+			AccountIdentifier: &types.AccountIdentifier{
+				Address:  operation.Account.Address,
+				Metadata: metadata,
+			},
+			Bytes:         message,
+			SignatureType: types.EcdsaRecovery,
+		})
+	}
+
+	return payloads, nil
+}
@@ -0,0 +1,72 @@
+package builder
+
+import (
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+var _ UnsignedTxBuilder = UnsignedTxBuilderACP{}
+
+// UnsignedTxBuilderACP builds top ups of anyone-can-pay cells paid by secp256k1_blake160_sighash_all cells,
+// anyone-can-pay inputs are unlocked by recreating them with more capacity so their witnesses are left empty.
+type UnsignedTxBuilderACP struct {
+	UnsignedTxBuilderSecp256k1
+}
+
+func NewUnsignedTxBuilderACP(cfg *config.Config, inputOperations []*types.Operation, outputOperations []*types.Operation) *UnsignedTxBuilderACP {
+	b := UnsignedTxBuilderACP{
+		UnsignedTxBuilderSecp256k1{
+			Cfg:              cfg,
+			InputOperations:  inputOperations,
+			OutputOperations: outputOperations,
+		},
+	}
+	b.UnsignedTx.BuildVersion = b.BuildVersion
+	b.UnsignedTx.BuildCellDeps = b.BuildCellDeps
+	b.UnsignedTx.BuildHeaderDeps = b.BuildHeaderDeps
+	b.UnsignedTx.BuildInputs = b.BuildInputs
+	b.UnsignedTx.BuildOutputs = b.BuildOutputs
+	b.UnsignedTx.BuildOutputsData = b.BuildOutputsData
+	b.UnsignedTx.BuildWitnesses = b.BuildWitnesses
+	return &b
+}
+
+func (b UnsignedTxBuilderACP) BuildCellDeps() ([]*ckbTypes.CellDep, error) {
+	cellDeps, err := b.UnsignedTxBuilderSecp256k1.BuildCellDeps()
+	if err != nil {
+		return nil, err
+	}
+	cellDeps = append(cellDeps, &ckbTypes.CellDep{
+		OutPoint: &ckbTypes.OutPoint{
+			TxHash: ckbTypes.HexToHash(b.Cfg.ACP.Deps[0].TxHash),
+			Index:  b.Cfg.ACP.Deps[0].Index,
+		},
+		DepType: ckbTypes.DepType(b.Cfg.ACP.Deps[0].DepType),
+	})
+
+	return cellDeps, nil
+}
+
+func (b UnsignedTxBuilderACP) BuildWitnesses() ([][]byte, error) {
+	cellInputsSize := len(b.InputOperations)
+	witnesses := make([][]byte, cellInputsSize)
+	indexGroups, err := BuildIndexGroups(b.InputOperations)
+	if err != nil {
+		return nil, err
+	}
+	for _, indexes := range indexGroups {
+		firstIndexOfGroup := indexes[0]
+		parsedAddress, err := address.Parse(b.InputOperations[firstIndexOfGroup].Account.Address)
+		if err != nil {
+			return nil, err
+		}
+		if IsAnyoneCanPayLock(parsedAddress.Script, b.Cfg) {
+			continue
+		}
+		witnesses[firstIndexOfGroup] = make([]byte, 85) // empty witnessArg placeholder
+	}
+
+	return witnesses, nil
+}
@@ -0,0 +1,217 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+var _ UnsignedTxBuilder = UnsignedTxBuilderDAO{}
+
+// UnsignedTxBuilderDAO builds Nervos DAO deposits, phase 1 withdrawals and unlocks from secp256k1_blake160_sighash_all
+// cells. The header deps and the DAO inputs are resolved by /construction/metadata.
+type UnsignedTxBuilderDAO struct {
+	UnsignedTxBuilderSecp256k1
+	Metadata *ckb.ConstructionMetadata
+}
+
+func NewUnsignedTxBuilderDAO(cfg *config.Config, inputOperations []*types.Operation, outputOperations []*types.Operation, metadata *ckb.ConstructionMetadata) *UnsignedTxBuilderDAO {
+	b := UnsignedTxBuilderDAO{
+		UnsignedTxBuilderSecp256k1: UnsignedTxBuilderSecp256k1{
+			Cfg:              cfg,
+			InputOperations:  inputOperations,
+			OutputOperations: outputOperations,
+		},
+		Metadata: metadata,
+	}
+	b.UnsignedTx.BuildVersion = b.BuildVersion
+	b.UnsignedTx.BuildCellDeps = b.BuildCellDeps
+	b.UnsignedTx.BuildHeaderDeps = b.BuildHeaderDeps
+	b.UnsignedTx.BuildInputs = b.BuildInputs
+	b.UnsignedTx.BuildOutputs = b.BuildOutputs
+	b.UnsignedTx.BuildOutputsData = b.BuildOutputsData
+	b.UnsignedTx.BuildWitnesses = b.BuildWitnesses
+	return &b
+}
+
+func (b UnsignedTxBuilderDAO) BuildCellDeps() ([]*ckbTypes.CellDep, error) {
+	cellDeps, err := b.UnsignedTxBuilderSecp256k1.BuildCellDeps()
+	if err != nil {
+		return nil, err
+	}
+	cellDeps = append(cellDeps, &ckbTypes.CellDep{
+		OutPoint: &ckbTypes.OutPoint{
+			TxHash: ckbTypes.HexToHash(b.Cfg.DAO.Deps[0].TxHash),
+			Index:  b.Cfg.DAO.Deps[0].Index,
+		},
+		DepType: ckbTypes.DepType(b.Cfg.DAO.Deps[0].DepType),
+	})
+
+	return cellDeps, nil
+}
+
+func (b UnsignedTxBuilderDAO) BuildHeaderDeps() ([]ckbTypes.Hash, error) {
+	headerDeps := make([]ckbTypes.Hash, 0, len(b.Metadata.HeaderDeps))
+	for _, headerDep := range b.Metadata.HeaderDeps {
+		headerDeps = append(headerDeps, ckbTypes.HexToHash(headerDep))
+	}
+	return headerDeps, nil
+}
+
+// BuildInputs sets the since of withdrawing cells to the epoch their deposit is unlocked, a since declared on the
+// operation must match it
+func (b UnsignedTxBuilderDAO) BuildInputs() ([]*ckbTypes.CellInput, map[string]interface{}, error) {
+	cellInputs, options, err := b.UnsignedTxBuilderSecp256k1.BuildInputs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, operation := range b.InputOperations {
+		daoInput, err := b.daoInput(operation)
+		if err != nil {
+			return nil, nil, err
+		}
+		if daoInput == nil || daoInput.Since == 0 {
+			continue
+		}
+		if cellInputs[i].Since != 0 && cellInputs[i].Since != uint64(daoInput.Since) {
+			return nil, nil, fmt.Errorf("since %#x of input %d does not match the DAO unlock since %s", cellInputs[i].Since, i, daoInput.Since.String())
+		}
+		cellInputs[i].Since = uint64(daoInput.Since)
+	}
+	return cellInputs, options, nil
+}
+
+func (b UnsignedTxBuilderDAO) BuildOutputs(options map[string]interface{}) ([]*ckbTypes.CellOutput, map[string]interface{}, error) {
+	var cellOutputs []*ckbTypes.CellOutput
+	for i, operation := range b.OutputOperations {
+		parsedAddress, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return nil, nil, err
+		}
+		capacity, err := strconv.ParseUint(operation.Amount.Value, 10, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		cellType, err := GetOperationCellType(operation)
+		if err != nil {
+			return nil, nil, err
+		}
+		cellOutput := &ckbTypes.CellOutput{
+			Capacity: capacity,
+			Lock:     parsedAddress.Script,
+		}
+		switch cellType {
+		case ckb.DAODepositCell.String():
+			cellOutput.Type = GetDAOTypeScript(b.Cfg)
+		case ckb.DAOWithdrawingCell.String():
+			// the DAO script pairs the withdrawing cell with the deposit cell at the same index
+			daoInput, err := b.depositInput(i)
+			if err != nil {
+				return nil, nil, err
+			}
+			if daoInput.Capacity != operation.Amount.Value {
+				return nil, nil, fmt.Errorf("withdrawing output %d capacity %s does not match the deposit capacity %s", i, operation.Amount.Value, daoInput.Capacity)
+			}
+			cellOutput.Type = GetDAOTypeScript(b.Cfg)
+		}
+		cellOutputs = append(cellOutputs, cellOutput)
+	}
+	return cellOutputs, nil, nil
+}
+
+// BuildOutputsData writes zero to deposit cells and the deposit block number to withdrawing cells
+func (b UnsignedTxBuilderDAO) BuildOutputsData(options map[string]interface{}) ([][]byte, error) {
+	var outputsData [][]byte
+	for i, operation := range b.OutputOperations {
+		cellType, err := GetOperationCellType(operation)
+		if err != nil {
+			return nil, err
+		}
+		switch cellType {
+		case ckb.DAODepositCell.String():
+			outputsData = append(outputsData, make([]byte, ckb.DAODataSize))
+		case ckb.DAOWithdrawingCell.String():
+			daoInput, err := b.depositInput(i)
+			if err != nil {
+				return nil, err
+			}
+			outputsData = append(outputsData, ckbTypes.SerializeUint64(daoInput.DepositBlockNumber))
+		default:
+			outputsData = append(outputsData, []byte{})
+		}
+	}
+	return outputsData, nil
+}
+
+// BuildWitnesses puts the index of the deposit header dep in the input_type of the withdrawing cell witnesses,
+// the lock of the first witness of each group is a 65 bytes signature placeholder.
+func (b UnsignedTxBuilderDAO) BuildWitnesses() ([][]byte, error) {
+	witnesses, err := b.UnsignedTxBuilderSecp256k1.BuildWitnesses()
+	if err != nil {
+		return nil, err
+	}
+	indexGroups, err := BuildIndexGroups(b.InputOperations)
+	if err != nil {
+		return nil, err
+	}
+	for _, indexes := range indexGroups {
+		for j, index := range indexes {
+			daoInput, err := b.daoInput(b.InputOperations[index])
+			if err != nil {
+				return nil, err
+			}
+			if daoInput == nil || daoInput.Since == 0 {
+				continue
+			}
+			witnessArgs := &ckbTypes.WitnessArgs{
+				InputType: ckbTypes.SerializeUint64(daoInput.DepositHeaderIndex),
+			}
+			if j == 0 {
+				witnessArgs.Lock = make([]byte, ckb.SignatureSize)
+			}
+			witness, err := witnessArgs.Serialize()
+			if err != nil {
+				return nil, err
+			}
+			witnesses[index] = witness
+		}
+	}
+	return witnesses, nil
+}
+
+// daoInput returns the DAO input resolved for the operation, nil when the operation spends no DAO cell
+func (b UnsignedTxBuilderDAO) daoInput(operation *types.Operation) (*ckb.DAOInput, error) {
+	cellType, err := GetOperationCellType(operation)
+	if err != nil {
+		return nil, err
+	}
+	if cellType != ckb.DAODepositCell.String() && cellType != ckb.DAOWithdrawingCell.String() {
+		return nil, nil
+	}
+	daoInput := b.Metadata.DAOInput(operation.CoinChange.CoinIdentifier.Identifier)
+	if daoInput == nil {
+		return nil, fmt.Errorf("missing DAO input %s in construction metadata", operation.CoinChange.CoinIdentifier.Identifier)
+	}
+	return daoInput, nil
+}
+
+// depositInput returns the deposit cell spent by the input at index
+func (b UnsignedTxBuilderDAO) depositInput(index int) (*ckb.DAOInput, error) {
+	if index >= len(b.InputOperations) {
+		return nil, fmt.Errorf("withdrawing output %d has no deposit input", index)
+	}
+	operation := b.InputOperations[index]
+	cellType, err := GetOperationCellType(operation)
+	if err != nil {
+		return nil, err
+	}
+	if cellType != ckb.DAODepositCell.String() {
+		return nil, fmt.Errorf("withdrawing output %d has no deposit input", index)
+	}
+	return b.daoInput(operation)
+}
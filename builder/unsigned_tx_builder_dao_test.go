@@ -0,0 +1,60 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func TestUnsignedTxBuilderDAOBuildInputs(t *testing.T) {
+	cfg := testConfig(t)
+	unlockSince := (&ckbTypes.EpochParams{Length: 1000, Index: 100, Number: 185}).Uint64()
+	daoInput := func(cellType ckb.CellType, since *hexutil.Uint64) *types.Operation {
+		operation := testInput(testSighashAddress(t, 1), "-10000000000", 0)
+		operation.Metadata = testMetadata(t, &ckb.OperationMetadata{CellType: cellType.String(), Since: since})
+		return operation
+	}
+	metadata := func(since uint64) *ckb.ConstructionMetadata {
+		return &ckb.ConstructionMetadata{
+			HeaderDeps: []string{ckbTypes.Hash{}.String(), ckbTypes.Hash{}.String()},
+			DAOInputs: []*ckb.DAOInput{{
+				CoinIdentifier: testCoinIdentifier(0),
+				Capacity:       "10000000000",
+				Since:          hexutil.Uint64(since),
+			}},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		operation *types.Operation
+		metadata  *ckb.ConstructionMetadata
+		want      uint64
+		wantErr   bool
+	}{
+		{name: "unlock since", operation: daoInput(ckb.DAOWithdrawingCell, nil), metadata: metadata(unlockSince), want: unlockSince},
+		{name: "matching since", operation: daoInput(ckb.DAOWithdrawingCell, testSince(unlockSince)), metadata: metadata(unlockSince), want: unlockSince},
+		{name: "conflicting since", operation: daoInput(ckb.DAOWithdrawingCell, testSince(unlockSince+1)), metadata: metadata(unlockSince), wantErr: true},
+		{name: "deposit without since", operation: daoInput(ckb.DAODepositCell, nil), metadata: metadata(0), want: 0},
+		{name: "deposit with since", operation: daoInput(ckb.DAODepositCell, testSince(1000)), metadata: metadata(0), want: 1000},
+		{name: "missing DAO input", operation: daoInput(ckb.DAOWithdrawingCell, nil), metadata: &ckb.ConstructionMetadata{}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewUnsignedTxBuilderDAO(cfg, []*types.Operation{tt.operation}, nil, tt.metadata)
+			cellInputs, _, err := b.BuildInputs()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildInputs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if cellInputs[0].Since != tt.want {
+				t.Errorf("BuildInputs() since = %#x, want %#x", cellInputs[0].Since, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+var _ UnsignedTxBuilder = UnsignedTxBuilderGenericCell{}
+
+// UnsignedTxBuilderGenericCell creates the type script and data declared in the OUTPUT operation metadata from
+// secp256k1_blake160_sighash_all cells, the cell deps of the type scripts come from the construction metadata.
+type UnsignedTxBuilderGenericCell struct {
+	UnsignedTxBuilderSecp256k1
+	Metadata *ckb.ConstructionMetadata
+}
+
+func NewUnsignedTxBuilderGenericCell(cfg *config.Config, inputOperations []*types.Operation, outputOperations []*types.Operation, metadata *ckb.ConstructionMetadata) *UnsignedTxBuilderGenericCell {
+	b := UnsignedTxBuilderGenericCell{
+		UnsignedTxBuilderSecp256k1: UnsignedTxBuilderSecp256k1{
+			Cfg:              cfg,
+			InputOperations:  inputOperations,
+			OutputOperations: outputOperations,
+		},
+		Metadata: metadata,
+	}
+	b.UnsignedTx.BuildVersion = b.BuildVersion
+	b.UnsignedTx.BuildCellDeps = b.BuildCellDeps
+	b.UnsignedTx.BuildHeaderDeps = b.BuildHeaderDeps
+	b.UnsignedTx.BuildInputs = b.BuildInputs
+	b.UnsignedTx.BuildOutputs = b.BuildOutputs
+	b.UnsignedTx.BuildOutputsData = b.BuildOutputsData
+	b.UnsignedTx.BuildWitnesses = b.BuildWitnesses
+	return &b
+}
+
+func (b UnsignedTxBuilderGenericCell) BuildCellDeps() ([]*ckbTypes.CellDep, error) {
+	cellDeps, err := b.UnsignedTxBuilderSecp256k1.BuildCellDeps()
+	if err != nil {
+		return nil, err
+	}
+	for _, cellDep := range b.Metadata.CellDeps {
+		dep, err := GenerateCellDep(cellDep)
+		if err != nil {
+			return nil, err
+		}
+		cellDeps = append(cellDeps, dep)
+	}
+
+	return cellDeps, nil
+}
+
+func (b UnsignedTxBuilderGenericCell) BuildOutputs(options map[string]interface{}) ([]*ckbTypes.CellOutput, map[string]interface{}, error) {
+	var cellOutputs []*ckbTypes.CellOutput
+	for _, operation := range b.OutputOperations {
+		parsedAddress, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return nil, nil, err
+		}
+		capacity, err := strconv.ParseUint(operation.Amount.Value, 10, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		typeScript, _, err := GetOperationCellOutput(operation)
+		if err != nil {
+			return nil, nil, err
+		}
+		cellOutputs = append(cellOutputs, &ckbTypes.CellOutput{
+			Capacity: capacity,
+			Lock:     parsedAddress.Script,
+			Type:     typeScript,
+		})
+	}
+	return cellOutputs, nil, nil
+}
+
+func (b UnsignedTxBuilderGenericCell) BuildOutputsData(options map[string]interface{}) ([][]byte, error) {
+	var outputsData [][]byte
+	for _, operation := range b.OutputOperations {
+		_, data, err := GetOperationCellOutput(operation)
+		if err != nil {
+			return nil, err
+		}
+		outputsData = append(outputsData, data)
+	}
+	return outputsData, nil
+}
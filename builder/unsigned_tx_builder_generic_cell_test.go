@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func TestUnsignedTxBuilderGenericCellBuild(t *testing.T) {
+	cfg := testConfig(t)
+	typeScript := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash("0x01"),
+		HashType: ckbTypes.HashTypeData,
+		Args:     bytes.Repeat([]byte{2}, 20),
+	}
+	typeOutput := testOutput(testSighashAddress(t, 2), "20000000000")
+	typeOutput.Metadata = testMetadata(t, &ckb.OperationMetadata{Type: typeScript, Data: "0x0102"})
+	dataOutput := testOutput(testSighashAddress(t, 2), "10000000000")
+	dataOutput.Metadata = testMetadata(t, &ckb.OperationMetadata{Data: "0x03"})
+	changeOutput := testOutput(testSighashAddress(t, 1), "9999999000")
+	typeDep := fmt.Sprintf("0x%064x:%d", 10, 0)
+	groupDep := fmt.Sprintf("0x%064x:%d", 11, 1)
+
+	tests := []struct {
+		name            string
+		outputs         []*types.Operation
+		cellDeps        []*ckb.CellDep
+		wantTypes       []*ckbTypes.Script
+		wantOutputsData [][]byte
+		wantCellDeps    []string
+		wantErr         bool
+	}{
+		{
+			name:            "type script and data",
+			outputs:         []*types.Operation{typeOutput, dataOutput, changeOutput},
+			cellDeps:        []*ckb.CellDep{{OutPoint: typeDep, DepType: string(ckbTypes.DepTypeCode)}, {OutPoint: groupDep, DepType: string(ckbTypes.DepTypeDepGroup)}},
+			wantTypes:       []*ckbTypes.Script{typeScript, nil, nil},
+			wantOutputsData: [][]byte{{1, 2}, {3}, {}},
+			wantCellDeps:    []string{typeDep, groupDep},
+		},
+		{
+			name:            "no extra cell deps",
+			outputs:         []*types.Operation{dataOutput, changeOutput},
+			wantTypes:       []*ckbTypes.Script{nil, nil},
+			wantOutputsData: [][]byte{{3}, {}},
+		},
+		{
+			name:     "invalid dep type",
+			outputs:  []*types.Operation{typeOutput},
+			cellDeps: []*ckb.CellDep{{OutPoint: typeDep, DepType: "dep"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs := []*types.Operation{testInput(testSighashAddress(t, 1), "-40000000000", 0)}
+			b := NewUnsignedTxBuilderGenericCell(cfg, inputs, tt.outputs, &ckb.ConstructionMetadata{CellDeps: tt.cellDeps})
+			tx, err := b.Build()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(tx.Outputs) != len(tt.wantTypes) || len(tx.OutputsData) != len(tt.wantOutputsData) {
+				t.Fatalf("outputs = %d data = %d, want %d", len(tx.Outputs), len(tx.OutputsData), len(tt.wantTypes))
+			}
+			for i, output := range tx.Outputs {
+				if strconv.FormatUint(output.Capacity, 10) != tt.outputs[i].Amount.Value {
+					t.Errorf("output %d capacity = %d, want %s", i, output.Capacity, tt.outputs[i].Amount.Value)
+				}
+				if (output.Type == nil) != (tt.wantTypes[i] == nil) || (output.Type != nil && !output.Type.Equals(tt.wantTypes[i])) {
+					t.Errorf("output %d type = %+v, want %+v", i, output.Type, tt.wantTypes[i])
+				}
+				if !bytes.Equal(tx.OutputsData[i], tt.wantOutputsData[i]) {
+					t.Errorf("output %d data = %x, want %x", i, tx.OutputsData[i], tt.wantOutputsData[i])
+				}
+			}
+
+			// the secp256k1_blake160 dep group comes first, then the cell deps of the construction metadata
+			if len(tx.CellDeps) != 1+len(tt.wantCellDeps) {
+				t.Fatalf("cell deps = %d, want %d", len(tx.CellDeps), 1+len(tt.wantCellDeps))
+			}
+			secpDep := cfg.Secp256k1Blake160.Deps[0]
+			if tx.CellDeps[0].OutPoint.TxHash != ckbTypes.HexToHash(secpDep.TxHash) || tx.CellDeps[0].OutPoint.Index != secpDep.Index {
+				t.Errorf("cell dep 0 = %+v, want the secp256k1_blake160 dep group", tx.CellDeps[0].OutPoint)
+			}
+			for i, want := range tt.wantCellDeps {
+				cellDep := tx.CellDeps[i+1]
+				if got := fmt.Sprintf("%s:%d", cellDep.OutPoint.TxHash.String(), cellDep.OutPoint.Index); got != want {
+					t.Errorf("cell dep %d = %s, want %s", i+1, got, want)
+				}
+				if string(cellDep.DepType) != tt.cellDeps[i].DepType {
+					t.Errorf("cell dep %d type = %s, want %s", i+1, cellDep.DepType, tt.cellDeps[i].DepType)
+				}
+			}
+		})
+	}
+}
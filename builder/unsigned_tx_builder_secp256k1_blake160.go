@@ -63,8 +63,12 @@ func (b UnsignedTxBuilderSecp256k1) BuildInputs() ([]*ckbTypes.CellInput, map[st
 		if err != nil {
 			return nil, nil, err
 		}
+		since, err := GetOperationSince(operation)
+		if err != nil {
+			return nil, nil, err
+		}
 		cellInputs = append(cellInputs, &ckbTypes.CellInput{
-			Since:          0,
+			Since:          since,
 			PreviousOutput: outPoint,
 		})
 	}
@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+var _ UnsignedTxBuilder = UnsignedTxBuilderSecp256k1Multisig{}
+
+type UnsignedTxBuilderSecp256k1Multisig struct {
+	UnsignedTxBuilderSecp256k1
+}
+
+func NewUnsignedTxBuilderSecp256k1Multisig(cfg *config.Config, inputOperations []*types.Operation, outputOperations []*types.Operation) *UnsignedTxBuilderSecp256k1Multisig {
+	b := UnsignedTxBuilderSecp256k1Multisig{
+		UnsignedTxBuilderSecp256k1{
+			Cfg:              cfg,
+			InputOperations:  inputOperations,
+			OutputOperations: outputOperations,
+		},
+	}
+	b.UnsignedTx.BuildVersion = b.BuildVersion
+	b.UnsignedTx.BuildCellDeps = b.BuildCellDeps
+	b.UnsignedTx.BuildHeaderDeps = b.BuildHeaderDeps
+	b.UnsignedTx.BuildInputs = b.BuildInputs
+	b.UnsignedTx.BuildOutputs = b.BuildOutputs
+	b.UnsignedTx.BuildOutputsData = b.BuildOutputsData
+	b.UnsignedTx.BuildWitnesses = b.BuildWitnesses
+	return &b
+}
+
+func (b UnsignedTxBuilderSecp256k1Multisig) BuildCellDeps() ([]*ckbTypes.CellDep, error) {
+	var cellDeps []*ckbTypes.CellDep
+	cellDeps = append(cellDeps, &ckbTypes.CellDep{
+		OutPoint: &ckbTypes.OutPoint{
+			TxHash: ckbTypes.HexToHash(b.Cfg.Secp256k1Blake160Mutisig.Deps[0].TxHash),
+			Index:  b.Cfg.Secp256k1Blake160Mutisig.Deps[0].Index,
+		},
+		DepType: ckbTypes.DepType(b.Cfg.Secp256k1Blake160Mutisig.Deps[0].DepType),
+	})
+
+	return cellDeps, nil
+}
+
+func (b UnsignedTxBuilderSecp256k1Multisig) BuildWitnesses() ([][]byte, error) {
+	cellInputsSize := len(b.InputOperations)
+	witnesses := make([][]byte, cellInputsSize)
+	indexGroups, err := BuildIndexGroups(b.InputOperations)
+	if err != nil {
+		return nil, err
+	}
+	for _, indexes := range indexGroups {
+		firstIndexOfGroup := indexes[0]
+		multisigScript, err := GetMultisigScript(b.InputOperations[firstIndexOfGroup])
+		if err != nil {
+			return nil, err
+		}
+		witnessArgs, err := BuildMultisigWitnessArgs(multisigScript)
+		if err != nil {
+			return nil, err
+		}
+		// witnessArgs with the multisig script and empty signatures placeholder
+		witnesses[firstIndexOfGroup], err = witnessArgs.Serialize()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return witnesses, nil
+}
+
+// BuildInputs sets the since carried by the multisig lock args
+func (b UnsignedTxBuilderSecp256k1Multisig) BuildInputs() ([]*ckbTypes.CellInput, map[string]interface{}, error) {
+	cellInputs, options, err := b.UnsignedTxBuilderSecp256k1.BuildInputs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, operation := range b.InputOperations {
+		since, err := GetMultisigInputSince(operation)
+		if err != nil {
+			return nil, nil, err
+		}
+		cellInputs[i].Since = since
+	}
+	return cellInputs, options, nil
+}
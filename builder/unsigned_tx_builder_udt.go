@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+var _ UnsignedTxBuilder = UnsignedTxBuilderUDT{}
+
+// UnsignedTxBuilderUDT builds sUDT transfers from secp256k1_blake160_sighash_all cells,
+// plain CKB operations can be mixed in to pay the fee and collect the change.
+type UnsignedTxBuilderUDT struct {
+	UnsignedTxBuilderSecp256k1
+}
+
+func NewUnsignedTxBuilderUDT(cfg *config.Config, inputOperations []*types.Operation, outputOperations []*types.Operation) *UnsignedTxBuilderUDT {
+	b := UnsignedTxBuilderUDT{
+		UnsignedTxBuilderSecp256k1{
+			Cfg:              cfg,
+			InputOperations:  inputOperations,
+			OutputOperations: outputOperations,
+		},
+	}
+	b.UnsignedTx.BuildVersion = b.BuildVersion
+	b.UnsignedTx.BuildCellDeps = b.BuildCellDeps
+	b.UnsignedTx.BuildHeaderDeps = b.BuildHeaderDeps
+	b.UnsignedTx.BuildInputs = b.BuildInputs
+	b.UnsignedTx.BuildOutputs = b.BuildOutputs
+	b.UnsignedTx.BuildOutputsData = b.BuildOutputsData
+	b.UnsignedTx.BuildWitnesses = b.BuildWitnesses
+	return &b
+}
+
+func (b UnsignedTxBuilderUDT) BuildCellDeps() ([]*ckbTypes.CellDep, error) {
+	cellDeps, err := b.UnsignedTxBuilderSecp256k1.BuildCellDeps()
+	if err != nil {
+		return nil, err
+	}
+	cellDeps = append(cellDeps, &ckbTypes.CellDep{
+		OutPoint: &ckbTypes.OutPoint{
+			TxHash: ckbTypes.HexToHash(b.Cfg.UDT.Deps[0].TxHash),
+			Index:  b.Cfg.UDT.Deps[0].Index,
+		},
+		DepType: ckbTypes.DepType(b.Cfg.UDT.Deps[0].DepType),
+	})
+
+	return cellDeps, nil
+}
+
+func (b UnsignedTxBuilderUDT) BuildOutputs(options map[string]interface{}) ([]*ckbTypes.CellOutput, map[string]interface{}, error) {
+	var cellOutputs []*ckbTypes.CellOutput
+	for _, operation := range b.OutputOperations {
+		parsedAddress, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !IsUDTOperation(operation) {
+			capacity, err := strconv.ParseUint(operation.Amount.Value, 10, 64)
+			if err != nil {
+				return nil, nil, err
+			}
+			cellOutputs = append(cellOutputs, &ckbTypes.CellOutput{
+				Capacity: capacity,
+				Lock:     parsedAddress.Script,
+			})
+			continue
+		}
+		capacity, err := GetOperationCapacity(operation)
+		if err != nil {
+			return nil, nil, err
+		}
+		typeScript, err := GetUDTTypeScript(b.Cfg, operation.Amount.Currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		cellOutputs = append(cellOutputs, &ckbTypes.CellOutput{
+			Capacity: capacity,
+			Lock:     parsedAddress.Script,
+			Type:     typeScript,
+		})
+	}
+	return cellOutputs, nil, nil
+}
+
+func (b UnsignedTxBuilderUDT) BuildOutputsData(options map[string]interface{}) ([][]byte, error) {
+	var outputsData [][]byte
+	for _, operation := range b.OutputOperations {
+		if !IsUDTOperation(operation) {
+			outputsData = append(outputsData, []byte{})
+			continue
+		}
+		amount, err := ParseUDTAmount(operation.Amount.Value)
+		if err != nil {
+			return nil, err
+		}
+		outputsData = append(outputsData, GenerateUDTAmountData(amount))
+	}
+	return outputsData, nil
+}
@@ -1,15 +1,27 @@
 package builder
 
 import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 	"github.com/nervosnetwork/ckb-sdk-go/address"
 	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
-	"strconv"
-	"strings"
 )
 
 func GenerateOutPointFromCoinIdentifier(identifier string) (*ckbTypes.OutPoint, error) {
 	splittedIdentifier := strings.Split(identifier, ":")
+	if len(splittedIdentifier) != 2 {
+		return nil, fmt.Errorf("invalid coin identifier %s", identifier)
+	}
 	index, err := strconv.ParseUint(splittedIdentifier[1], 10, 32)
 	if err != nil {
 		return nil, err
@@ -20,8 +32,10 @@ func GenerateOutPointFromCoinIdentifier(identifier string) (*ckbTypes.OutPoint,
 	}, nil
 }
 
+// BuildIndexGroups groups input indexes by lock script hash, groups are ordered by their first input index
 func BuildIndexGroups(inputOperations []*types.Operation) ([][]int, error) {
-	lockScriptHashes := make(map[ckbTypes.Hash][]int)
+	lockScriptHashes := make(map[ckbTypes.Hash]int)
+	var indexGroups [][]int
 	for i, operation := range inputOperations {
 		parsedAddress, err := address.Parse(operation.Account.Address)
 		if err != nil {
@@ -31,14 +45,351 @@ func BuildIndexGroups(inputOperations []*types.Operation) ([][]int, error) {
 		if err != nil {
 			return nil, err
 		}
-		if _, ok := lockScriptHashes[lockHash]; !ok {
-			lockScriptHashes[lockHash] = append(lockScriptHashes[lockHash], i)
+		if groupIndex, ok := lockScriptHashes[lockHash]; ok {
+			indexGroups[groupIndex] = append(indexGroups[groupIndex], i)
+		} else {
+			lockScriptHashes[lockHash] = len(indexGroups)
+			indexGroups = append(indexGroups, []int{i})
 		}
 	}
 
-	indexGroups := make([][]int, 0, len(lockScriptHashes))
-	for _, index := range lockScriptHashes {
-		indexGroups = append(indexGroups, index)
-	}
 	return indexGroups, nil
 }
+
+// BuildSigningIndexGroups returns the index groups whose first witness is a placeholder to be signed
+func BuildSigningIndexGroups(tx *ckbTypes.Transaction, inputOperations []*types.Operation) ([][]int, error) {
+	indexGroups, err := BuildIndexGroups(inputOperations)
+	if err != nil {
+		return nil, err
+	}
+	var signingIndexGroups [][]int
+	for _, indexGroup := range indexGroups {
+		if len(tx.Witnesses[indexGroup[0]]) == 0 {
+			continue
+		}
+		signingIndexGroups = append(signingIndexGroups, indexGroup)
+	}
+
+	return signingIndexGroups, nil
+}
+
+func IsAnyoneCanPayLock(script *ckbTypes.Script, cfg *config.Config) bool {
+	return string(script.HashType) == cfg.ACP.Script.HashType &&
+		script.CodeHash.String() == cfg.ACP.Script.CodeHash
+}
+
+func GetMultisigScript(operation *types.Operation) (*ckb.MultisigScript, error) {
+	var metadata ckb.OperationMetadata
+	if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
+		return nil, err
+	}
+	if metadata.MultisigScript == nil {
+		return nil, errors.New("missing multisig script in operation metadata")
+	}
+	return metadata.MultisigScript, nil
+}
+
+// BuildMultisigWitnessArgs returns the witness args whose lock is the multisig script followed by threshold empty signatures
+func BuildMultisigWitnessArgs(multisigScript *ckb.MultisigScript) (*ckbTypes.WitnessArgs, error) {
+	serializedScript, err := multisigScript.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	lock := append(serializedScript, make([]byte, ckb.SignatureSize*int(multisigScript.Threshold))...)
+	return &ckbTypes.WitnessArgs{
+		Lock: lock,
+	}, nil
+}
+
+// IsUDTOperation reports whether the operation amount is denominated in a sUDT token
+func IsUDTOperation(operation *types.Operation) bool {
+	return operation.Amount != nil && operation.Amount.Currency != nil && operation.Amount.Currency.Symbol != ckb.CkbSymbol
+}
+
+// GetUDTTypeScript returns the sUDT type script of the configured token matching the currency,
+// a currency matching several configured tokens is ambiguous and rejected
+func GetUDTTypeScript(cfg *config.Config, currency *types.Currency) (*ckbTypes.Script, error) {
+	var matches []string
+	for typeArgs, token := range cfg.UDT.Tokens {
+		if token.Symbol == currency.Symbol && int32(token.Decimal) == currency.Decimals {
+			matches = append(matches, typeArgs)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("unsupported token %s", currency.Symbol)
+	}
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("token %s is configured with several type script args", currency.Symbol)
+	}
+	args, err := hexutil.Decode(matches[0])
+	if err != nil {
+		return nil, err
+	}
+	return &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.UDT.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.UDT.Script.HashType),
+		Args:     args,
+	}, nil
+}
+
+// ParseUDTAmount parses the absolute value of a sUDT operation amount, which must fit in uint128
+func ParseUDTAmount(value string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(strings.TrimPrefix(value, "-"), 10)
+	if !ok || amount.Sign() < 0 || amount.BitLen() > ckb.UDTAmountSize*8 {
+		return nil, fmt.Errorf("invalid udt amount %s", value)
+	}
+	return amount, nil
+}
+
+// GenerateUDTAmountData encodes the amount as the 16 bytes little-endian sUDT cell data
+func GenerateUDTAmountData(amount *big.Int) []byte {
+	data := make([]byte, ckb.UDTAmountSize)
+	bigEndian := amount.Bytes()
+	for i, b := range bigEndian {
+		data[len(bigEndian)-1-i] = b
+	}
+	return data
+}
+
+// GetOperationCapacity returns the cell capacity declared in the operation metadata
+func GetOperationCapacity(operation *types.Operation) (uint64, error) {
+	var metadata ckb.OperationMetadata
+	if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
+		return 0, err
+	}
+	if metadata.Capacity == "" {
+		return 0, errors.New("missing capacity in operation metadata")
+	}
+	return strconv.ParseUint(metadata.Capacity, 10, 64)
+}
+
+// ParseUDTAmountData decodes the 16 bytes little-endian amount at the beginning of sUDT cell data
+func ParseUDTAmountData(data []byte) (*big.Int, error) {
+	if len(data) < ckb.UDTAmountSize {
+		return nil, fmt.Errorf("invalid udt data size %d", len(data))
+	}
+	bigEndian := make([]byte, ckb.UDTAmountSize)
+	for i := 0; i < ckb.UDTAmountSize; i++ {
+		bigEndian[ckb.UDTAmountSize-1-i] = data[i]
+	}
+	return new(big.Int).SetBytes(bigEndian), nil
+}
+
+// GetDAOTypeScript returns the Nervos DAO type script, its args are empty
+func GetDAOTypeScript(cfg *config.Config) *ckbTypes.Script {
+	return &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.DAO.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.DAO.Script.HashType),
+		Args:     []byte{},
+	}
+}
+
+// GetOperationCellType returns the cell type declared in the operation metadata, empty when it is not declared.
+// DAO_DEPOSIT and DAO_WITHDRAW operations are deposit and withdrawing cells whether the metadata declares it or not.
+func GetOperationCellType(operation *types.Operation) (string, error) {
+	var metadata ckb.OperationMetadata
+	if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
+		return "", err
+	}
+	var cellType string
+	switch operation.Type {
+	case ckb.DAODepositOpType:
+		cellType = ckb.DAODepositCell.String()
+	case ckb.DAOWithdrawOpType:
+		cellType = ckb.DAOWithdrawingCell.String()
+	default:
+		return metadata.CellType, nil
+	}
+	if metadata.CellType != "" && metadata.CellType != cellType {
+		return "", fmt.Errorf("%s operation declares cell type %s", operation.Type, metadata.CellType)
+	}
+	return cellType, nil
+}
+
+// IsInputOperation reports whether the operation spends a cell, DAO_WITHDRAW spends a withdrawing cell
+func IsInputOperation(operation *types.Operation) bool {
+	return operation.Type == ckb.InputOpType || operation.Type == ckb.DAOWithdrawOpType
+}
+
+// IsOutputOperation reports whether the operation creates a cell, DAO_DEPOSIT creates a deposit cell
+func IsOutputOperation(operation *types.Operation) bool {
+	return operation.Type == ckb.OutputOpType || operation.Type == ckb.DAODepositOpType
+}
+
+// ParseWitnessArgs decodes a serialized WitnessArgs table whose fields are optional bytes
+func ParseWitnessArgs(witness []byte) (*ckbTypes.WitnessArgs, error) {
+	const fieldCount = 3
+	headerSize := 4 * (fieldCount + 1)
+	if len(witness) < headerSize || binary.LittleEndian.Uint32(witness[0:4]) != uint32(len(witness)) {
+		return nil, errors.New("invalid witness args")
+	}
+	offsets := make([]uint32, fieldCount+1)
+	for i := 0; i < fieldCount; i++ {
+		offsets[i] = binary.LittleEndian.Uint32(witness[4*(i+1) : 4*(i+2)])
+	}
+	offsets[fieldCount] = uint32(len(witness))
+	if offsets[0] != uint32(headerSize) {
+		return nil, errors.New("invalid witness args")
+	}
+	fields := make([][]byte, fieldCount)
+	for i := 0; i < fieldCount; i++ {
+		if offsets[i] > offsets[i+1] {
+			return nil, errors.New("invalid witness args")
+		}
+		field := witness[offsets[i]:offsets[i+1]]
+		if len(field) == 0 {
+			continue
+		}
+		if len(field) < 4 || binary.LittleEndian.Uint32(field[0:4]) != uint32(len(field)-4) {
+			return nil, errors.New("invalid witness args")
+		}
+		fields[i] = field[4:]
+	}
+	return &ckbTypes.WitnessArgs{
+		Lock:       fields[0],
+		InputType:  fields[1],
+		OutputType: fields[2],
+	}, nil
+}
+
+// GetMultisigInputSince returns the since of a secp256k1_blake160_multisig_all input, lock args of 28 bytes carry the
+// since the input must be spent with, which is used when the operation declares no since
+func GetMultisigInputSince(operation *types.Operation) (uint64, error) {
+	since, err := GetOperationSince(operation)
+	if err != nil {
+		return 0, err
+	}
+	parsedAddress, err := address.Parse(operation.Account.Address)
+	if err != nil {
+		return 0, err
+	}
+	args := parsedAddress.Script.Args
+	switch len(args) {
+	case 20:
+		return since, nil
+	case 28:
+		lockSince := binary.LittleEndian.Uint64(args[20:])
+		if since != 0 && since != lockSince {
+			return 0, fmt.Errorf("since %d does not match the since %d of the multisig lock args", since, lockSince)
+		}
+		return lockSince, nil
+	default:
+		return 0, fmt.Errorf("invalid multisig lock args length %d", len(args))
+	}
+}
+
+// GetOperationSince returns the since of an INPUT operation from its raw or structured form in the metadata,
+// zero when neither is declared
+func GetOperationSince(operation *types.Operation) (uint64, error) {
+	var metadata ckb.OperationMetadata
+	if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
+		return 0, err
+	}
+	var since uint64
+	if metadata.TimeLock != nil {
+		var err error
+		if since, err = metadata.TimeLock.Since(); err != nil {
+			return 0, err
+		}
+	}
+	if metadata.Since != nil {
+		if metadata.TimeLock != nil && uint64(*metadata.Since) != since {
+			return 0, fmt.Errorf("since %s does not match the time lock", metadata.Since.String())
+		}
+		since = uint64(*metadata.Since)
+		if since != 0 {
+			if _, err := ckb.ParseSince(since); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return since, nil
+}
+
+// GetOperationCellOutput returns the type script and data declared in the metadata of an OUTPUT operation,
+// the data may omit the 0x prefix
+func GetOperationCellOutput(operation *types.Operation) (*ckbTypes.Script, []byte, error) {
+	var metadata ckb.OperationMetadata
+	if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
+		return nil, nil, err
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(metadata.Data, "0x"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return metadata.Type, data, nil
+}
+
+// GenerateCellDep parses a cell dep whose out point is formatted as a coin identifier
+func GenerateCellDep(cellDep *ckb.CellDep) (*ckbTypes.CellDep, error) {
+	outPoint, err := GenerateOutPointFromCoinIdentifier(cellDep.OutPoint)
+	if err != nil {
+		return nil, err
+	}
+	depType := ckbTypes.DepType(cellDep.DepType)
+	if depType != ckbTypes.DepTypeCode && depType != ckbTypes.DepTypeDepGroup {
+		return nil, fmt.Errorf("invalid dep type %s", cellDep.DepType)
+	}
+	return &ckbTypes.CellDep{
+		OutPoint: outPoint,
+		DepType:  depType,
+	}, nil
+}
+
+// OccupiedCapacity returns the capacity in shannons the cell occupies, one CKB per byte of its capacity,
+// lock, type and data
+func OccupiedCapacity(output *ckbTypes.CellOutput, dataSize int) uint64 {
+	size := 8 + scriptOccupiedSize(output.Lock) + uint64(dataSize)
+	if output.Type != nil {
+		size += scriptOccupiedSize(output.Type)
+	}
+	return size * ckb.ShannonsPerCKB
+}
+
+func scriptOccupiedSize(script *ckbTypes.Script) uint64 {
+	return 32 + 1 + uint64(len(script.Args))
+}
+
+// GetOperationOccupiedCapacity returns the occupied capacity of the cell the output operation creates, including the
+// type script and data the builders add to sUDT and DAO cells
+func GetOperationOccupiedCapacity(cfg *config.Config, operation *types.Operation) (uint64, error) {
+	parsedAddress, err := address.Parse(operation.Account.Address)
+	if err != nil {
+		return 0, err
+	}
+	output := &ckbTypes.CellOutput{Lock: parsedAddress.Script}
+	if IsUDTOperation(operation) {
+		output.Type, err = GetUDTTypeScript(cfg, operation.Amount.Currency)
+		if err != nil {
+			return 0, err
+		}
+		return OccupiedCapacity(output, ckb.UDTAmountSize), nil
+	}
+	cellType, err := GetOperationCellType(operation)
+	if err != nil {
+		return 0, err
+	}
+	if cellType == ckb.DAODepositCell.String() || cellType == ckb.DAOWithdrawingCell.String() {
+		output.Type = GetDAOTypeScript(cfg)
+		return OccupiedCapacity(output, ckb.DAODataSize), nil
+	}
+	typeScript, data, err := GetOperationCellOutput(operation)
+	if err != nil {
+		return 0, err
+	}
+	output.Type = typeScript
+	return OccupiedCapacity(output, len(data)), nil
+}
+
+// SerializeTransaction serializes the transaction with its witnesses, the sdk only serializes the raw transaction
+func SerializeTransaction(tx *ckbTypes.Transaction) ([]byte, error) {
+	rawTx, err := tx.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	witnesses := make([][]byte, len(tx.Witnesses))
+	for i, witness := range tx.Witnesses {
+		witnesses[i] = ckbTypes.SerializeBytes(witness)
+	}
+	return ckbTypes.SerializeTable([][]byte{rawTx, ckbTypes.SerializeDynVec(witnesses)}), nil
+}
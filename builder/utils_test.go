@@ -0,0 +1,308 @@
+package builder
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGetMultisigInputSince(t *testing.T) {
+	multisigScript := &ckb.MultisigScript{Threshold: 1, PubKeyHashes: []string{testPubKeyHash(1)}}
+	args, err := multisigScript.Args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockSince := uint64(1000) // absolute block number 1000
+	sinceArgs := append(append([]byte{}, args...), ckbTypes.SerializeUint64(lockSince)...)
+	multisigAddress := func(args []byte) string {
+		return testAddress(t, &ckbTypes.Script{
+			CodeHash: ckbTypes.HexToHash(transaction.SECP256K1_BLAKE160_MULTISIG_ALL_TYPE_HASH),
+			HashType: ckbTypes.HashTypeType,
+			Args:     args,
+		})
+	}
+
+	tests := []struct {
+		name    string
+		args    []byte
+		since   *hexutil.Uint64
+		want    uint64
+		wantErr bool
+	}{
+		{name: "plain args", args: args, want: 0},
+		{name: "plain args with since", args: args, since: testSince(100), want: 100},
+		{name: "since args derive", args: sinceArgs, want: lockSince},
+		{name: "since args match", args: sinceArgs, since: testSince(lockSince), want: lockSince},
+		{name: "since args mismatch", args: sinceArgs, since: testSince(lockSince + 1), wantErr: true},
+		{name: "invalid args length", args: args[:19], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation := testInput(multisigAddress(tt.args), "-10000000000", 0)
+			operation.Metadata = testMetadata(t, &ckb.OperationMetadata{Since: tt.since})
+			got, err := GetMultisigInputSince(operation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetMultisigInputSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetMultisigInputSince() = %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUDTTypeScript(t *testing.T) {
+	cfg := testConfig(t)
+	if err := yaml.Unmarshal([]byte(`
+'0x01':
+  symbol: AAA
+  decimal: 8
+'0x02':
+  symbol: BBB
+  decimal: 6
+'0x03':
+  symbol: BBB
+  decimal: 8
+'0x04':
+  symbol: BBB
+  decimal: 8
+`), &cfg.UDT.Tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		currency *types.Currency
+		wantArgs string
+		wantErr  bool
+	}{
+		{name: "configured token", currency: &types.Currency{Symbol: "AAA", Decimals: 8}, wantArgs: "0x01"},
+		{name: "symbol with other decimals", currency: &types.Currency{Symbol: "BBB", Decimals: 6}, wantArgs: "0x02"},
+		{name: "ambiguous token", currency: &types.Currency{Symbol: "BBB", Decimals: 8}, wantErr: true},
+		{name: "decimals mismatch", currency: &types.Currency{Symbol: "AAA", Decimals: 6}, wantErr: true},
+		{name: "unknown token", currency: &types.Currency{Symbol: "CCC", Decimals: 8}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := GetUDTTypeScript(cfg, tt.currency)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetUDTTypeScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := hexutil.Encode(script.Args); got != tt.wantArgs {
+				t.Errorf("GetUDTTypeScript() args = %s, want %s", got, tt.wantArgs)
+			}
+			if script.CodeHash != ckbTypes.HexToHash(cfg.UDT.Script.CodeHash) {
+				t.Errorf("GetUDTTypeScript() code hash = %s, want %s", script.CodeHash.String(), cfg.UDT.Script.CodeHash)
+			}
+		})
+	}
+}
+
+func TestParseWitnessArgs(t *testing.T) {
+	serialize := func(witnessArgs *ckbTypes.WitnessArgs) []byte {
+		witness, err := witnessArgs.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return witness
+	}
+	signed := serialize(&ckbTypes.WitnessArgs{Lock: bytes.Repeat([]byte{1}, ckb.SignatureSize)})
+	// the first field says its bytes are one byte longer than they are
+	invalidField := append([]byte{}, signed...)
+	invalidField[16]++
+
+	tests := []struct {
+		name    string
+		witness []byte
+		want    *ckbTypes.WitnessArgs
+		wantErr bool
+	}{
+		{name: "empty fields", witness: serialize(&ckbTypes.WitnessArgs{}), want: &ckbTypes.WitnessArgs{}},
+		{name: "lock", witness: signed, want: &ckbTypes.WitnessArgs{Lock: bytes.Repeat([]byte{1}, ckb.SignatureSize)}},
+		{
+			name:    "all fields",
+			witness: serialize(&ckbTypes.WitnessArgs{Lock: []byte{1}, InputType: []byte{2, 2}, OutputType: []byte{3, 3, 3}}),
+			want:    &ckbTypes.WitnessArgs{Lock: []byte{1}, InputType: []byte{2, 2}, OutputType: []byte{3, 3, 3}},
+		},
+		{name: "empty witness", witness: []byte{}, wantErr: true},
+		{name: "truncated", witness: signed[:len(signed)-1], wantErr: true},
+		{name: "invalid field length", witness: invalidField, wantErr: true},
+		{name: "invalid header", witness: signed[:12], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWitnessArgs(tt.witness)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWitnessArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !bytes.Equal(got.Lock, tt.want.Lock) || !bytes.Equal(got.InputType, tt.want.InputType) || !bytes.Equal(got.OutputType, tt.want.OutputType) {
+				t.Errorf("ParseWitnessArgs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOperationSince(t *testing.T) {
+	timeLock := &ckb.TimeLock{Relative: true, Metric: ckb.BlockNumberMetric, BlockNumber: 100}
+
+	tests := []struct {
+		name     string
+		metadata *ckb.OperationMetadata
+		want     uint64
+		wantErr  bool
+	}{
+		{name: "no since", metadata: &ckb.OperationMetadata{}, want: 0},
+		{name: "raw since", metadata: &ckb.OperationMetadata{Since: testSince(0x8000000000000064)}, want: 0x8000000000000064},
+		{name: "time lock", metadata: &ckb.OperationMetadata{TimeLock: timeLock}, want: 0x8000000000000064},
+		{name: "matching raw since and time lock", metadata: &ckb.OperationMetadata{Since: testSince(0x8000000000000064), TimeLock: timeLock}, want: 0x8000000000000064},
+		{name: "conflicting raw since and time lock", metadata: &ckb.OperationMetadata{Since: testSince(0x8000000000000065), TimeLock: timeLock}, wantErr: true},
+		{name: "invalid raw since", metadata: &ckb.OperationMetadata{Since: testSince(0x6000000000000001)}, wantErr: true},
+		{name: "invalid time lock", metadata: &ckb.OperationMetadata{TimeLock: &ckb.TimeLock{Metric: ckb.EpochMetric}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation := testInput(testSighashAddress(t, 1), "-10000000000", 0)
+			operation.Metadata = testMetadata(t, tt.metadata)
+			got, err := GetOperationSince(operation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetOperationSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetOperationSince() = %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccupiedCapacity(t *testing.T) {
+	lock := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{1}, 20),
+	}
+	typeScript := &ckbTypes.Script{HashType: ckbTypes.HashTypeData, Args: bytes.Repeat([]byte{2}, 32)}
+
+	tests := []struct {
+		name     string
+		output   *ckbTypes.CellOutput
+		dataSize int
+		want     uint64
+	}{
+		{name: "sighash cell", output: &ckbTypes.CellOutput{Lock: lock}, want: 61 * ckb.ShannonsPerCKB},
+		{name: "sighash cell with data", output: &ckbTypes.CellOutput{Lock: lock}, dataSize: 10, want: 71 * ckb.ShannonsPerCKB},
+		{name: "typed cell", output: &ckbTypes.CellOutput{Lock: lock, Type: typeScript}, dataSize: ckb.UDTAmountSize, want: 142 * ckb.ShannonsPerCKB},
+		{name: "lock without args", output: &ckbTypes.CellOutput{Lock: &ckbTypes.Script{}}, want: 41 * ckb.ShannonsPerCKB},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OccupiedCapacity(tt.output, tt.dataSize); got != tt.want {
+				t.Errorf("OccupiedCapacity() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOperationOccupiedCapacity(t *testing.T) {
+	cfg := testConfig(t)
+	if err := yaml.Unmarshal([]byte(`
+'0x01':
+  symbol: AAA
+  decimal: 8
+`), &cfg.UDT.Tokens); err != nil {
+		t.Fatal(err)
+	}
+	addr := testSighashAddress(t, 1)
+	output := func(currency *types.Currency, metadata *ckb.OperationMetadata) *types.Operation {
+		operation := testOutput(addr, "10000000000")
+		if currency != nil {
+			operation.Amount.Currency = currency
+		}
+		if metadata != nil {
+			operation.Metadata = testMetadata(t, metadata)
+		}
+		return operation
+	}
+	typeScript := &ckbTypes.Script{HashType: ckbTypes.HashTypeType, Args: bytes.Repeat([]byte{2}, 20)}
+
+	tests := []struct {
+		name      string
+		operation *types.Operation
+		want      uint64
+		wantErr   bool
+	}{
+		{name: "plain cell", operation: output(nil, nil), want: 61 * ckb.ShannonsPerCKB},
+		{name: "sUDT cell", operation: output(&types.Currency{Symbol: "AAA", Decimals: 8}, nil), want: 111 * ckb.ShannonsPerCKB},
+		{name: "DAO deposit cell", operation: output(nil, &ckb.OperationMetadata{CellType: ckb.DAODepositCell.String()}), want: 102 * ckb.ShannonsPerCKB},
+		{name: "declared type and data", operation: output(nil, &ckb.OperationMetadata{Type: typeScript, Data: "0x0102"}), want: 116 * ckb.ShannonsPerCKB},
+		{name: "declared data without prefix", operation: output(nil, &ckb.OperationMetadata{Data: "0102"}), want: 63 * ckb.ShannonsPerCKB},
+		{name: "unknown token", operation: output(&types.Currency{Symbol: "BBB", Decimals: 8}, nil), wantErr: true},
+		{name: "invalid data", operation: output(nil, &ckb.OperationMetadata{Data: "0x0"}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetOperationOccupiedCapacity(cfg, tt.operation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetOperationOccupiedCapacity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetOperationOccupiedCapacity() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOperationCellType(t *testing.T) {
+	addr := testSighashAddress(t, 1)
+	operation := func(operationType string, metadata *ckb.OperationMetadata) *types.Operation {
+		operation := testOutput(addr, "10000000000")
+		operation.Type = operationType
+		if metadata != nil {
+			operation.Metadata = testMetadata(t, metadata)
+		}
+		return operation
+	}
+
+	tests := []struct {
+		name       string
+		operation  *types.Operation
+		want       string
+		wantInput  bool
+		wantOutput bool
+		wantErr    bool
+	}{
+		{name: "undeclared", operation: operation(ckb.OutputOpType, nil), wantOutput: true},
+		{name: "declared deposit", operation: operation(ckb.OutputOpType, &ckb.OperationMetadata{CellType: ckb.DAODepositCell.String()}), want: ckb.DAODepositCell.String(), wantOutput: true},
+		{name: "declared withdrawing", operation: operation(ckb.InputOpType, &ckb.OperationMetadata{CellType: ckb.DAOWithdrawingCell.String()}), want: ckb.DAOWithdrawingCell.String(), wantInput: true},
+		{name: "DAO_DEPOSIT", operation: operation(ckb.DAODepositOpType, nil), want: ckb.DAODepositCell.String(), wantOutput: true},
+		{name: "DAO_WITHDRAW", operation: operation(ckb.DAOWithdrawOpType, nil), want: ckb.DAOWithdrawingCell.String(), wantInput: true},
+		{name: "DAO_WITHDRAW declaring its cell type", operation: operation(ckb.DAOWithdrawOpType, &ckb.OperationMetadata{CellType: ckb.DAOWithdrawingCell.String()}), want: ckb.DAOWithdrawingCell.String(), wantInput: true},
+		{name: "DAO_DEPOSIT declaring another cell type", operation: operation(ckb.DAODepositOpType, &ckb.OperationMetadata{CellType: ckb.DAOWithdrawingCell.String()}), wantOutput: true, wantErr: true},
+		{name: "DAO_INTEREST", operation: operation(ckb.DAOInterestOpType, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetOperationCellType(tt.operation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetOperationCellType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetOperationCellType() = %q, want %q", got, tt.want)
+			}
+			if IsInputOperation(tt.operation) != tt.wantInput || IsOutputOperation(tt.operation) != tt.wantOutput {
+				t.Errorf("input %v output %v, want input %v output %v", IsInputOperation(tt.operation), IsOutputOperation(tt.operation), tt.wantInput, tt.wantOutput)
+			}
+		})
+	}
+}
@@ -1,58 +1,338 @@
 package ckb
 
-import ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-sdk-go/crypto/blake2b"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
 
 const (
 	InputOpType              = "INPUT"
 	OutputOpType             = "OUTPUT"
 	RewardOpType             = "Reward"
-	BaseTxSize               = 68 // empty cellDeps + empty headerDeps + empty inputs + empty outputs + empty outputs_data + empty witnesses + version
+	FeeOpType                = "FEE"
+	DAODepositOpType         = "DAO_DEPOSIT"  // creates a Nervos DAO deposit cell
+	DAOWithdrawOpType        = "DAO_WITHDRAW" // spends a withdrawing cell, which leaves the Nervos DAO
+	DAOInterestOpType        = "DAO_INTEREST" // the compensation a DAO_WITHDRAW adds on top of its capacity
 	InputSize                = 44
-	HeaderDepSize            = 32
-	CellDepSize              = 37
+	GroupWitnessSize         = 8 // the empty witness of an input following the first one of its lock group, with its offset
 	SerializedOffsetByteSize = 4
 	BytesInKb                = 1000
 	MinFeeRate               = 1000 // shannons/KB
 	TransferCKB              = "TransferCKB"
+	TransferCKBFromMultisig  = "TransferCKBFromMultisig"
+	TransferUDT              = "TransferUDT"
+	TopUpACP                 = "TopUpACP"
+	DepositDAO               = "DepositDAO"
+	WithdrawDAO              = "WithdrawDAO"
+	UnlockDAO                = "UnlockDAO"
+	GenericCell              = "GenericCell"
+	SignatureSize            = 65
+	UDTAmountSize            = 16
+	DAODataSize              = 8   // deposit block number of a withdrawing cell, zero for a deposit cell
+	DAOLockEpochs            = 180 // deposits are locked for multiples of 180 epochs
+	CkbSymbol                = "CKB"
+	ShannonsPerCKB           = 100000000 // a cell occupies one CKB of capacity per byte
 	SearchLimit              = 1000
+	BatchTransactionsLimit   = 2000
+	EventsBlocksLimit        = 1000
+	SearchTransactionsLimit  = 100
+	MaxOperationDataSize     = 1024 // output data larger than this is described by its hash and length only
+	LiveCellsRetryLimit      = 3
 )
 
 const (
 	Secp256k1Blake160Lock LockType = iota
 	Secp256k1Blake160Multisig
+	AnyoneCanPay
 	UnknownLock
 )
 
 func (l LockType) String() string {
-	return [...]string{"Secp256k1Blake160Lock", "Secp256k1Blake160Multisig", "UnknownLock"}[l]
+	return [...]string{"Secp256k1Blake160Lock", "Secp256k1Blake160Multisig", "AnyoneCanPay", "UnknownLock"}[l]
 }
 
 type LockType int
 
+const (
+	PlainCell CellType = iota
+	SUDTCell
+	DAODepositCell
+	DAOWithdrawingCell
+	UnknownTypeCell
+)
+
+func (c CellType) String() string {
+	return [...]string{"PlainCell", "SUDTCell", "DAODepositCell", "DAOWithdrawingCell", "UnknownTypeCell"}[c]
+}
+
+// CellType classifies a cell by its type script and data
+type CellType int
+
+// PreprocessMetadata enables input selection when Sender is set and no INPUT operation is given
+type PreprocessMetadata struct {
+	Sender string `json:"sender,omitempty"`
+	// CellDeps are the extra cell deps of the type scripts created by GenericCell
+	CellDeps []*CellDep `json:"cell_deps,omitempty"`
+}
+
 type PreprocessOptions struct {
 	ConstructionType       string   `json:"construction_type"`
 	EstimatedTxSize        uint64   `json:"estimated_tx_size"`
 	SuggestedFeeMultiplier *float64 `json:"suggested_fee_multiplier"`
+	Sender                 string   `json:"sender,omitempty"`
+	OutputsCapacity        uint64   `json:"outputs_capacity,omitempty"`
+	// DAOInputs are the coin identifiers of the DAO cells spent by WithdrawDAO and UnlockDAO
+	DAOInputs []string   `json:"dao_inputs,omitempty"`
+	CellDeps  []*CellDep `json:"cell_deps,omitempty"`
 }
 
 type ConstructionMetadata struct {
 	ConstructionType string `json:"construction_type"`
+	// FeeRate is the rate in shannons/KB the suggested fee is priced at
+	FeeRate uint64           `json:"fee_rate,omitempty"`
+	Sender  string           `json:"sender,omitempty"`
+	Inputs  []*SelectedInput `json:"inputs,omitempty"`
+	Change  string           `json:"change,omitempty"`
+	Fee     string           `json:"fee,omitempty"`
+	// HeaderDeps and DAOInputs are resolved from the chain for WithdrawDAO and UnlockDAO
+	HeaderDeps []string    `json:"header_deps,omitempty"`
+	DAOInputs  []*DAOInput `json:"dao_inputs,omitempty"`
+	// CellDeps are appended after the secp256k1_blake160 dep group by GenericCell
+	CellDeps []*CellDep `json:"cell_deps,omitempty"`
+}
+
+// DAOInput returns the DAO input of the coin, nil when the coin is not a DAO input
+func (m *ConstructionMetadata) DAOInput(coinIdentifier string) *DAOInput {
+	for _, input := range m.DAOInputs {
+		if input.CoinIdentifier == coinIdentifier {
+			return input
+		}
+	}
+	return nil
+}
+
+// DAOInput is a deposit cell spent by WithdrawDAO or a withdrawing cell spent by UnlockDAO, DepositHeaderIndex
+// is the index of the deposit block in HeaderDeps. Since and MaximumWithdraw are only set for UnlockDAO.
+type DAOInput struct {
+	CoinIdentifier     string         `json:"coin_identifier"`
+	Capacity           string         `json:"capacity"`
+	DepositBlockNumber uint64         `json:"deposit_block_number"`
+	DepositHeaderIndex uint64         `json:"deposit_header_index"`
+	Since              hexutil.Uint64 `json:"since,omitempty"`
+	MaximumWithdraw    string         `json:"maximum_withdraw,omitempty"`
+}
+
+// SelectedInput is a live cell of the sender chosen by /construction/metadata
+type SelectedInput struct {
+	CoinIdentifier string `json:"coin_identifier"`
+	Capacity       string `json:"capacity"`
 }
 
 type OperationMetadata struct {
-	Data string           `json:"data"`
-	Type *ckbTypes.Script `json:"type"`
+	Data           string           `json:"data"`
+	Type           *ckbTypes.Script `json:"type"`
+	MultisigScript *MultisigScript  `json:"multisig_script,omitempty"`
+	// Capacity is the cell capacity in shannons of an operation whose amount is not denominated in CKB
+	Capacity string `json:"capacity,omitempty"`
+	// DataHash, DataLength and CellType describe the cell of a committed operation, Data is left empty
+	// when the data is larger than MaxOperationDataSize
+	DataHash   string `json:"data_hash,omitempty"`
+	DataLength int    `json:"data_length,omitempty"`
+	CellType   string `json:"cell_type,omitempty"`
+	// InvalidUDTData marks a cell of the sUDT type whose data is too short for an amount, it has no sUDT operation
+	InvalidUDTData bool `json:"invalid_udt_data,omitempty"`
+	// Since is the raw since of an INPUT operation and TimeLock its structured form, a request may set either
+	Since    *hexutil.Uint64 `json:"since,omitempty"`
+	TimeLock *TimeLock       `json:"time_lock,omitempty"`
+}
+
+// Since metrics of a TimeLock
+const (
+	BlockNumberMetric = "block_number"
+	EpochMetric       = "epoch"
+	TimestampMetric   = "timestamp"
+)
+
+const (
+	sinceRelativeFlag    = 0x80
+	sinceMetricMask      = 0x60
+	sinceBlockNumberFlag = 0x00
+	sinceEpochFlag       = 0x20
+	sinceTimestampFlag   = 0x40
+	sinceValueBits       = 56
+	sinceValueMask       = 1<<sinceValueBits - 1
+)
+
+// TimeLock is the structured form of a since, Timestamp is the median time in seconds. An epoch is
+// EpochNumber + EpochIndex / EpochLength.
+type TimeLock struct {
+	Relative    bool   `json:"relative"`
+	Metric      string `json:"metric"`
+	BlockNumber uint64 `json:"block_number,omitempty"`
+	Timestamp   uint64 `json:"timestamp,omitempty"`
+	EpochNumber uint64 `json:"epoch_number,omitempty"`
+	EpochIndex  uint64 `json:"epoch_index,omitempty"`
+	EpochLength uint64 `json:"epoch_length,omitempty"`
+}
+
+// Since encodes the time lock as the flags byte followed by the 56 bits value
+func (t *TimeLock) Since() (uint64, error) {
+	var flags, value uint64
+	switch t.Metric {
+	case BlockNumberMetric:
+		flags, value = sinceBlockNumberFlag, t.BlockNumber
+	case TimestampMetric:
+		flags, value = sinceTimestampFlag, t.Timestamp
+	case EpochMetric:
+		if t.EpochNumber >= 1<<24 || t.EpochLength == 0 || t.EpochLength >= 1<<16 || t.EpochIndex >= t.EpochLength {
+			return 0, fmt.Errorf("invalid epoch %d %d/%d", t.EpochNumber, t.EpochIndex, t.EpochLength)
+		}
+		flags = sinceEpochFlag
+		value = t.EpochLength<<40 | t.EpochIndex<<24 | t.EpochNumber
+	default:
+		return 0, fmt.Errorf("unsupported since metric %s", t.Metric)
+	}
+	if value > sinceValueMask {
+		return 0, fmt.Errorf("since value %d overflows %d bits", value, sinceValueBits)
+	}
+	if t.Relative {
+		flags |= sinceRelativeFlag
+	}
+	return flags<<sinceValueBits | value, nil
+}
+
+// ParseSince decodes a non-zero since, it fails when the flags are not defined by CKB
+func ParseSince(since uint64) (*TimeLock, error) {
+	flags := since >> sinceValueBits
+	value := since & sinceValueMask
+	if flags&^(sinceRelativeFlag|sinceMetricMask) != 0 {
+		return nil, fmt.Errorf("invalid since flags %#x", flags)
+	}
+	timeLock := &TimeLock{
+		Relative: flags&sinceRelativeFlag != 0,
+	}
+	switch flags & sinceMetricMask {
+	case sinceBlockNumberFlag:
+		timeLock.Metric = BlockNumberMetric
+		timeLock.BlockNumber = value
+	case sinceEpochFlag:
+		timeLock.Metric = EpochMetric
+		timeLock.EpochNumber = value & 0xFFFFFF
+		timeLock.EpochIndex = value >> 24 & 0xFFFF
+		timeLock.EpochLength = value >> 40 & 0xFFFF
+		if _, err := timeLock.Since(); err != nil {
+			return nil, err
+		}
+	case sinceTimestampFlag:
+		timeLock.Metric = TimestampMetric
+		timeLock.Timestamp = value
+	default:
+		return nil, fmt.Errorf("invalid since metric flags %#x", flags&sinceMetricMask)
+	}
+	return timeLock, nil
+}
+
+type AccountBalanceMetadata struct {
+	FreeCapacity     string `json:"free_capacity"`
+	OccupiedCapacity string `json:"occupied_capacity"`
+	LockType         string `json:"lock_type"`
 }
 
 type AccountIdentifierMetadata struct {
 	LockType string `json:"lock_type"`
 }
 
+// Cellbase reward components, a cellbase output is split into one Reward operation per component
+const (
+	PrimaryIssuanceReward   = "primary_issuance"
+	SecondaryIssuanceReward = "secondary_issuance"
+	ProposalReward          = "proposal_reward"
+	CommitReward            = "commit_reward"
+)
+
+// RewardOperationMetadata is the metadata of a Reward operation, RewardType is empty when the cellbase is not split
+type RewardOperationMetadata struct {
+	RewardType string `json:"reward_type,omitempty"`
+}
+
+// TransactionMetadata describes a committed transaction, Fee is in shannons and omitted for the cellbase
+type TransactionMetadata struct {
+	Fee         string           `json:"fee,omitempty"`
+	CellDeps    []*CellDep       `json:"cell_deps"`
+	HeaderDeps  []string         `json:"header_deps"`
+	InputsSince []hexutil.Uint64 `json:"inputs_since"`
+}
+
+// CellDep is a cell dep whose out point is formatted as a coin identifier
+type CellDep struct {
+	OutPoint string `json:"out_point"`
+	DepType  string `json:"dep_type"`
+}
+
+// BlockMetadata contains the header fields of a block which are not part of the block identifier
+type BlockMetadata struct {
+	Epoch          hexutil.Uint64 `json:"epoch"`
+	CompactTarget  hexutil.Uint64 `json:"compact_target"`
+	Dao            string         `json:"dao"`
+	Nonce          *hexutil.Big   `json:"nonce"`
+	ProposalsCount int            `json:"proposals_count"`
+}
+
 type Script struct {
 	CodeHash string `json:"code_hash"`
 	HashType string `json:"hash_type"`
 	Args     string `json:"args"`
 }
 type DeriveMetadata struct {
-	Script `json:"script"`
+	Script         `json:"script"`
+	MultisigScript *MultisigScript `json:"multisig_script,omitempty"`
+	// LockType selects the lock of the public key, only AnyoneCanPay is accepted
+	LockType string `json:"lock_type,omitempty"`
+}
+
+// MultisigScript describes a secp256k1_blake160_multisig_all script, the lock args are the blake160 of its serialization.
+type MultisigScript struct {
+	RequireFirstN uint8    `json:"require_first_n"`
+	Threshold     uint8    `json:"threshold"`
+	PubKeyHashes  []string `json:"pubkey_hashes"`
+}
+
+// Serialize returns S | R | M | N | blake160(Pubkey1) | ... | blake160(PubkeyN)
+func (m *MultisigScript) Serialize() ([]byte, error) {
+	n := len(m.PubKeyHashes)
+	if n == 0 || n > 255 {
+		return nil, errors.New("pubkey hashes size must range from 1 to 255")
+	}
+	if m.Threshold == 0 || int(m.Threshold) > n {
+		return nil, fmt.Errorf("threshold must range from 1 to %d", n)
+	}
+	if m.RequireFirstN > m.Threshold {
+		return nil, errors.New("require_first_n must not be greater than threshold")
+	}
+
+	data := []byte{0, m.RequireFirstN, m.Threshold, byte(n)}
+	for _, pubKeyHash := range m.PubKeyHashes {
+		hash, err := hexutil.Decode(pubKeyHash)
+		if err != nil {
+			return nil, err
+		}
+		if len(hash) != 20 {
+			return nil, fmt.Errorf("invalid pubkey hash %s", pubKeyHash)
+		}
+		data = append(data, hash...)
+	}
+	return data, nil
+}
+
+// Args returns the lock args of the multisig script
+func (m *MultisigScript) Args() ([]byte, error) {
+	data, err := m.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return blake2b.Blake160(data)
 }
@@ -0,0 +1,76 @@
+package ckb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTimeLockSince(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeLock *TimeLock
+		want     uint64
+		wantErr  bool
+	}{
+		{name: "absolute block number", timeLock: &TimeLock{Metric: BlockNumberMetric, BlockNumber: 1000}, want: 0x00000000000003e8},
+		{name: "relative block number", timeLock: &TimeLock{Relative: true, Metric: BlockNumberMetric, BlockNumber: 100}, want: 0x8000000000000064},
+		{name: "absolute timestamp", timeLock: &TimeLock{Metric: TimestampMetric, Timestamp: 1600000000}, want: 0x400000005f5e1000},
+		{name: "relative timestamp", timeLock: &TimeLock{Relative: true, Metric: TimestampMetric, Timestamp: 3600}, want: 0xc000000000000e10},
+		{name: "absolute epoch", timeLock: &TimeLock{Metric: EpochMetric, EpochNumber: 185, EpochIndex: 100, EpochLength: 1000}, want: 0x2003e800640000b9},
+		{name: "relative epoch", timeLock: &TimeLock{Relative: true, Metric: EpochMetric, EpochNumber: 180, EpochLength: 1}, want: 0xa0000100000000b4},
+		{name: "value overflow", timeLock: &TimeLock{Metric: BlockNumberMetric, BlockNumber: 1 << 56}, wantErr: true},
+		{name: "zero epoch length", timeLock: &TimeLock{Metric: EpochMetric, EpochNumber: 1}, wantErr: true},
+		{name: "epoch index past length", timeLock: &TimeLock{Metric: EpochMetric, EpochNumber: 1, EpochIndex: 2, EpochLength: 2}, wantErr: true},
+		{name: "epoch number overflow", timeLock: &TimeLock{Metric: EpochMetric, EpochNumber: 1 << 24, EpochLength: 1}, wantErr: true},
+		{name: "unknown metric", timeLock: &TimeLock{Metric: "height", BlockNumber: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.timeLock.Since()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Since() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Since() = %#x, want %#x", got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			parsed, err := ParseSince(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed, tt.timeLock) {
+				t.Errorf("ParseSince() = %+v, want %+v", parsed, tt.timeLock)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		name    string
+		since   uint64
+		want    *TimeLock
+		wantErr bool
+	}{
+		{name: "block number", since: 0x00000000000003e8, want: &TimeLock{Metric: BlockNumberMetric, BlockNumber: 1000}},
+		{name: "relative timestamp", since: 0xc000000000000e10, want: &TimeLock{Relative: true, Metric: TimestampMetric, Timestamp: 3600}},
+		{name: "epoch", since: 0x2003e800640000b9, want: &TimeLock{Metric: EpochMetric, EpochNumber: 185, EpochIndex: 100, EpochLength: 1000}},
+		{name: "undefined flags", since: 0x0100000000000001, wantErr: true},
+		{name: "undefined metric", since: 0x6000000000000001, wantErr: true},
+		{name: "zero epoch length", since: 0x2000000000000001, wantErr: true},
+		{name: "epoch index past length", since: 0x2000020002000001, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSince(tt.since)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSince() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
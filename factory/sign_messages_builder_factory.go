@@ -9,8 +9,14 @@ type SignMessagesBuilderFactory struct{}
 
 func (f SignMessagesBuilderFactory) CreateSignMessagesBuilder(constructionType string) builder.SignMessagesBuilder {
 	switch constructionType {
-	case ckb.TransferCKB:
+	case ckb.TransferCKB, ckb.TransferUDT, ckb.GenericCell:
 		return builder.NewSignMessagesBuilderSecp256k1Blake160()
+	case ckb.TransferCKBFromMultisig:
+		return builder.NewSignMessagesBuilderSecp256k1Blake160Multisig()
+	case ckb.TopUpACP:
+		return builder.NewSignMessagesBuilderACP()
+	case ckb.DepositDAO, ckb.WithdrawDAO, ckb.UnlockDAO:
+		return builder.NewSignMessagesBuilderDAO()
 	default:
 		return nil
 	}
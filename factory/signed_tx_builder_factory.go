@@ -9,8 +9,10 @@ type SignedTxBuilder struct{}
 
 func (u SignedTxBuilder) CreateSignedTxBuilder(constructionType string) builder.SignedTxBuilder {
 	switch constructionType {
-	case ckb.TransferCKB:
+	case ckb.TransferCKB, ckb.TransferUDT, ckb.TopUpACP, ckb.GenericCell, ckb.DepositDAO, ckb.WithdrawDAO, ckb.UnlockDAO:
 		return builder.NewSignedTxCombinerSecp256k1Blake160()
+	case ckb.TransferCKBFromMultisig:
+		return builder.NewSignedTxCombinerSecp256k1Blake160Multisig()
 	default:
 		return nil
 	}
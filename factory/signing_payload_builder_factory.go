@@ -9,10 +9,18 @@ type SigningPayloadBuilderFactory struct{}
 
 func (f SigningPayloadBuilderFactory) CreateSigningPayloadBuilder(constructionType string) builder.SigningPayloadBuilder {
 	switch constructionType {
-	case ckb.TransferCKB:
+	case ckb.TransferCKB, ckb.TransferUDT, ckb.GenericCell, ckb.DepositDAO, ckb.WithdrawDAO, ckb.UnlockDAO:
 		sf := SignMessagesBuilderFactory{}
 		signMessagesBuilder := sf.CreateSignMessagesBuilder(constructionType)
 		return builder.NewSigningPayloadBuilderSecp256k1Blake160(constructionType, signMessagesBuilder)
+	case ckb.TransferCKBFromMultisig:
+		sf := SignMessagesBuilderFactory{}
+		signMessagesBuilder := sf.CreateSignMessagesBuilder(constructionType)
+		return builder.NewSigningPayloadBuilderSecp256k1Blake160Multisig(constructionType, signMessagesBuilder)
+	case ckb.TopUpACP:
+		sf := SignMessagesBuilderFactory{}
+		signMessagesBuilder := sf.CreateSignMessagesBuilder(constructionType)
+		return builder.NewSigningPayloadBuilderACP(constructionType, signMessagesBuilder)
 	default:
 		return nil
 	}
@@ -1,135 +1,63 @@
 package factory
 
 import (
-	"encoding/hex"
+	"fmt"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
-	"github.com/nervosnetwork/ckb-sdk-go/address"
-	"github.com/nervosnetwork/ckb-sdk-go/transaction"
-	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 )
 
 type TxSizeEstimatorFactory struct{}
 
-func (tf TxSizeEstimatorFactory) CreateTxSizeEstimator(constructionType string) TxSizeEstimater {
-	switch constructionType {
-	case ckb.TransferCKB:
-		return NewSecp256k1TxSizeEstimator()
-	default:
+// CreateTxSizeEstimator returns nil when no unsigned tx builder supports the construction type
+func (tf TxSizeEstimatorFactory) CreateTxSizeEstimator(constructionType string, cfg *config.Config) TxSizeEstimater {
+	unsignedTxBuilderFactory := new(UnsignedTxBuilderFactory)
+	if unsignedTxBuilderFactory.CreateUnsignedTxBuilder(constructionType, cfg, nil, nil, nil) == nil {
 		return nil
 	}
+	return &TxSizeEstimator{
+		ConstructionType: constructionType,
+		Cfg:              cfg,
+	}
 }
 
 type TxSizeEstimater interface {
-	EstimatedTxSize(operations []*types.Operation) (uint64, error)
-	HeaderDepsSize() uint64
-	CellDepsSize() uint64
-	WitnessSize(int) uint64
-	OutputSize(*types.Operation) (uint64, error)
-	OutputDataSize(string) (uint64, error)
+	EstimatedTxSize(operations []*types.Operation, metadata *ckb.ConstructionMetadata) (uint64, error)
 }
 
+// TxSizeEstimator builds the placeholder transaction of the operations with the unsigned tx builder used by
+// /construction/payloads. The first witness of every lock group holds a zero signature placeholder as long as the
+// signed witness, so the serialized placeholder is as large as the transaction that is finally submitted.
 type TxSizeEstimator struct {
-	HeaderDepsSize func() uint64
-	CellDepsSize   func() uint64
-	WitnessSize    func(int) uint64
-	OutputSize     func(*types.Operation) (uint64, error)
-	OutputDataSize func(string) (uint64, error)
-}
-
-func (tse *TxSizeEstimator) EstimatedTxSize(operations []*types.Operation) (uint64, error) {
-	var sizeArr []uint64
-	var txSize uint64
-	sizeArr = append(sizeArr, ckb.BaseTxSize, tse.HeaderDepsSize(), tse.CellDepsSize())
-	for i, operation := range operations {
-		switch operation.Type {
-		case ckb.InputOpType:
-			sizeArr = append(sizeArr, ckb.InputSize, tse.WitnessSize(i))
-		case ckb.OutputOpType:
-			var metadata ckb.OperationMetadata
-			if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
-				return 0, err
-			}
-			outputSize, err := tse.OutputSize(operation)
-			if err != nil {
-				return 0, err
-			}
-			outputDataSize, err := tse.OutputDataSize(metadata.Data)
-			if err != nil {
-				return 0, err
-			}
-			sizeArr = append(sizeArr, outputSize, outputDataSize)
+	ConstructionType string
+	Cfg              *config.Config
+}
+
+func (tse *TxSizeEstimator) EstimatedTxSize(operations []*types.Operation, metadata *ckb.ConstructionMetadata) (uint64, error) {
+	var inputOperations, outputOperations []*types.Operation
+	for _, operation := range operations {
+		switch {
+		case builder.IsInputOperation(operation):
+			inputOperations = append(inputOperations, operation)
+		case builder.IsOutputOperation(operation):
+			outputOperations = append(outputOperations, operation)
 		}
 	}
-	for _, size := range sizeArr {
-		txSize += size
+	unsignedTxBuilderFactory := new(UnsignedTxBuilderFactory)
+	unsignedTxBuilder := unsignedTxBuilderFactory.CreateUnsignedTxBuilder(tse.ConstructionType, tse.Cfg, inputOperations, outputOperations, metadata)
+	if unsignedTxBuilder == nil {
+		return 0, fmt.Errorf("unsupported construction type: %s", tse.ConstructionType)
 	}
-	txSize += ckb.SerializedOffsetByteSize
-
-	return txSize, nil
-}
-
-type Secp256k1TxSizeEstimator struct {
-	TxSizeEstimator
-}
-
-func NewSecp256k1TxSizeEstimator() *Secp256k1TxSizeEstimator {
-	tes := Secp256k1TxSizeEstimator{}
-	tes.TxSizeEstimator.HeaderDepsSize = tes.HeaderDepsSize
-	tes.TxSizeEstimator.CellDepsSize = tes.CellDepsSize
-	tes.TxSizeEstimator.WitnessSize = tes.WitnessSize
-	tes.TxSizeEstimator.OutputSize = tes.OutputSize
-	tes.TxSizeEstimator.OutputDataSize = tes.OutputDataSize
-	return &tes
-}
-
-func (tse Secp256k1TxSizeEstimator) HeaderDepsSize() uint64 {
-	return 0
-}
-
-func (tse Secp256k1TxSizeEstimator) CellDepsSize() uint64 {
-	return ckb.CellDepSize
-}
-
-func (tse Secp256k1TxSizeEstimator) WitnessSize(i int) uint64 {
-	var witnessBytes []byte
-	if i == 0 {
-		witnessBytes, _ = transaction.EmptyWitnessArg.Serialize()
-		witnessBytes = ckbTypes.SerializeBytes(witnessBytes)
-	}
-	size := uint64(len(witnessBytes)) + ckb.SerializedOffsetByteSize
-
-	return size
-}
-
-func (tse Secp256k1TxSizeEstimator) OutputSize(operation *types.Operation) (uint64, error) {
-	parsedAddress, err := address.Parse(operation.Account.Address)
+	tx, err := unsignedTxBuilder.Build()
 	if err != nil {
 		return 0, err
 	}
-	cellOutput := ckbTypes.CellOutput{
-		Capacity: 0,
-		Lock:     parsedAddress.Script,
-		Type:     nil,
-	}
-	serializedCellOutput, _ := cellOutput.Serialize()
-	var outputSize uint64
-	outputSize = uint64(len(serializedCellOutput)) + ckb.SerializedOffsetByteSize
-
-	return outputSize, nil
-}
-
-func (tse Secp256k1TxSizeEstimator) OutputDataSize(data string) (uint64, error) {
-	var dataBytes []byte
-	var err error
-	if data != "" {
-		dataBytes, err = hex.DecodeString(data)
-		if err != nil {
-			return 0, err
-		}
+	serializedTx, err := builder.SerializeTransaction(tx)
+	if err != nil {
+		return 0, err
 	}
-	serializedDataBytes := ckbTypes.SerializeBytes(dataBytes)
-	outputDataSize := uint64(len(serializedDataBytes)) + ckb.SerializedOffsetByteSize
-
-	return outputDataSize, nil
+	// the transaction is priced as an item of the transactions of its block
+	return uint64(len(serializedTx)) + ckb.SerializedOffsetByteSize, nil
 }
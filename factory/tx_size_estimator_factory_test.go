@@ -0,0 +1,166 @@
+package factory
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+	"gopkg.in/yaml.v2"
+)
+
+// testConfigYAML holds the mainnet scripts of the transfers and DAO transactions the estimator builds
+const testConfigYAML = `
+network: Mainnet
+secp256k1Blake160:
+  deps:
+    - txHash: 0xf8de3bb47d055cdf460d93a2a6e1b05f7432f9777c8c474abf4eec1d4aee5d37
+      index: 0
+      depType: dep_group
+  script:
+    codeHash: 0x9bd7e06f3ecf4be0f2fcd2188b23f1b9fcc88e5d4b65a8637b17723bbda3cce8
+    hashType: type
+dao:
+  deps:
+    - txHash: 0xe2fb199810d49a4d8beec56718ba2593b665db9d52299a0f9e6e75416d73ff5c
+      index: 2
+      depType: code
+  script:
+    codeHash: 0x82d76d1b75fe2fd9a27dfbaa65a039221a380d76c926f378d3f81cf3e7e13f2e
+    hashType: type
+`
+
+func TestTxSizeEstimatorEstimatedTxSize(t *testing.T) {
+	cfg := &config.Config{}
+	if err := yaml.Unmarshal([]byte(testConfigYAML), cfg); err != nil {
+		t.Fatal(err)
+	}
+	currency := &types.Currency{Symbol: ckb.CkbSymbol, Decimals: 8}
+	sighashAddress := func(i byte) string {
+		addr, err := address.Generate(address.Mainnet, &ckbTypes.Script{
+			CodeHash: ckbTypes.HexToHash(transaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+			HashType: ckbTypes.HashTypeType,
+			Args:     bytes.Repeat([]byte{i}, 20),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+	input := func(i int, addr string, metadata map[string]interface{}) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+			Type:                ckb.InputOpType,
+			Account:             &types.AccountIdentifier{Address: addr},
+			Amount:              &types.Amount{Value: "-10000000000", Currency: currency},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: fmt.Sprintf("0x%064x:%d", i+1, i)},
+				CoinAction:     types.CoinSpent,
+			},
+			Metadata: metadata,
+		}
+	}
+	output := func(addr string, metadata map[string]interface{}) *types.Operation {
+		return &types.Operation{
+			Type:     ckb.OutputOpType,
+			Account:  &types.AccountIdentifier{Address: addr},
+			Amount:   &types.Amount{Value: "10000000000", Currency: currency},
+			Metadata: metadata,
+		}
+	}
+	metadata := func(metadata *ckb.OperationMetadata) map[string]interface{} {
+		m, err := types.MarshalMap(metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	deposit := metadata(&ckb.OperationMetadata{CellType: ckb.DAODepositCell.String()})
+
+	tests := []struct {
+		name             string
+		constructionType string
+		operations       []*types.Operation
+		metadata         *ckb.ConstructionMetadata
+		want             uint64
+	}{
+		{
+			name:             "one input and two outputs",
+			constructionType: ckb.TransferCKB,
+			operations:       []*types.Operation{input(0, sighashAddress(1), nil), output(sighashAddress(2), nil), output(sighashAddress(1), nil)},
+			want:             464,
+		},
+		{
+			name:             "three inputs in two lock groups and one output",
+			constructionType: ckb.TransferCKB,
+			operations: []*types.Operation{
+				input(0, sighashAddress(1), nil),
+				input(1, sighashAddress(2), nil),
+				input(2, sighashAddress(1), nil),
+				output(sighashAddress(3), nil),
+			},
+			want: 544,
+		},
+		{
+			name:             "DAO deposit",
+			constructionType: ckb.DepositDAO,
+			operations:       []*types.Operation{input(0, sighashAddress(1), nil), output(sighashAddress(1), deposit), output(sighashAddress(1), nil)},
+			want:             562,
+		},
+		{
+			name:             "DAO deposit operation",
+			constructionType: ckb.DepositDAO,
+			operations: []*types.Operation{
+				input(0, sighashAddress(1), nil),
+				{Type: ckb.DAODepositOpType, Account: &types.AccountIdentifier{Address: sighashAddress(1)}, Amount: &types.Amount{Value: "10000000000", Currency: currency}},
+				output(sighashAddress(1), nil),
+			},
+			want: 562,
+		},
+		{
+			name:             "DAO withdraw",
+			constructionType: ckb.WithdrawDAO,
+			operations:       []*types.Operation{input(0, sighashAddress(1), deposit), input(1, sighashAddress(1), nil), output(sighashAddress(1), metadata(&ckb.OperationMetadata{CellType: ckb.DAOWithdrawingCell.String()}))},
+			metadata: &ckb.ConstructionMetadata{
+				HeaderDeps: []string{ckbTypes.Hash{}.String()},
+				DAOInputs:  []*ckb.DAOInput{{CoinIdentifier: fmt.Sprintf("0x%064x:%d", 1, 0), Capacity: "10000000000"}},
+			},
+			want: 537,
+		},
+		{
+			name:             "DAO withdraw counts every header dep",
+			constructionType: ckb.WithdrawDAO,
+			operations:       []*types.Operation{input(0, sighashAddress(1), deposit), input(1, sighashAddress(1), nil), output(sighashAddress(1), metadata(&ckb.OperationMetadata{CellType: ckb.DAOWithdrawingCell.String()}))},
+			metadata: &ckb.ConstructionMetadata{
+				HeaderDeps: []string{ckbTypes.Hash{}.String(), ckbTypes.Hash{}.String()},
+				DAOInputs:  []*ckb.DAOInput{{CoinIdentifier: fmt.Sprintf("0x%064x:%d", 1, 0), Capacity: "10000000000"}},
+			},
+			want: 537 + 32,
+		},
+	}
+	if (TxSizeEstimatorFactory{}).CreateTxSizeEstimator("Unknown", cfg) != nil {
+		t.Error("CreateTxSizeEstimator() of an unknown construction type is not nil")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := tt.metadata
+			if metadata == nil {
+				metadata = &ckb.ConstructionMetadata{}
+			}
+			metadata.ConstructionType = tt.constructionType
+			estimator := TxSizeEstimatorFactory{}.CreateTxSizeEstimator(tt.constructionType, cfg)
+			got, err := estimator.EstimatedTxSize(tt.operations, metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("EstimatedTxSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
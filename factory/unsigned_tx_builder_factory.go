@@ -9,10 +9,20 @@ import (
 
 type UnsignedTxBuilderFactory struct{}
 
-func (f UnsignedTxBuilderFactory) CreateUnsignedTxBuilder(constructionType string, cfg *config.Config, inputOperations []*types.Operation, outputOperations []*types.Operation) builder.UnsignedTxBuilder {
+func (f UnsignedTxBuilderFactory) CreateUnsignedTxBuilder(constructionType string, cfg *config.Config, inputOperations []*types.Operation, outputOperations []*types.Operation, metadata *ckb.ConstructionMetadata) builder.UnsignedTxBuilder {
 	switch constructionType {
 	case ckb.TransferCKB:
 		return builder.NewUnsignedTxBuilderSecp256k1(cfg, inputOperations, outputOperations)
+	case ckb.TransferCKBFromMultisig:
+		return builder.NewUnsignedTxBuilderSecp256k1Multisig(cfg, inputOperations, outputOperations)
+	case ckb.TransferUDT:
+		return builder.NewUnsignedTxBuilderUDT(cfg, inputOperations, outputOperations)
+	case ckb.TopUpACP:
+		return builder.NewUnsignedTxBuilderACP(cfg, inputOperations, outputOperations)
+	case ckb.GenericCell:
+		return builder.NewUnsignedTxBuilderGenericCell(cfg, inputOperations, outputOperations, metadata)
+	case ckb.DepositDAO, ckb.WithdrawDAO, ckb.UnlockDAO:
+		return builder.NewUnsignedTxBuilderDAO(cfg, inputOperations, outputOperations, metadata)
 	default:
 		return nil
 	}
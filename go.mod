@@ -9,5 +9,6 @@ require (
 	github.com/ethereum/go-ethereum v1.9.21
 	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/nervosnetwork/ckb-sdk-go v0.0.0-20200921070645-0b9f312327c4
+	go.etcd.io/bbolt v1.3.5
 	gopkg.in/yaml.v2 v2.3.0
 )
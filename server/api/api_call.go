@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// CallAPIServicer defines the api actions for the CallAPI service.
+type CallAPIServicer interface {
+	Call(context.Context, *CallRequest) (*CallResponse, *types.Error)
+}
+
+// CallAPIController binds the /call endpoint to a CallAPIServicer, rosetta-sdk-go does not ship the Call API
+// yet.
+type CallAPIController struct {
+	service  CallAPIServicer
+	asserter *asserter.Asserter
+}
+
+// NewCallAPIController creates a default api controller
+func NewCallAPIController(
+	s CallAPIServicer,
+	asserter *asserter.Asserter,
+) server.Router {
+	return &CallAPIController{
+		service:  s,
+		asserter: asserter,
+	}
+}
+
+// Routes returns all of the api route for the CallAPIController
+func (c *CallAPIController) Routes() server.Routes {
+	return server.Routes{
+		{
+			Name:        "Call",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/call",
+			HandlerFunc: c.Call,
+		},
+	}
+}
+
+// Call - Make a Network-Specific Procedure Call
+func (c *CallAPIController) Call(w http.ResponseWriter, r *http.Request) {
+	callRequest := &CallRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&callRequest); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	// Assert that CallRequest is correct
+	if err := c.assertCallRequest(callRequest); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	result, serviceErr := c.service.Call(r.Context(), callRequest)
+	if serviceErr != nil {
+		server.EncodeJSONResponse(serviceErr, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	server.EncodeJSONResponse(result, http.StatusOK, w)
+}
+
+func (c *CallAPIController) assertCallRequest(request *CallRequest) error {
+	if err := c.asserter.ValidSupportedNetwork(request.NetworkIdentifier); err != nil {
+		return err
+	}
+	if request.Method == "" {
+		return errors.New("method is missing")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// EventsAPIServicer defines the api actions for the EventsAPI service.
+type EventsAPIServicer interface {
+	EventsBlocks(context.Context, *EventsBlocksRequest) (*EventsBlocksResponse, *types.Error)
+}
+
+// EventsAPIController binds the /events endpoints to an EventsAPIServicer, rosetta-sdk-go does not ship
+// the Events API yet.
+type EventsAPIController struct {
+	service  EventsAPIServicer
+	asserter *asserter.Asserter
+}
+
+// NewEventsAPIController creates a default api controller
+func NewEventsAPIController(
+	s EventsAPIServicer,
+	asserter *asserter.Asserter,
+) server.Router {
+	return &EventsAPIController{
+		service:  s,
+		asserter: asserter,
+	}
+}
+
+// Routes returns all of the api route for the EventsAPIController
+func (c *EventsAPIController) Routes() server.Routes {
+	return server.Routes{
+		{
+			Name:        "EventsBlocks",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/events/blocks",
+			HandlerFunc: c.EventsBlocks,
+		},
+	}
+}
+
+// EventsBlocks - [INDEXER] Get a range of BlockEvents
+func (c *EventsAPIController) EventsBlocks(w http.ResponseWriter, r *http.Request) {
+	eventsBlocksRequest := &EventsBlocksRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&eventsBlocksRequest); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	// Assert that EventsBlocksRequest is correct
+	if err := c.assertEventsBlocksRequest(eventsBlocksRequest); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	result, serviceErr := c.service.EventsBlocks(r.Context(), eventsBlocksRequest)
+	if serviceErr != nil {
+		server.EncodeJSONResponse(serviceErr, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	server.EncodeJSONResponse(result, http.StatusOK, w)
+}
+
+func (c *EventsAPIController) assertEventsBlocksRequest(request *EventsBlocksRequest) error {
+	if err := c.asserter.ValidSupportedNetwork(request.NetworkIdentifier); err != nil {
+		return err
+	}
+	if request.Offset != nil && *request.Offset < 0 {
+		return errors.New("offset is negative")
+	}
+	if request.Limit != nil && *request.Limit < 0 {
+		return errors.New("limit is negative")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+// SearchAPIServicer defines the api actions for the SearchAPI service.
+type SearchAPIServicer interface {
+	SearchTransactions(context.Context, *SearchTransactionsRequest) (*SearchTransactionsResponse, *types.Error)
+}
+
+// SearchAPIController binds the /search endpoints to a SearchAPIServicer, rosetta-sdk-go does not ship
+// the Search API yet.
+type SearchAPIController struct {
+	service  SearchAPIServicer
+	asserter *asserter.Asserter
+}
+
+// NewSearchAPIController creates a default api controller
+func NewSearchAPIController(
+	s SearchAPIServicer,
+	asserter *asserter.Asserter,
+) server.Router {
+	return &SearchAPIController{
+		service:  s,
+		asserter: asserter,
+	}
+}
+
+// Routes returns all of the api route for the SearchAPIController
+func (c *SearchAPIController) Routes() server.Routes {
+	return server.Routes{
+		{
+			Name:        "SearchTransactions",
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "/search/transactions",
+			HandlerFunc: c.SearchTransactions,
+		},
+	}
+}
+
+// SearchTransactions - [INDEXER] Search for Transactions
+func (c *SearchAPIController) SearchTransactions(w http.ResponseWriter, r *http.Request) {
+	searchTransactionsRequest := &SearchTransactionsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&searchTransactionsRequest); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	// Assert that SearchTransactionsRequest is correct
+	if err := c.assertSearchTransactionsRequest(searchTransactionsRequest); err != nil {
+		server.EncodeJSONResponse(&types.Error{
+			Message: err.Error(),
+		}, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	result, serviceErr := c.service.SearchTransactions(r.Context(), searchTransactionsRequest)
+	if serviceErr != nil {
+		server.EncodeJSONResponse(serviceErr, http.StatusInternalServerError, w)
+
+		return
+	}
+
+	server.EncodeJSONResponse(result, http.StatusOK, w)
+}
+
+func (c *SearchAPIController) assertSearchTransactionsRequest(request *SearchTransactionsRequest) error {
+	if err := c.asserter.ValidSupportedNetwork(request.NetworkIdentifier); err != nil {
+		return err
+	}
+	if request.AccountIdentifier == nil && request.CoinIdentifier == nil && request.TransactionIdentifier == nil {
+		return errors.New("one of account_identifier, coin_identifier and transaction_identifier is required")
+	}
+	if request.AccountIdentifier != nil {
+		if err := asserter.AccountIdentifier(request.AccountIdentifier); err != nil {
+			return err
+		}
+	}
+	if request.CoinIdentifier != nil {
+		if err := asserter.CoinIdentifier(request.CoinIdentifier); err != nil {
+			return err
+		}
+	}
+	if request.TransactionIdentifier != nil {
+		if err := asserter.TransactionIdentifier(request.TransactionIdentifier); err != nil {
+			return err
+		}
+	}
+	if request.MaxBlock != nil && *request.MaxBlock < 0 {
+		return errors.New("max_block is negative")
+	}
+	if request.Offset != nil && *request.Offset < 0 {
+		return errors.New("offset is negative")
+	}
+	if request.Limit != nil && *request.Limit <= 0 {
+		return errors.New("limit is not positive")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/asserter"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+func TestAssertSearchTransactionsRequest(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}
+	serverAsserter, err := asserter.NewServer([]string{"INPUT"}, true, []*types.NetworkIdentifier{network})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &SearchAPIController{asserter: serverAsserter}
+	value := func(value int64) *int64 {
+		return &value
+	}
+	transaction := &types.TransactionIdentifier{Hash: "0x01"}
+
+	tests := []struct {
+		name    string
+		request *SearchTransactionsRequest
+		wantErr bool
+	}{
+		{name: "transaction", request: &SearchTransactionsRequest{NetworkIdentifier: network, TransactionIdentifier: transaction}},
+		{name: "no filter", request: &SearchTransactionsRequest{NetworkIdentifier: network}, wantErr: true},
+		{name: "limit", request: &SearchTransactionsRequest{NetworkIdentifier: network, TransactionIdentifier: transaction, Limit: value(1)}},
+		{name: "zero limit", request: &SearchTransactionsRequest{NetworkIdentifier: network, TransactionIdentifier: transaction, Limit: value(0)}, wantErr: true},
+		{name: "negative limit", request: &SearchTransactionsRequest{NetworkIdentifier: network, TransactionIdentifier: transaction, Limit: value(-1)}, wantErr: true},
+		{name: "zero offset", request: &SearchTransactionsRequest{NetworkIdentifier: network, TransactionIdentifier: transaction, Offset: value(0)}},
+		{name: "negative offset", request: &SearchTransactionsRequest{NetworkIdentifier: network, TransactionIdentifier: transaction, Offset: value(-1)}, wantErr: true},
+		{name: "negative max block", request: &SearchTransactionsRequest{NetworkIdentifier: network, TransactionIdentifier: transaction, MaxBlock: value(-1)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.assertSearchTransactionsRequest(tt.request); (err != nil) != tt.wantErr {
+				t.Fatalf("assertSearchTransactionsRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import "github.com/coinbase/rosetta-sdk-go/types"
+
+// BlockEventType is the type of a BlockEvent, BlockAdded or BlockRemoved.
+type BlockEventType string
+
+const (
+	BlockAdded   BlockEventType = "block_added"
+	BlockRemoved BlockEventType = "block_removed"
+)
+
+// BlockEvent records a block added to or removed from the canonical chain at Sequence.
+type BlockEvent struct {
+	Sequence        int64                  `json:"sequence"`
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	Type            BlockEventType         `json:"type"`
+}
+
+// EventsBlocksRequest is used to poll for BlockEvents, events are returned from Offset when it is set,
+// otherwise the last Limit events are returned.
+type EventsBlocksRequest struct {
+	NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	Offset            *int64                   `json:"offset,omitempty"`
+	Limit             *int64                   `json:"limit,omitempty"`
+}
+
+// EventsBlocksResponse contains the BlockEvents and the largest sequence known.
+type EventsBlocksResponse struct {
+	MaxSequence int64         `json:"max_sequence"`
+	Events      []*BlockEvent `json:"events"`
+}
+
+// SearchTransactionsRequest searches the committed transactions touching AccountIdentifier or CoinIdentifier,
+// or the transaction of TransactionIdentifier, the filters are combined with AND.
+type SearchTransactionsRequest struct {
+	NetworkIdentifier     *types.NetworkIdentifier     `json:"network_identifier"`
+	MaxBlock              *int64                       `json:"max_block,omitempty"`
+	Offset                *int64                       `json:"offset,omitempty"`
+	Limit                 *int64                       `json:"limit,omitempty"`
+	TransactionIdentifier *types.TransactionIdentifier `json:"transaction_identifier,omitempty"`
+	AccountIdentifier     *types.AccountIdentifier     `json:"account_identifier,omitempty"`
+	CoinIdentifier        *types.CoinIdentifier        `json:"coin_identifier,omitempty"`
+}
+
+// BlockTransaction contains a transaction and the block it was committed in.
+type BlockTransaction struct {
+	BlockIdentifier *types.BlockIdentifier `json:"block_identifier"`
+	Transaction     *types.Transaction     `json:"transaction"`
+}
+
+// SearchTransactionsResponse contains one page of the matching transactions, newest first. NextOffset is
+// omitted on the last page. TotalCount is the number of matches of all pages.
+type SearchTransactionsResponse struct {
+	Transactions []*BlockTransaction `json:"transactions"`
+	TotalCount   int64               `json:"total_count"`
+	NextOffset   *int64              `json:"next_offset,omitempty"`
+}
+
+// CallRequest invokes the network-specific procedure Method with Parameters.
+type CallRequest struct {
+	NetworkIdentifier *types.NetworkIdentifier `json:"network_identifier"`
+	Method            string                   `json:"method"`
+	Parameters        map[string]interface{}   `json:"parameters"`
+}
+
+// CallResponse contains the result of the procedure, Idempotent is false when calling it again may return a
+// different result.
+type CallResponse struct {
+	Result     map[string]interface{} `json:"result"`
+	Idempotent bool                   `json:"idempotent"`
+}
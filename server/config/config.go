@@ -1,15 +1,43 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	Port              uint   `yaml:"port"`
-	Network           string `yaml:"network"`
-	RichNodeRpc       string `yaml:"rich_node_rpc"`
+	// Offline serves the construction endpoints without connecting to a node
+	Offline bool `yaml:"offline"`
+	Port    uint `yaml:"port"`
+	// Address is the host to bind, all interfaces are bound when it is empty
+	Address string `yaml:"address"`
+	TLS     struct {
+		CertFile string `yaml:"cert_file"`
+		KeyFile  string `yaml:"key_file"`
+	} `yaml:"tls"`
+	// Timeouts of the http server, zero means no timeout
+	Timeouts struct {
+		Read     time.Duration `yaml:"read"`
+		Write    time.Duration `yaml:"write"`
+		Idle     time.Duration `yaml:"idle"`
+		Shutdown time.Duration `yaml:"shutdown"`
+	} `yaml:"timeouts"`
+	// Networks hosts several networks in one server, each with its own rpc and script config
+	Networks    []*Config `yaml:"networks"`
+	Network     string    `yaml:"network"`
+	RichNodeRpc string    `yaml:"rich_node_rpc"`
+	// NodeRpc and IndexerRpc override the /rpc and /indexer endpoints of RichNodeRpc
+	NodeRpc           string `yaml:"node_rpc"`
+	IndexerRpc        string `yaml:"indexer_rpc"`
 	Secp256k1Blake160 struct {
 		Deps []struct {
 			TxHash  string `yaml:"txHash"`
@@ -43,6 +71,17 @@ type Config struct {
 			HashType string `yaml:"hashType"`
 		} `yaml:"script"`
 	} `yaml:"acp"`
+	DAO struct {
+		Deps []struct {
+			TxHash  string `yaml:"txHash"`
+			Index   uint   `yaml:"index"`
+			DepType string `yaml:"depType"`
+		} `yaml:"deps"`
+		Script struct {
+			CodeHash string `yaml:"codeHash"`
+			HashType string `yaml:"hashType"`
+		} `yaml:"script"`
+	} `yaml:"dao"`
 	UDT struct {
 		Deps []struct {
 			TxHash  string `yaml:"txHash"`
@@ -53,11 +92,37 @@ type Config struct {
 			CodeHash string `yaml:"codeHash"`
 			HashType string `yaml:"hashType"`
 		} `yaml:"script"`
+		// Tokens is keyed by the sUDT type script args
 		Tokens map[string]struct {
 			Symbol  string `yaml:"symbol"`
 			Decimal int    `yaml:"decimal"`
 		} `yaml:"tokens"`
 	} `yaml:"udt"`
+	// Cache keeps rendered blocks at least Confirmations deep and the previous outputs of inputs,
+	// Concurrency limits the parallel batches resolving inputs
+	Cache struct {
+		Blocks        int    `yaml:"blocks"`
+		Outputs       int    `yaml:"outputs"`
+		Confirmations uint64 `yaml:"confirmations"`
+		Concurrency   int    `yaml:"concurrency"`
+	} `yaml:"cache"`
+	// DryRunOnSubmit verifies transactions with dry_run_transaction before /construction/submit sends them, unknown
+	// cells are left to the node because the dry run does not see the tx pool
+	DryRunOnSubmit bool `yaml:"dry_run_on_submit"`
+	// FeeRate bounds the fee rate suggested from the tx pool, rates are in shannons/KB
+	FeeRate struct {
+		Percentile float64 `yaml:"percentile"`
+		Floor      uint64  `yaml:"floor"`
+		Cap        uint64  `yaml:"cap"`
+	} `yaml:"feeRate"`
+	// Events follows the tip every PollInterval and records the blocks added and removed in DataDir,
+	// an empty event log starts at StartHeight
+	Events struct {
+		Enabled      bool          `yaml:"enabled"`
+		DataDir      string        `yaml:"data_dir"`
+		PollInterval time.Duration `yaml:"poll_interval"`
+		StartHeight  uint64        `yaml:"start_height"`
+	} `yaml:"events"`
 }
 
 func Init(path string) (*Config, error) {
@@ -71,6 +136,95 @@ func Init(path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, network := range append([]*Config{&c}, c.Networks...) {
+		if err := network.validate(); err != nil {
+			return nil, err
+		}
+	}
 
 	return &c, nil
 }
+
+// validate rejects settings that would otherwise only fail on the request path
+func (c *Config) validate() error {
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+	symbols := make(map[string]string)
+	for typeArgs, token := range c.UDT.Tokens {
+		if other, ok := symbols[token.Symbol]; ok {
+			return fmt.Errorf("udt token symbol %s is configured for both %s and %s", token.Symbol, other, typeArgs)
+		}
+		symbols[token.Symbol] = typeArgs
+	}
+	// percentile 0 leaves the suggested fee rate at the median
+	if c.FeeRate.Percentile < 0 || c.FeeRate.Percentile > 100 {
+		return fmt.Errorf("feeRate.percentile %v must be within [0, 100]", c.FeeRate.Percentile)
+	}
+	if c.FeeRate.Cap > 0 && c.FeeRate.Floor > c.FeeRate.Cap {
+		return fmt.Errorf("feeRate.floor %d is above feeRate.cap %d", c.FeeRate.Floor, c.FeeRate.Cap)
+	}
+	return nil
+}
+
+// NetworkConfigs returns the config of every hosted network, a config without networks hosts its own network.
+// The server settings are inherited from the top level config, so are the cache, feeRate, events, udt, acp and
+// dao sections a network leaves empty. An inherited events section keeps the default data dir of the network.
+func (c *Config) NetworkConfigs() []*Config {
+	if len(c.Networks) == 0 {
+		return []*Config{c}
+	}
+	for _, network := range c.Networks {
+		network.Offline = c.Offline
+		inherit(&network.Cache, &c.Cache)
+		inherit(&network.FeeRate, &c.FeeRate)
+		if inherit(&network.Events, &c.Events) {
+			network.Events.DataDir = ""
+		}
+		inherit(&network.UDT, &c.UDT)
+		inherit(&network.ACP, &c.ACP)
+		inherit(&network.DAO, &c.DAO)
+	}
+	return c.Networks
+}
+
+// inherit copies the section src points to into dst when dst is the zero value and reports whether it copied
+func inherit(dst interface{}, src interface{}) bool {
+	section := reflect.ValueOf(dst).Elem()
+	if !section.IsZero() {
+		return false
+	}
+	section.Set(reflect.ValueOf(src).Elem())
+	return true
+}
+
+func (c *Config) NodeRpcUrl() string {
+	if c.NodeRpc != "" {
+		return c.NodeRpc
+	}
+	return strings.TrimSuffix(c.RichNodeRpc, "/") + "/rpc"
+}
+
+func (c *Config) IndexerRpcUrl() string {
+	if c.IndexerRpc != "" {
+		return c.IndexerRpc
+	}
+	return strings.TrimSuffix(c.RichNodeRpc, "/") + "/indexer"
+}
+
+// ListenAddr joins the address and port, an IPv6 address is bracketed
+func (c *Config) ListenAddr() string {
+	return net.JoinHostPort(c.Address, strconv.FormatUint(uint64(c.Port), 10))
+}
+
+func (c *Config) TLSEnabled() bool {
+	return c.TLS.CertFile != "" && c.TLS.KeyFile != ""
+}
+
+// EventsDataDir defaults to data/<network> so hosted networks do not share an event log
+func (c *Config) EventsDataDir() string {
+	if c.Events.DataDir != "" {
+		return c.Events.DataDir
+	}
+	return filepath.Join("data", strings.ToLower(c.Network))
+}
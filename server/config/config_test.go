@@ -0,0 +1,199 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{
+			name: "distinct token symbols",
+			config: `
+udt:
+  tokens:
+    '0x01':
+      symbol: AAA
+      decimal: 8
+    '0x02':
+      symbol: BBB
+      decimal: 8
+`,
+		},
+		{
+			name: "duplicated token symbol",
+			config: `
+udt:
+  tokens:
+    '0x01':
+      symbol: AAA
+      decimal: 8
+    '0x02':
+      symbol: AAA
+      decimal: 6
+`,
+			wantErr: true,
+		},
+		{name: "tls", config: "tls:\n  cert_file: cert.pem\n  key_file: key.pem\n"},
+		{name: "tls without key file", config: "tls:\n  cert_file: cert.pem\n", wantErr: true},
+		{name: "tls without cert file", config: "tls:\n  key_file: key.pem\n", wantErr: true},
+		{name: "unset fee rate percentile", config: "feeRate:\n  percentile: 0\n"},
+		{name: "fee rate percentile", config: "feeRate:\n  percentile: 99.5\n"},
+		{name: "negative fee rate percentile", config: "feeRate:\n  percentile: -1\n", wantErr: true},
+		{name: "fee rate percentile above 100", config: "feeRate:\n  percentile: 150\n", wantErr: true},
+		{name: "fee rate floor below cap", config: "feeRate:\n  floor: 1000\n  cap: 2000\n"},
+		{name: "fee rate floor without cap", config: "feeRate:\n  floor: 1000\n"},
+		{name: "fee rate floor above cap", config: "feeRate:\n  floor: 3000\n  cap: 2000\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+			if err := yaml.Unmarshal([]byte(tt.config), &c); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		port    uint
+		want    string
+	}{
+		{name: "all interfaces", port: 8080, want: ":8080"},
+		{name: "IPv4", address: "127.0.0.1", port: 8080, want: "127.0.0.1:8080"},
+		{name: "host name", address: "localhost", port: 8080, want: "localhost:8080"},
+		{name: "IPv6", address: "::1", port: 8080, want: "[::1]:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Address: tt.address, Port: tt.port}
+			if got := c.ListenAddr(); got != tt.want {
+				t.Errorf("ListenAddr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	if _, err := Init("../config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNetworkConfigs(t *testing.T) {
+	var c Config
+	if err := yaml.Unmarshal([]byte(`
+offline: true
+network: Mainnet
+cache:
+  blocks: 100
+feeRate:
+  percentile: 75
+events:
+  enabled: true
+  data_dir: events
+udt:
+  script:
+    codeHash: '0x01'
+acp:
+  script:
+    codeHash: '0x02'
+dao:
+  script:
+    codeHash: '0x03'
+networks:
+  - network: Mainnet
+  - network: Testnet
+    cache:
+      blocks: 10
+    feeRate:
+      percentile: 50
+    events:
+      poll_interval: 2s
+    udt:
+      script:
+        codeHash: '0x11'
+    acp:
+      script:
+        codeHash: '0x12'
+    dao:
+      script:
+        codeHash: '0x13'
+`), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	networks := c.NetworkConfigs()
+	if len(networks) != 2 {
+		t.Fatalf("NetworkConfigs() = %d networks, want 2", len(networks))
+	}
+	tests := []struct {
+		name           string
+		network        *Config
+		wantBlocks     int
+		wantPercentile float64
+		wantEvents     bool
+		wantDataDir    string
+		wantUDT        string
+		wantACP        string
+		wantDAO        string
+	}{
+		{
+			name:           "inherited sections",
+			network:        networks[0],
+			wantBlocks:     100,
+			wantPercentile: 75,
+			wantEvents:     true,
+			wantDataDir:    "data/mainnet",
+			wantUDT:        "0x01",
+			wantACP:        "0x02",
+			wantDAO:        "0x03",
+		},
+		{
+			name:           "own sections",
+			network:        networks[1],
+			wantBlocks:     10,
+			wantPercentile: 50,
+			wantEvents:     false,
+			wantDataDir:    "data/testnet",
+			wantUDT:        "0x11",
+			wantACP:        "0x12",
+			wantDAO:        "0x13",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := tt.network
+			if !n.Offline {
+				t.Error("Offline is not inherited")
+			}
+			if n.Cache.Blocks != tt.wantBlocks {
+				t.Errorf("Cache.Blocks = %d, want %d", n.Cache.Blocks, tt.wantBlocks)
+			}
+			if n.FeeRate.Percentile != tt.wantPercentile {
+				t.Errorf("FeeRate.Percentile = %v, want %v", n.FeeRate.Percentile, tt.wantPercentile)
+			}
+			if n.Events.Enabled != tt.wantEvents {
+				t.Errorf("Events.Enabled = %v, want %v", n.Events.Enabled, tt.wantEvents)
+			}
+			if n.EventsDataDir() != tt.wantDataDir {
+				t.Errorf("EventsDataDir() = %s, want %s", n.EventsDataDir(), tt.wantDataDir)
+			}
+			if n.UDT.Script.CodeHash != tt.wantUDT || n.ACP.Script.CodeHash != tt.wantACP || n.DAO.Script.CodeHash != tt.wantDAO {
+				t.Errorf("script code hashes = %s %s %s, want %s %s %s", n.UDT.Script.CodeHash, n.ACP.Script.CodeHash,
+					n.DAO.Script.CodeHash, tt.wantUDT, tt.wantACP, tt.wantDAO)
+			}
+		})
+	}
+}
@@ -1,74 +1,231 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
-	"github.com/nervosnetwork/ckb-sdk-go/rpc"
 
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/server/services"
 )
 
 func NewBlockchainRouter(
-	network *types.NetworkIdentifier,
 	asserter *asserter.Asserter,
-	client rpc.Client,
-	cfg *config.Config,
+	service *services.MultiNetworkAPIService,
 ) http.Handler {
-	networkAPIService := services.NewNetworkAPIService(network, client, cfg)
 	networkAPIController := server.NewNetworkAPIController(
-		networkAPIService,
+		service,
+		asserter,
+	)
+
+	blockAPIController := server.NewBlockAPIController(
+		service,
+		asserter,
+	)
+
+	accountAPIController := server.NewAccountAPIController(
+		service,
+		asserter,
+	)
+
+	constructionAPIController := server.NewConstructionAPIController(
+		service,
+		asserter,
+	)
+
+	mempoolAPIController := server.NewMempoolAPIController(
+		service,
+		asserter,
+	)
+
+	eventsAPIController := api.NewEventsAPIController(
+		service,
+		asserter,
+	)
+
+	searchAPIController := api.NewSearchAPIController(
+		service,
+		asserter,
+	)
+
+	callAPIController := api.NewCallAPIController(
+		service,
+		asserter,
+	)
+
+	return server.NewRouter(networkAPIController, blockAPIController, accountAPIController, constructionAPIController, mempoolAPIController, eventsAPIController, searchAPIController, callAPIController)
+}
+
+// NewOfflineRouter serves the construction endpoints which work without a node, the network, block and
+// account endpoints fail with an unavailable in offline mode error.
+func NewOfflineRouter(
+	asserter *asserter.Asserter,
+	service *services.MultiNetworkAPIService,
+) http.Handler {
+	networkAPIController := server.NewNetworkAPIController(
+		service,
 		asserter,
 	)
 
-	blockAPIService := services.NewBlockAPIService(network, client, cfg)
 	blockAPIController := server.NewBlockAPIController(
-		blockAPIService,
+		service,
 		asserter,
 	)
 
-	accountAPIService := services.NewAccountAPIService(network, client, cfg)
 	accountAPIController := server.NewAccountAPIController(
-		accountAPIService,
+		service,
 		asserter,
 	)
 
-	constructionAPIService := services.NewConstructionAPIService(network, client, cfg)
 	constructionAPIController := server.NewConstructionAPIController(
-		constructionAPIService,
+		service,
 		asserter,
 	)
 
 	return server.NewRouter(networkAPIController, blockAPIController, accountAPIController, constructionAPIController)
 }
 
-func main() {
-	cfg, err := config.Init("config.yaml")
+// NewNetworkAPIServices creates the services of one network, the node is not dialed in offline mode.
+// When events are enabled the block events are synced in the background until ctx is done.
+func NewNetworkAPIServices(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	network *types.NetworkIdentifier,
+	cfg *config.Config,
+) (*services.NetworkAPIServices, error) {
+	if cfg.Offline {
+		offlineAPIService := services.NewOfflineAPIService()
+		return &services.NetworkAPIServices{
+			Network:      offlineAPIService,
+			Block:        offlineAPIService,
+			Account:      offlineAPIService,
+			Construction: services.NewConstructionAPIService(network, nil, cfg),
+		}, nil
+	}
+
+	client, err := services.DialWithIndexer(cfg.NodeRpcUrl(), cfg.IndexerRpcUrl())
 	if err != nil {
-		log.Fatalf("initial config error: %v", err)
+		return nil, err
+	}
+	blockAPIService := services.NewBlockAPIService(network, client, cfg)
+	networkAPIServices := &services.NetworkAPIServices{
+		Network:      services.NewNetworkAPIService(network, client, cfg),
+		Block:        blockAPIService,
+		Account:      services.NewAccountAPIService(network, client, cfg),
+		Construction: services.NewConstructionAPIService(network, client, cfg),
+		Mempool:      services.NewMempoolAPIService(network, client, cfg, blockAPIService),
+		Search:       services.NewSearchAPIService(network, client, cfg, blockAPIService),
+		Call:         services.NewCallAPIService(network, client, cfg),
+	}
+	if cfg.Events.Enabled {
+		eventsAPIService, err := services.NewEventsAPIService(network, client, cfg)
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			eventsAPIService.Sync(ctx)
+		}()
+		networkAPIServices.Events = eventsAPIService
 	}
+	return networkAPIServices, nil
+}
+
+const configPathEnv = "CKB_ROSETTA_CONFIG"
 
-	client, err := rpc.DialWithIndexer(cfg.RichNodeRpc+"/rpc", cfg.RichNodeRpc+"/indexer")
+func main() {
+	defaultConfigPath := "config.yaml"
+	if path, ok := os.LookupEnv(configPathEnv); ok {
+		defaultConfigPath = path
+	}
+	configPath := flag.String("config", defaultConfigPath, "path of the config file, also set by $"+configPathEnv)
+	offline := flag.Bool("offline", false, "serve the construction endpoints without connecting to a node")
+	flag.Parse()
+
+	cfg, err := config.Init(*configPath)
 	if err != nil {
-		log.Fatalf("dial rich node rpc error: %v", err)
+		log.Fatalf("initial config error: %v", err)
+	}
+	if *offline {
+		cfg.Offline = true
 	}
 
-	network := &types.NetworkIdentifier{
-		Blockchain: "CKB",
-		Network:    cfg.Network,
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	var syncWg sync.WaitGroup
+	service := services.NewMultiNetworkAPIService()
+	for _, networkCfg := range cfg.NetworkConfigs() {
+		network := &types.NetworkIdentifier{
+			Blockchain: "CKB",
+			Network:    networkCfg.Network,
+		}
+		networkAPIServices, err := NewNetworkAPIServices(syncCtx, &syncWg, network, networkCfg)
+		if err != nil {
+			log.Fatalf("initial services of %s error: %v", networkCfg.Network, err)
+		}
+		if err := service.AddNetwork(network, networkAPIServices); err != nil {
+			log.Fatalf("initial network error: %v", err)
+		}
 	}
 
-	serverAsserter, err := asserter.NewServer(services.SupportedOperationTypes, false, []*types.NetworkIdentifier{network})
+	serverAsserter, err := asserter.NewServer(services.SupportedOperationTypes, true, service.Networks())
 	if err != nil {
 		log.Fatalf("initial server error: %v", err)
 	}
 
-	router := NewBlockchainRouter(network, serverAsserter, client, cfg)
-	log.Printf("Listening on port %d\n", cfg.Port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), router))
+	var router http.Handler
+	if cfg.Offline {
+		router = NewOfflineRouter(serverAsserter, service)
+	} else {
+		router = NewBlockchainRouter(serverAsserter, service)
+	}
+	srv := &http.Server{
+		Addr:         cfg.ListenAddr(),
+		Handler:      router,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
+	}
+
+	go func() {
+		log.Printf("Listening on %s\n", srv.Addr)
+		var err error
+		if cfg.TLSEnabled() {
+			err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("listen error: %v", err)
+		}
+	}()
+
+	// drain in-flight requests on SIGINT or SIGTERM
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+
+	ctx := context.Background()
+	if cfg.Timeouts.Shutdown > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeouts.Shutdown)
+		defer cancel()
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatalf("shutdown error: %v", err)
+	}
+	stopSync()
+	syncWg.Wait()
+	log.Println("Server stopped")
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/services"
+)
+
+func TestNewNetworkAPIServicesOffline(t *testing.T) {
+	network := &types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}
+	// the node url is never dialed in offline mode
+	cfg := &config.Config{Offline: true, NodeRpc: "http://127.0.0.1:0"}
+	networkAPIServices, err := NewNetworkAPIServices(context.Background(), &sync.WaitGroup{}, network, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if networkAPIServices.Construction == nil {
+		t.Fatal("offline mode has no construction service")
+	}
+	if networkAPIServices.Mempool != nil || networkAPIServices.Events != nil || networkAPIServices.Search != nil || networkAPIServices.Call != nil {
+		t.Errorf("offline mode has services which need a node: %+v", networkAPIServices)
+	}
+	service := services.NewMultiNetworkAPIService()
+	if err := service.AddNetwork(network, networkAPIServices); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	networkRequest := &types.NetworkRequest{NetworkIdentifier: network}
+	tests := []struct {
+		name string
+		call func() *types.Error
+	}{
+		{name: "network list", call: func() *types.Error {
+			_, rErr := service.NetworkList(ctx, &types.MetadataRequest{})
+			return rErr
+		}},
+		{name: "network status", call: func() *types.Error {
+			_, rErr := service.NetworkStatus(ctx, networkRequest)
+			return rErr
+		}},
+		{name: "network options", call: func() *types.Error {
+			_, rErr := service.NetworkOptions(ctx, networkRequest)
+			return rErr
+		}},
+		{name: "block", call: func() *types.Error {
+			_, rErr := service.Block(ctx, &types.BlockRequest{NetworkIdentifier: network})
+			return rErr
+		}},
+		{name: "block transaction", call: func() *types.Error {
+			_, rErr := service.BlockTransaction(ctx, &types.BlockTransactionRequest{NetworkIdentifier: network})
+			return rErr
+		}},
+		{name: "account balance", call: func() *types.Error {
+			_, rErr := service.AccountBalance(ctx, &types.AccountBalanceRequest{NetworkIdentifier: network})
+			return rErr
+		}},
+		{name: "construction metadata", call: func() *types.Error {
+			_, rErr := service.ConstructionMetadata(ctx, &types.ConstructionMetadataRequest{NetworkIdentifier: network})
+			return rErr
+		}},
+		{name: "construction submit", call: func() *types.Error {
+			_, rErr := service.ConstructionSubmit(ctx, &types.ConstructionSubmitRequest{NetworkIdentifier: network})
+			return rErr
+		}},
+		{name: "mempool", call: func() *types.Error {
+			_, rErr := service.Mempool(ctx, networkRequest)
+			return rErr
+		}},
+		{name: "search", call: func() *types.Error {
+			_, rErr := service.SearchTransactions(ctx, &api.SearchTransactionsRequest{NetworkIdentifier: network})
+			return rErr
+		}},
+		{name: "call", call: func() *types.Error {
+			_, rErr := service.Call(ctx, &api.CallRequest{NetworkIdentifier: network})
+			return rErr
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rErr := tt.call(); rErr == nil || rErr.Code != services.UnavailableOfflineError.Code {
+				t.Errorf("error = %v, want UnavailableOfflineError", rErr)
+			}
+		})
+	}
+
+	t.Run("construction derive", func(t *testing.T) {
+		response, rErr := service.ConstructionDerive(ctx, &types.ConstructionDeriveRequest{
+			NetworkIdentifier: network,
+			PublicKey:         &types.PublicKey{Bytes: make([]byte, 33), CurveType: types.Secp256k1},
+		})
+		if rErr != nil {
+			t.Fatal(rErr)
+		}
+		if response.AccountIdentifier == nil || response.AccountIdentifier.Address == "" {
+			t.Error("construction derive returned no address")
+		}
+	})
+}
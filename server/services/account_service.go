@@ -2,7 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 
@@ -11,6 +16,7 @@ import (
 	"github.com/nervosnetwork/ckb-sdk-go/address"
 	"github.com/nervosnetwork/ckb-sdk-go/indexer"
 	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
 )
 
 // AccountAPIService implements the server.AccountAPIServicer interface.
@@ -38,51 +44,300 @@ func (s *AccountAPIService) AccountBalance(
 	if err != nil {
 		return nil, AddressParseError
 	}
+
+	var blockIdentifier *types.BlockIdentifier
+	var cells []*indexer.LiveCell
+	var rErr *types.Error
+	if request.BlockIdentifier == nil || (request.BlockIdentifier.Index == nil && request.BlockIdentifier.Hash == nil) {
+		blockIdentifier, cells, rErr = s.getLiveCells(addr.Script)
+	} else {
+		blockIdentifier, cells, rErr = s.getHistoricalCells(addr.Script, request.BlockIdentifier)
+	}
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return s.buildAccountBalance(blockIdentifier, addr.Script, cells)
+}
+
+// getLiveCells collects the live cells of the lock script at the indexer tip, the cells are
+// collected again if the indexer tip moves during pagination.
+func (s *AccountAPIService) getLiveCells(lock *ckbTypes.Script) (*types.BlockIdentifier, []*indexer.LiveCell, *types.Error) {
+	for i := 0; i < ckb.LiveCellsRetryLimit; i++ {
+		tip, err := s.client.GetTip(context.Background())
+		if err != nil {
+			return nil, nil, wrapErr(RpcError, err)
+		}
+
+		var cursor string
+		var cells []*indexer.LiveCell
+		for {
+			liveCells, err := s.client.GetCells(context.Background(), &indexer.SearchKey{
+				Script:     lock,
+				ScriptType: indexer.ScriptTypeLock,
+			}, indexer.SearchOrderAsc, ckb.SearchLimit, cursor)
+			if err != nil {
+				return nil, nil, wrapErr(ServerError, err)
+			}
+			cells = append(cells, liveCells.Objects...)
+			if len(liveCells.Objects) < ckb.SearchLimit || liveCells.LastCursor == "" {
+				break
+			}
+			cursor = liveCells.LastCursor
+		}
+
+		currentTip, err := s.client.GetTip(context.Background())
+		if err != nil {
+			return nil, nil, wrapErr(RpcError, err)
+		}
+		if currentTip.BlockHash == tip.BlockHash {
+			return &types.BlockIdentifier{
+				Index: int64(tip.BlockNumber),
+				Hash:  tip.BlockHash.String(),
+			}, cells, nil
+		}
+	}
+
+	return nil, nil, wrapErr(BlockNotIndexedError, fmt.Errorf("indexer tip kept moving while collecting live cells"))
+}
+
+// getHistoricalCells replays the cells created and consumed by the lock script up to the requested block
+// from the indexer transactions.
+func (s *AccountAPIService) getHistoricalCells(lock *ckbTypes.Script, partialBlockIdentifier *types.PartialBlockIdentifier) (*types.BlockIdentifier, []*indexer.LiveCell, *types.Error) {
+	header, rErr := s.getCanonicalHeader(partialBlockIdentifier)
+	if rErr != nil {
+		return nil, nil, rErr
+	}
+	tip, err := s.client.GetTip(context.Background())
+	if err != nil {
+		return nil, nil, wrapErr(RpcError, err)
+	}
+	if tip.BlockNumber < header.Number {
+		return nil, nil, wrapErr(BlockNotIndexedError, fmt.Errorf("indexer tip %d is lower than block %d", tip.BlockNumber, header.Number))
+	}
+
 	var cursor string
-	var ckbBalance uint64
-	var ckbCoins []*types.Coin
+	var records []*indexer.Transaction
+	txHashes := make(map[ckbTypes.Hash]bool)
 	for {
-		liveCells, err := s.client.GetCells(context.Background(), &indexer.SearchKey{
-			Script:     addr.Script,
+		transactions, err := s.client.GetTransactions(context.Background(), &indexer.SearchKey{
+			Script:     lock,
 			ScriptType: indexer.ScriptTypeLock,
 		}, indexer.SearchOrderAsc, ckb.SearchLimit, cursor)
 		if err != nil {
-			return nil, wrapErr(ServerError, err)
-		}
-		for _, cell := range liveCells.Objects {
-			ckbBalance += cell.Output.Capacity
-			if cell.Output.Type == nil && len(cell.OutputData) == 0 {
-				ckbCoins = append(ckbCoins, &types.Coin{
-					CoinIdentifier: &types.CoinIdentifier{Identifier: fmt.Sprintf("%s:%d", cell.OutPoint.TxHash, cell.OutPoint.Index)},
-					Amount: &types.Amount{
-						Value:    fmt.Sprintf("%d", cell.Output.Capacity),
-						Currency: CkbCurrency,
-					},
-				})
+			return nil, nil, wrapErr(HistoricalBalanceUnavailableError, err)
+		}
+		reachedBlock := false
+		for _, record := range transactions.Objects {
+			if record.BlockNumber > header.Number {
+				reachedBlock = true
+				break
 			}
+			records = append(records, record)
+			txHashes[record.TxHash] = true
 		}
-		if len(liveCells.Objects) < ckb.SearchLimit || liveCells.LastCursor == "" {
+		if reachedBlock || len(transactions.Objects) < ckb.SearchLimit || transactions.LastCursor == "" {
 			break
 		}
-		cursor = liveCells.LastCursor
+		cursor = transactions.LastCursor
 	}
 
-	tipHeader, err := s.client.GetTipHeader(context.Background())
+	txs, err := s.getTransactions(txHashes)
 	if err != nil {
-		return nil, wrapErr(RpcError, err)
+		return nil, nil, wrapErr(RpcError, err)
 	}
 
-	return &types.AccountBalanceResponse{
-		BlockIdentifier: &types.BlockIdentifier{
-			Index: int64(tipHeader.Number),
-			Hash:  tipHeader.Hash.String(),
-		},
-		Balances: []*types.Amount{
-			{
-				Value:    fmt.Sprintf("%d", ckbBalance),
-				Currency: CkbCurrency,
-			},
+	cells := make(map[string]*indexer.LiveCell)
+	var outPoints []string
+	for _, record := range records {
+		tx := txs[record.TxHash]
+		switch record.IoType {
+		case indexer.IOTypeOut:
+			if int(record.IoIndex) >= len(tx.Outputs) {
+				return nil, nil, wrapErr(ServerError, fmt.Errorf("output %d of transaction %s not found", record.IoIndex, record.TxHash.String()))
+			}
+			outPoint := &ckbTypes.OutPoint{
+				TxHash: record.TxHash,
+				Index:  record.IoIndex,
+			}
+			key := getCoinIdentifier(outPoint).Identifier
+			cells[key] = &indexer.LiveCell{
+				BlockNumber: record.BlockNumber,
+				OutPoint:    outPoint,
+				Output:      tx.Outputs[record.IoIndex],
+				OutputData:  tx.OutputsData[record.IoIndex],
+				TxIndex:     record.TxIndex,
+			}
+			outPoints = append(outPoints, key)
+		case indexer.IOTypeIn:
+			if int(record.IoIndex) >= len(tx.Inputs) {
+				return nil, nil, wrapErr(ServerError, fmt.Errorf("input %d of transaction %s not found", record.IoIndex, record.TxHash.String()))
+			}
+			delete(cells, getCoinIdentifier(tx.Inputs[record.IoIndex].PreviousOutput).Identifier)
+		}
+	}
+
+	// the chain may have been reorganized while replaying
+	currentHeader, err := s.client.GetHeaderByNumber(context.Background(), header.Number)
+	if err != nil {
+		return nil, nil, wrapErr(RpcError, err)
+	}
+	if currentHeader.Hash != header.Hash {
+		return nil, nil, wrapErr(BlockNotIndexedError, fmt.Errorf("block %d was reorganized", header.Number))
+	}
+
+	var liveCells []*indexer.LiveCell
+	for _, key := range outPoints {
+		if cell, ok := cells[key]; ok {
+			liveCells = append(liveCells, cell)
+		}
+	}
+
+	return &types.BlockIdentifier{
+		Index: int64(header.Number),
+		Hash:  header.Hash.String(),
+	}, liveCells, nil
+}
+
+// getCanonicalHeader resolves the partial block identifier to a header on the main chain
+func (s *AccountAPIService) getCanonicalHeader(partialBlockIdentifier *types.PartialBlockIdentifier) (*ckbTypes.Header, *types.Error) {
+	var header *ckbTypes.Header
+	var err error
+	if partialBlockIdentifier.Index != nil {
+		if *partialBlockIdentifier.Index < 0 {
+			return nil, wrapErr(BlockNotFoundError, fmt.Errorf("invalid block index %d", *partialBlockIdentifier.Index))
+		}
+		header, err = s.client.GetHeaderByNumber(context.Background(), uint64(*partialBlockIdentifier.Index))
+	} else {
+		header, err = s.client.GetHeader(context.Background(), ckbTypes.HexToHash(*partialBlockIdentifier.Hash))
+	}
+	if err != nil {
+		return nil, wrapErr(BlockNotFoundError, err)
+	}
+	// the node returns null for an unknown block, which the sdk decodes as a zero header
+	if header.Hash == (ckbTypes.Hash{}) {
+		return nil, wrapErr(BlockNotFoundError, errors.New("block not found"))
+	}
+	if partialBlockIdentifier.Hash != nil && ckbTypes.HexToHash(*partialBlockIdentifier.Hash) != header.Hash {
+		return nil, wrapErr(BlockNotFoundError, fmt.Errorf("block %d hash is %s", header.Number, header.Hash.String()))
+	}
+	if partialBlockIdentifier.Index == nil {
+		canonicalHeader, err := s.client.GetHeaderByNumber(context.Background(), header.Number)
+		if err != nil {
+			return nil, wrapErr(RpcError, err)
+		}
+		if canonicalHeader.Hash != header.Hash {
+			return nil, wrapErr(BlockNotFoundError, fmt.Errorf("block %s is not on the main chain", header.Hash.String()))
+		}
+	}
+
+	return header, nil
+}
+
+func (s *AccountAPIService) getTransactions(txHashes map[ckbTypes.Hash]bool) (map[ckbTypes.Hash]*ckbTypes.Transaction, error) {
+	batchReq := make([]ckbTypes.BatchTransactionItem, 0, len(txHashes))
+	for hash := range txHashes {
+		batchReq = append(batchReq, ckbTypes.BatchTransactionItem{
+			Hash:   hash,
+			Result: &ckbTypes.TransactionWithStatus{},
+		})
+	}
+	for start := 0; start < len(batchReq); start += ckb.BatchTransactionsLimit {
+		end := start + ckb.BatchTransactionsLimit
+		if end > len(batchReq) {
+			end = len(batchReq)
+		}
+		if err := s.client.BatchTransactions(context.Background(), batchReq[start:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	txs := make(map[ckbTypes.Hash]*ckbTypes.Transaction, len(batchReq))
+	for _, req := range batchReq {
+		if req.Error != nil {
+			return nil, req.Error
+		}
+		if req.Result.Transaction == nil {
+			return nil, fmt.Errorf("transaction %s not found", req.Hash.String())
+		}
+		txs[req.Hash] = req.Result.Transaction
+	}
+	return txs, nil
+}
+
+// buildAccountBalance sums the cells of the lock script, the CKB balance is the total capacity of the cells and is
+// split into free and occupied capacity in metadata. Cells without type script and data are returned as coins,
+// which includes the plain anyone-can-pay cells a TopUpACP transaction consumes and recreates. sUDT cells whose data
+// is shorter than an amount add no token balance.
+func (s *AccountAPIService) buildAccountBalance(blockIdentifier *types.BlockIdentifier, lock *ckbTypes.Script, cells []*indexer.LiveCell) (*types.AccountBalanceResponse, *types.Error) {
+	var freeCapacity uint64
+	var occupiedCapacity uint64
+	var ckbCoins []*types.Coin
+	udtBalances := make(map[string]*big.Int)
+	udtCurrencies := make(map[string]*types.Currency)
+	for _, cell := range cells {
+		if cell.Output.Type != nil || len(cell.OutputData) > 0 {
+			occupiedCapacity += cell.Output.Capacity
+		} else {
+			freeCapacity += cell.Output.Capacity
+			ckbCoins = append(ckbCoins, &types.Coin{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: fmt.Sprintf("%s:%d", cell.OutPoint.TxHash, cell.OutPoint.Index)},
+				Amount: &types.Amount{
+					Value:    fmt.Sprintf("%d", cell.Output.Capacity),
+					Currency: CkbCurrency,
+				},
+			})
+		}
+		if !isUDTCell(cell.Output, s.cfg) {
+			continue
+		}
+		currency, err := getUDTCurrency(cell.Output.Type, s.cfg)
+		if err != nil {
+			return nil, wrapErr(ScriptHashComputedError, err)
+		}
+		// anyone can create a cell of the sUDT type whose data is not an amount, it only occupies capacity
+		amount, err := builder.ParseUDTAmountData(cell.OutputData)
+		if err != nil {
+			continue
+		}
+		if _, ok := udtBalances[currency.Symbol]; !ok {
+			udtBalances[currency.Symbol] = new(big.Int)
+			udtCurrencies[currency.Symbol] = currency
+		}
+		udtBalances[currency.Symbol].Add(udtBalances[currency.Symbol], amount)
+	}
+
+	balances := []*types.Amount{
+		{
+			Value:    fmt.Sprintf("%d", freeCapacity+occupiedCapacity),
+			Currency: CkbCurrency,
 		},
-		Coins: ckbCoins,
+	}
+	symbols := make([]string, 0, len(udtBalances))
+	for symbol := range udtBalances {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		balances = append(balances, &types.Amount{
+			Value:    udtBalances[symbol].String(),
+			Currency: udtCurrencies[symbol],
+		})
+	}
+
+	metadata, err := types.MarshalMap(&ckb.AccountBalanceMetadata{
+		FreeCapacity:     fmt.Sprintf("%d", freeCapacity),
+		OccupiedCapacity: fmt.Sprintf("%d", occupiedCapacity),
+		LockType:         getLockType(lock, s.cfg),
+	})
+	if err != nil {
+		return nil, wrapErr(ServerError, err)
+	}
+
+	return &types.AccountBalanceResponse{
+		BlockIdentifier: blockIdentifier,
+		Balances:        balances,
+		Coins:           ckbCoins,
+		Metadata:        metadata,
 	}, nil
 }
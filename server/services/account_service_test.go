@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func TestBuildAccountBalance(t *testing.T) {
+	cfg := testConfig(t)
+	s := &AccountAPIService{cfg: cfg}
+	lock := &ckbTypes.Script{CodeHash: ckbTypes.HexToHash("0x01"), HashType: ckbTypes.HashTypeType}
+	cell := func(index uint, capacity uint64, typeScript *ckbTypes.Script, data []byte) *indexer.LiveCell {
+		return &indexer.LiveCell{
+			Output:     &ckbTypes.CellOutput{Capacity: capacity, Lock: lock, Type: typeScript},
+			OutputData: data,
+			OutPoint:   &ckbTypes.OutPoint{TxHash: ckbTypes.HexToHash("0x02"), Index: index},
+		}
+	}
+	udtType := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.UDT.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.UDT.Script.HashType),
+		Args:     []byte{1},
+	}
+	udtHash, err := udtType.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	udtData, err := builder.ParseUDTAmount("25")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		cells        []*indexer.LiveCell
+		wantBalances []string
+		wantCoins    int
+		wantFree     string
+		wantOccupied string
+	}{
+		{name: "no cells", wantBalances: []string{"0"}, wantFree: "0", wantOccupied: "0"},
+		{
+			name:         "free cells",
+			cells:        []*indexer.LiveCell{cell(0, 6100000000, nil, nil), cell(1, 7000000000, nil, nil)},
+			wantBalances: []string{"13100000000"},
+			wantCoins:    2,
+			wantFree:     "13100000000",
+			wantOccupied: "0",
+		},
+		{
+			name:         "occupied cells are in the total",
+			cells:        []*indexer.LiveCell{cell(0, 6100000000, nil, nil), cell(1, 7000000000, nil, []byte{1})},
+			wantBalances: []string{"13100000000"},
+			wantCoins:    1,
+			wantFree:     "6100000000",
+			wantOccupied: "7000000000",
+		},
+		{
+			name:         "sUDT cells",
+			cells:        []*indexer.LiveCell{cell(0, 14200000000, udtType, builder.GenerateUDTAmountData(udtData))},
+			wantBalances: []string{"14200000000", "25"},
+			wantFree:     "0",
+			wantOccupied: "14200000000",
+		},
+		{
+			name:         "sUDT cell without amount",
+			cells:        []*indexer.LiveCell{cell(0, 14200000000, udtType, builder.GenerateUDTAmountData(udtData)), cell(1, 14200000000, udtType, []byte{1, 2, 3})},
+			wantBalances: []string{"28400000000", "25"},
+			wantFree:     "0",
+			wantOccupied: "28400000000",
+		},
+		{
+			name:         "only sUDT cells without amount",
+			cells:        []*indexer.LiveCell{cell(0, 14200000000, udtType, nil)},
+			wantBalances: []string{"14200000000"},
+			wantFree:     "0",
+			wantOccupied: "14200000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blockIdentifier := &types.BlockIdentifier{Index: 1, Hash: "0x03"}
+			response, rErr := s.buildAccountBalance(blockIdentifier, lock, tt.cells)
+			if rErr != nil {
+				t.Fatal(rErr)
+			}
+			if len(response.Balances) != len(tt.wantBalances) {
+				t.Fatalf("buildAccountBalance() balances = %v, want %v", response.Balances, tt.wantBalances)
+			}
+			for i, want := range tt.wantBalances {
+				if response.Balances[i].Value != want {
+					t.Errorf("buildAccountBalance() balance %d = %s, want %s", i, response.Balances[i].Value, want)
+				}
+			}
+			if len(tt.wantBalances) > 1 && response.Balances[1].Currency.Symbol != udtHash.String() {
+				t.Errorf("buildAccountBalance() currency = %s, want %s", response.Balances[1].Currency.Symbol, udtHash.String())
+			}
+			if len(response.Coins) != tt.wantCoins {
+				t.Errorf("buildAccountBalance() coins = %d, want %d", len(response.Coins), tt.wantCoins)
+			}
+			var metadata ckb.AccountBalanceMetadata
+			if err := types.UnmarshalMap(response.Metadata, &metadata); err != nil {
+				t.Fatal(err)
+			}
+			if metadata.FreeCapacity != tt.wantFree || metadata.OccupiedCapacity != tt.wantOccupied {
+				t.Errorf("buildAccountBalance() metadata = %+v, want free %s occupied %s", metadata, tt.wantFree, tt.wantOccupied)
+			}
+		})
+	}
+}
+
+// testClient serves the headers of a chain, the rpc methods it does not override panic
+type testClient struct {
+	rpc.Client
+	headers []*ckbTypes.Header
+	forks   []*ckbTypes.Header
+}
+
+// GetHeaderByNumber returns a zero header above the tip like the sdk decoding a null result
+func (c *testClient) GetHeaderByNumber(ctx context.Context, number uint64) (*ckbTypes.Header, error) {
+	if number >= uint64(len(c.headers)) {
+		return &ckbTypes.Header{}, nil
+	}
+	return c.headers[number], nil
+}
+
+func (c *testClient) GetHeader(ctx context.Context, hash ckbTypes.Hash) (*ckbTypes.Header, error) {
+	for _, header := range append(c.headers, c.forks...) {
+		if header.Hash == hash {
+			return header, nil
+		}
+	}
+	return &ckbTypes.Header{}, nil
+}
+
+func newTestClient(tip uint64) *testClient {
+	c := &testClient{}
+	for number := uint64(0); number <= tip; number++ {
+		c.headers = append(c.headers, &ckbTypes.Header{Number: number, Hash: ckbTypes.BytesToHash([]byte{byte(number + 1)})})
+	}
+	return c
+}
+
+func TestGetCanonicalHeader(t *testing.T) {
+	client := newTestClient(10)
+	fork := &ckbTypes.Header{Number: 5, Hash: ckbTypes.BytesToHash([]byte{0xff})}
+	client.forks = append(client.forks, fork)
+	s := &AccountAPIService{client: client}
+	index := func(index int64) *int64 {
+		return &index
+	}
+	hash := func(hash ckbTypes.Hash) *string {
+		s := hash.String()
+		return &s
+	}
+
+	tests := []struct {
+		name       string
+		identifier *types.PartialBlockIdentifier
+		wantNumber uint64
+		wantErr    *types.Error
+	}{
+		{name: "index", identifier: &types.PartialBlockIdentifier{Index: index(3)}, wantNumber: 3},
+		{name: "tip index", identifier: &types.PartialBlockIdentifier{Index: index(10)}, wantNumber: 10},
+		{name: "index above tip", identifier: &types.PartialBlockIdentifier{Index: index(11)}, wantErr: BlockNotFoundError},
+		{name: "negative index", identifier: &types.PartialBlockIdentifier{Index: index(-1)}, wantErr: BlockNotFoundError},
+		{name: "hash", identifier: &types.PartialBlockIdentifier{Hash: hash(client.headers[7].Hash)}, wantNumber: 7},
+		{name: "unknown hash", identifier: &types.PartialBlockIdentifier{Hash: hash(ckbTypes.BytesToHash([]byte{0xee}))}, wantErr: BlockNotFoundError},
+		{name: "fork hash", identifier: &types.PartialBlockIdentifier{Hash: hash(fork.Hash)}, wantErr: BlockNotFoundError},
+		{
+			name:       "index and hash",
+			identifier: &types.PartialBlockIdentifier{Index: index(4), Hash: hash(client.headers[4].Hash)},
+			wantNumber: 4,
+		},
+		{
+			name:       "index and other hash",
+			identifier: &types.PartialBlockIdentifier{Index: index(4), Hash: hash(client.headers[5].Hash)},
+			wantErr:    BlockNotFoundError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, rErr := s.getCanonicalHeader(tt.identifier)
+			assertError(t, rErr, tt.wantErr)
+			if tt.wantErr == nil && header.Number != tt.wantNumber {
+				t.Errorf("getCanonicalHeader() number = %d, want %d", header.Number, tt.wantNumber)
+			}
+		})
+	}
+}
@@ -3,28 +3,43 @@ package services
 import (
 	"context"
 	"fmt"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/nervosnetwork/ckb-sdk-go/rpc"
 	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
 )
 
 // BlockAPIService implements the server.BlockAPIServicer interface.
 type BlockAPIService struct {
-	network *types.NetworkIdentifier
-	client  rpc.Client
-	cfg     *config.Config
+	// tipNumber is the highest tip fetched by cacheBlock, it is accessed atomically and kept first for alignment
+	tipNumber uint64
+	network   *types.NetworkIdentifier
+	client    rpc.Client
+	cfg       *config.Config
+	// blockCache keeps confirmed block responses by both index and hash
+	blockCache     *lruCache
+	outputResolver OutputResolver
 }
 
-// NewBlockAPIService creates a new instance of a BlockAPIService.
-func NewBlockAPIService(network *types.NetworkIdentifier, client rpc.Client, cfg *config.Config) server.BlockAPIServicer {
+var _ server.BlockAPIServicer = (*BlockAPIService)(nil)
+
+// NewBlockAPIService creates a new instance of a BlockAPIService, the mempool and search services of the network
+// render transactions with it so they share its caches.
+func NewBlockAPIService(network *types.NetworkIdentifier, client rpc.Client, cfg *config.Config) *BlockAPIService {
 	return &BlockAPIService{
-		network: network,
-		client:  client,
-		cfg:     cfg,
+		network:        network,
+		client:         client,
+		cfg:            cfg,
+		blockCache:     newLRUCache(cfg.Cache.Blocks * 2),
+		outputResolver: NewOutputResolver(client, cfg),
 	}
 }
 
@@ -39,8 +54,14 @@ func (s *BlockAPIService) Block(
 		if *request.BlockIdentifier.Index < 0 {
 			*request.BlockIdentifier.Index = 0
 		}
+		if cached, ok := s.blockCache.Get(fmt.Sprintf("%d", *request.BlockIdentifier.Index)); ok {
+			return cached.(*types.BlockResponse), nil
+		}
 		block, err = s.client.GetBlockByNumber(context.Background(), uint64(*request.BlockIdentifier.Index))
 	} else {
+		if cached, ok := s.blockCache.Get(*request.BlockIdentifier.Hash); ok {
+			return cached.(*types.BlockResponse), nil
+		}
 		block, err = s.client.GetBlock(context.Background(), ckbTypes.HexToHash(*request.BlockIdentifier.Hash))
 	}
 	if err != nil {
@@ -62,6 +83,12 @@ func (s *BlockAPIService) Block(
 		},
 	}
 
+	metadata, rErr := blockMetadata(block)
+	if rErr != nil {
+		return nil, rErr
+	}
+	result.Block.Metadata = metadata
+
 	if block.Header.Number > 0 {
 		result.Block.ParentBlockIdentifier = &types.BlockIdentifier{
 			Index: int64(block.Header.Number) - 1,
@@ -69,47 +96,17 @@ func (s *BlockAPIService) Block(
 		}
 	}
 
-	batchReq := make([]ckbTypes.BatchTransactionItem, 0)
-	txHashCache := make(map[string]bool)
+	var previousOutPoints []*ckbTypes.OutPoint
 	for i, tx := range block.Transactions {
 		if i != 0 {
 			for _, input := range tx.Inputs {
-				if _, ok := txHashCache[input.PreviousOutput.TxHash.String()]; !ok {
-					txHashCache[input.PreviousOutput.TxHash.String()] = true
-					batchReq = append(batchReq, ckbTypes.BatchTransactionItem{
-						Hash:   input.PreviousOutput.TxHash,
-						Result: &ckbTypes.TransactionWithStatus{},
-					})
-				}
+				previousOutPoints = append(previousOutPoints, input.PreviousOutput)
 			}
 		}
 	}
-	inputTxCache := make(map[string]*ckbTypes.TransactionWithStatus)
-	if len(batchReq) > 0 {
-		count := len(batchReq) / 2000
-		if len(batchReq)%2000 != 0 {
-			count++
-		}
-
-		for i := 0; i < count; i++ {
-			start := i * 2000
-			end := start + 2000
-			if i == count-1 {
-				end = len(batchReq)
-			}
-			err = s.client.BatchTransactions(context.Background(), batchReq[start:end])
-			if err != nil {
-				return nil, RpcError
-			}
-
-		}
-	}
-
-	for _, req := range batchReq {
-		if req.Error != nil || req.Result.Transaction == nil {
-			return nil, RpcError
-		}
-		inputTxCache[req.Hash.String()] = req.Result
+	previousOutputs, rErr := s.outputResolver.Resolve(previousOutPoints)
+	if rErr != nil {
+		return nil, rErr
 	}
 
 	for i, tx := range block.Transactions {
@@ -123,29 +120,9 @@ func (s *BlockAPIService) Block(
 					},
 					Operations: []*types.Operation{},
 				}
-				for _, output := range tx.Outputs {
-					accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
-						LockType: getLockType(output.Lock, s.cfg),
-					})
-					if err != nil {
-						return nil, wrapErr(InvalidAccountIdentifierMetadataError, err)
-					}
-					transaction.Operations = append(transaction.Operations, &types.Operation{
-						OperationIdentifier: &types.OperationIdentifier{
-							Index: optIndex,
-						},
-						Type:   "Reward",
-						Status: "Success",
-						Account: &types.AccountIdentifier{
-							Address:  GenerateAddress(s.network, output.Lock),
-							Metadata: accountMetadata,
-						},
-						Amount: &types.Amount{
-							Value:    fmt.Sprintf("%d", output.Capacity),
-							Currency: CkbCurrency,
-						},
-					})
-					optIndex++
+				optIndex, rErr = s.appendRewardOperations(ctx, transaction, optIndex, tx, &block.Header.Hash)
+				if rErr != nil {
+					return nil, rErr
 				}
 			}
 		} else {
@@ -156,30 +133,39 @@ func (s *BlockAPIService) Block(
 				Operations: []*types.Operation{},
 			}
 			for _, input := range tx.Inputs {
-				tx := inputTxCache[input.PreviousOutput.TxHash.String()]
-				if tx == nil {
+				previousOutput := previousOutputs[outPointKey(input.PreviousOutput)]
+				if previousOutput == nil {
 					return nil, ServerError
 				}
 
 				accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
-					LockType: getLockType(tx.Transaction.Outputs[input.PreviousOutput.Index].Lock, s.cfg),
+					LockType: getLockType(previousOutput.Output.Lock, s.cfg),
 				})
 				if err != nil {
 					return nil, wrapErr(InvalidAccountIdentifierMetadataError, err)
 				}
+				addr, err := GenerateAddress(s.network, previousOutput.Output.Lock)
+				if err != nil {
+					return nil, wrapErr(AddressParseError, err)
+				}
+				operationMetadata, rErr := getInputOperationMetadata(previousOutput, input.Since, s.cfg)
+				if rErr != nil {
+					return nil, rErr
+				}
 
 				transaction.Operations = append(transaction.Operations, &types.Operation{
 					OperationIdentifier: &types.OperationIdentifier{
 						Index: optIndex,
 					},
-					Type:   ckb.InputOpType,
-					Status: "Success",
+					Type:     inputOperationType(previousOutput.Output, previousOutput.Data, s.cfg),
+					Status:   "Success",
+					Metadata: operationMetadata,
 					Account: &types.AccountIdentifier{
-						Address:  GenerateAddress(s.network, tx.Transaction.Outputs[input.PreviousOutput.Index].Lock),
+						Address:  addr,
 						Metadata: accountMetadata,
 					},
 					Amount: &types.Amount{
-						Value:    fmt.Sprintf("-%d", tx.Transaction.Outputs[input.PreviousOutput.Index].Capacity),
+						Value:    fmt.Sprintf("-%d", previousOutput.Output.Capacity),
 						Currency: CkbCurrency,
 					},
 					CoinChange: &types.CoinChange{
@@ -188,6 +174,14 @@ func (s *BlockAPIService) Block(
 					},
 				})
 				optIndex++
+				optIndex, rErr = s.appendUDTOperation(transaction, optIndex, previousOutput.Output, previousOutput.Data)
+				if rErr != nil {
+					return nil, rErr
+				}
+				optIndex, rErr = s.appendDAOInterestOperation(ctx, transaction, optIndex, input, previousOutput)
+				if rErr != nil {
+					return nil, rErr
+				}
 			}
 			for i, output := range tx.Outputs {
 				accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
@@ -196,14 +190,23 @@ func (s *BlockAPIService) Block(
 				if err != nil {
 					return nil, wrapErr(InvalidAccountIdentifierMetadataError, err)
 				}
+				addr, err := GenerateAddress(s.network, output.Lock)
+				if err != nil {
+					return nil, wrapErr(AddressParseError, err)
+				}
+				operationMetadata, rErr := getCellOperationMetadata(output, tx.OutputsData[i], s.cfg)
+				if rErr != nil {
+					return nil, rErr
+				}
 				transaction.Operations = append(transaction.Operations, &types.Operation{
 					OperationIdentifier: &types.OperationIdentifier{
 						Index: optIndex,
 					},
-					Type:   ckb.OutputOpType,
-					Status: "Success",
+					Type:     outputOperationType(output, tx.OutputsData[i], s.cfg),
+					Status:   "Success",
+					Metadata: operationMetadata,
 					Account: &types.AccountIdentifier{
-						Address:  GenerateAddress(s.network, output.Lock),
+						Address:  addr,
 						Metadata: accountMetadata,
 					},
 					Amount: &types.Amount{
@@ -219,16 +222,45 @@ func (s *BlockAPIService) Block(
 					},
 				})
 				optIndex++
+				optIndex, rErr = s.appendUDTOperation(transaction, optIndex, output, tx.OutputsData[i])
+				if rErr != nil {
+					return nil, rErr
+				}
 			}
 		}
 		if transaction != nil {
+			if rErr := appendTransactionMetadata(transaction, tx, optIndex, i == 0); rErr != nil {
+				return nil, rErr
+			}
 			result.Block.Transactions = append(result.Block.Transactions, transaction)
 		}
 	}
 
+	s.cacheBlock(ctx, block.Header, result)
+
 	return result, nil
 }
 
+// cacheBlock caches the block response once the block is Confirmations deep, tip blocks are always rendered again.
+// The tip is only fetched again when the block is not deep enough under the tip fetched before.
+func (s *BlockAPIService) cacheBlock(ctx context.Context, header *ckbTypes.Header, response *types.BlockResponse) {
+	if s.cfg.Cache.Blocks <= 0 {
+		return
+	}
+	if header.Number+s.cfg.Cache.Confirmations > atomic.LoadUint64(&s.tipNumber) {
+		tipNumber, err := s.client.GetTipBlockNumber(ctx)
+		if err != nil {
+			return
+		}
+		atomic.StoreUint64(&s.tipNumber, tipNumber)
+		if header.Number+s.cfg.Cache.Confirmations > tipNumber {
+			return
+		}
+	}
+	s.blockCache.Add(fmt.Sprintf("%d", header.Number), response)
+	s.blockCache.Add(header.Hash.String(), response)
+}
+
 // BlockTransaction implements the /block/transaction endpoint.
 func (s *BlockAPIService) BlockTransaction(
 	ctx context.Context,
@@ -238,69 +270,75 @@ func (s *BlockAPIService) BlockTransaction(
 	if err != nil {
 		return nil, RpcError
 	}
+	blockHash := ckbTypes.HexToHash(request.BlockIdentifier.Hash)
+	transaction, rErr := s.buildTransaction(ctx, tx.Transaction, &blockHash)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return &types.BlockTransactionResponse{
+		Transaction: transaction,
+	}, nil
+}
+
+// buildTransaction converts a CKB transaction into Rosetta operations, resolving
+// the previous outputs of its inputs through the node. The cellbase is only split into
+// its reward components when the hash of its block is known.
+func (s *BlockAPIService) buildTransaction(ctx context.Context, tx *ckbTypes.Transaction, blockHash *ckbTypes.Hash) (*types.Transaction, *types.Error) {
 	var transaction *types.Transaction
 	optIndex := int64(0)
-	if tx.Transaction.Inputs[0].PreviousOutput.TxHash.String() == "0x0000000000000000000000000000000000000000000000000000000000000000" {
-		if len(tx.Transaction.Outputs) > 0 {
+	cellbase := tx.Inputs[0].PreviousOutput.TxHash.String() == "0x0000000000000000000000000000000000000000000000000000000000000000"
+	if cellbase {
+		if len(tx.Outputs) > 0 {
 			transaction = &types.Transaction{
 				TransactionIdentifier: &types.TransactionIdentifier{
-					Hash: tx.Transaction.Hash.String(),
+					Hash: tx.Hash.String(),
 				},
 				Operations: []*types.Operation{},
 			}
-			for _, output := range tx.Transaction.Outputs {
-				accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
-					LockType: getLockType(output.Lock, s.cfg),
-				})
-				if err != nil {
-					return nil, wrapErr(InvalidAccountIdentifierMetadataError, err)
-				}
-				transaction.Operations = append(transaction.Operations, &types.Operation{
-					OperationIdentifier: &types.OperationIdentifier{
-						Index: optIndex,
-					},
-					Type:   "Reward",
-					Status: "Success",
-					Account: &types.AccountIdentifier{
-						Address:  GenerateAddress(s.network, output.Lock),
-						Metadata: accountMetadata,
-					},
-					Amount: &types.Amount{
-						Value:    fmt.Sprintf("%d", output.Capacity),
-						Currency: CkbCurrency,
-					},
-				})
-				optIndex++
+			var rErr *types.Error
+			optIndex, rErr = s.appendRewardOperations(ctx, transaction, optIndex, tx, blockHash)
+			if rErr != nil {
+				return nil, rErr
 			}
 		}
 	} else {
 		transaction = &types.Transaction{
 			TransactionIdentifier: &types.TransactionIdentifier{
-				Hash: tx.Transaction.Hash.String(),
+				Hash: tx.Hash.String(),
 			},
 			Operations: []*types.Operation{},
 		}
-		index, err := s.processTxInputs(tx.Transaction.Inputs, optIndex, transaction)
-		if err != nil {
-			return nil, RpcError
+		index, rErr := s.processTxInputs(ctx, tx.Inputs, optIndex, transaction)
+		if rErr != nil {
+			return nil, rErr
 		}
 		optIndex = index
 
-		for i, output := range tx.Transaction.Outputs {
+		for i, output := range tx.Outputs {
 			accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
 				LockType: getLockType(output.Lock, s.cfg),
 			})
 			if err != nil {
 				return nil, wrapErr(InvalidAccountIdentifierMetadataError, err)
 			}
+			addr, err := GenerateAddress(s.network, output.Lock)
+			if err != nil {
+				return nil, wrapErr(AddressParseError, err)
+			}
+			operationMetadata, rErr := getCellOperationMetadata(output, tx.OutputsData[i], s.cfg)
+			if rErr != nil {
+				return nil, rErr
+			}
 			transaction.Operations = append(transaction.Operations, &types.Operation{
 				OperationIdentifier: &types.OperationIdentifier{
 					Index: optIndex,
 				},
-				Type:   ckb.OutputOpType,
-				Status: "Success",
+				Type:     outputOperationType(output, tx.OutputsData[i], s.cfg),
+				Status:   "Success",
+				Metadata: operationMetadata,
 				Account: &types.AccountIdentifier{
-					Address:  GenerateAddress(s.network, output.Lock),
+					Address:  addr,
 					Metadata: accountMetadata,
 				},
 				Amount: &types.Amount{
@@ -309,68 +347,75 @@ func (s *BlockAPIService) BlockTransaction(
 				},
 				CoinChange: &types.CoinChange{
 					CoinIdentifier: getCoinIdentifier(&ckbTypes.OutPoint{
-						TxHash: tx.Transaction.Hash,
+						TxHash: tx.Hash,
 						Index:  uint(i),
 					}),
 					CoinAction: types.CoinCreated,
 				},
 			})
 			optIndex++
+			optIndex, rErr = s.appendUDTOperation(transaction, optIndex, output, tx.OutputsData[i])
+			if rErr != nil {
+				return nil, rErr
+			}
 		}
 	}
 
 	if transaction == nil {
 		transaction = &types.Transaction{
 			TransactionIdentifier: &types.TransactionIdentifier{
-				Hash: tx.Transaction.Hash.String(),
+				Hash: tx.Hash.String(),
 			},
 			Operations: []*types.Operation{},
 		}
 	}
+	if rErr := appendTransactionMetadata(transaction, tx, optIndex, cellbase); rErr != nil {
+		return nil, rErr
+	}
 
-	return &types.BlockTransactionResponse{
-		Transaction: transaction,
-	}, nil
+	return transaction, nil
 }
 
-func (s *BlockAPIService) processTxInputs(inputs []*ckbTypes.CellInput, optIndex int64, transaction *types.Transaction) (int64, error) {
-	batchReq := make([]ckbTypes.BatchTransactionItem, len(inputs))
+func (s *BlockAPIService) processTxInputs(ctx context.Context, inputs []*ckbTypes.CellInput, optIndex int64, transaction *types.Transaction) (int64, *types.Error) {
+	previousOutPoints := make([]*ckbTypes.OutPoint, len(inputs))
 	for i, input := range inputs {
-		batchReq[i] = ckbTypes.BatchTransactionItem{
-			Hash:   input.PreviousOutput.TxHash,
-			Result: &ckbTypes.TransactionWithStatus{},
-		}
+		previousOutPoints[i] = input.PreviousOutput
 	}
-
-	err := s.client.BatchTransactions(context.Background(), batchReq)
-	if err != nil {
-		return 0, err
+	previousOutputs, rErr := s.outputResolver.Resolve(previousOutPoints)
+	if rErr != nil {
+		return 0, rErr
 	}
 
-	for i, input := range inputs {
-		req := batchReq[i]
-		if req.Error != nil {
-			return 0, req.Error
-		}
+	for _, input := range inputs {
+		previousOutput := previousOutputs[outPointKey(input.PreviousOutput)]
 		accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
-			LockType: getLockType(req.Result.Transaction.Outputs[input.PreviousOutput.Index].Lock, s.cfg),
+			LockType: getLockType(previousOutput.Output.Lock, s.cfg),
 		})
 		if err != nil {
-			return 0, err
+			return 0, wrapErr(InvalidAccountIdentifierMetadataError, err)
+		}
+		addr, err := GenerateAddress(s.network, previousOutput.Output.Lock)
+		if err != nil {
+			return 0, wrapErr(AddressParseError, err)
+		}
+		operationMetadata, rErr := getInputOperationMetadata(previousOutput, input.Since, s.cfg)
+		if rErr != nil {
+			return 0, rErr
 		}
 
 		transaction.Operations = append(transaction.Operations, &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{
 				Index: optIndex,
 			},
-			Type:   ckb.InputOpType,
-			Status: "Success",
+			Type:     inputOperationType(previousOutput.Output, previousOutput.Data, s.cfg),
+			Status:   "Success",
+			Metadata: operationMetadata,
 			Account: &types.AccountIdentifier{
-				Address:  GenerateAddress(s.network, req.Result.Transaction.Outputs[input.PreviousOutput.Index].Lock),
+				Address:  addr,
 				Metadata: accountMetadata,
 			},
 			Amount: &types.Amount{
-				Value:    fmt.Sprintf("-%d", req.Result.Transaction.Outputs[input.PreviousOutput.Index].Capacity),
+				Value:    fmt.Sprintf("-%d", previousOutput.Output.Capacity),
 				Currency: CkbCurrency,
 			},
 			CoinChange: &types.CoinChange{
@@ -379,7 +424,286 @@ func (s *BlockAPIService) processTxInputs(inputs []*ckbTypes.CellInput, optIndex
 			},
 		})
 		optIndex++
+		optIndex, rErr = s.appendUDTOperation(transaction, optIndex, previousOutput.Output, previousOutput.Data)
+		if rErr != nil {
+			return 0, rErr
+		}
+		optIndex, rErr = s.appendDAOInterestOperation(ctx, transaction, optIndex, input, previousOutput)
+		if rErr != nil {
+			return 0, rErr
+		}
+	}
+
+	return optIndex, nil
+}
+
+// appendUDTOperation appends the sUDT amount of a cell as an operation related to its capacity operation, a cell
+// whose data is not an amount has no sUDT operation and is marked with invalid_udt_data in its metadata
+func (s *BlockAPIService) appendUDTOperation(transaction *types.Transaction, optIndex int64, output *ckbTypes.CellOutput, data []byte) (int64, *types.Error) {
+	if !isUDTCell(output, s.cfg) {
+		return optIndex, nil
+	}
+	amount, err := builder.ParseUDTAmountData(data)
+	if err != nil {
+		return optIndex, nil
+	}
+	currency, err := getUDTCurrency(output.Type, s.cfg)
+	if err != nil {
+		return 0, wrapErr(ScriptHashComputedError, err)
+	}
+	capacityOperation := transaction.Operations[len(transaction.Operations)-1]
+	if capacityOperation.Type == ckb.InputOpType {
+		amount.Neg(amount)
+	}
+	transaction.Operations = append(transaction.Operations, &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index: optIndex,
+		},
+		RelatedOperations: []*types.OperationIdentifier{
+			capacityOperation.OperationIdentifier,
+		},
+		Type:    capacityOperation.Type,
+		Status:  capacityOperation.Status,
+		Account: capacityOperation.Account,
+		Amount: &types.Amount{
+			Value:    amount.String(),
+			Currency: currency,
+		},
+	})
+
+	return optIndex + 1, nil
+}
+
+// appendRewardOperations appends a Reward operation per cellbase output, a single output is split into the
+// reward components of the block when they add up to its capacity. The genesis cellbase has no reward.
+func (s *BlockAPIService) appendRewardOperations(ctx context.Context, transaction *types.Transaction, optIndex int64, tx *ckbTypes.Transaction, blockHash *ckbTypes.Hash) (int64, *types.Error) {
+	var reward *ckbTypes.BlockReward
+	// the since of the cellbase input is the block number
+	if blockHash != nil && len(tx.Outputs) == 1 && tx.Inputs[0].Since > 0 {
+		var err error
+		reward, err = s.client.GetCellbaseOutputCapacityDetails(ctx, *blockHash)
+		if err != nil {
+			return 0, wrapErr(RpcError, err)
+		}
+		if reward.Total == nil || reward.Total.Cmp(new(big.Int).SetUint64(tx.Outputs[0].Capacity)) != 0 {
+			reward = nil
+		}
+	}
+
+	for _, output := range tx.Outputs {
+		accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
+			LockType: getLockType(output.Lock, s.cfg),
+		})
+		if err != nil {
+			return 0, wrapErr(InvalidAccountIdentifierMetadataError, err)
+		}
+		addr, err := GenerateAddress(s.network, output.Lock)
+		if err != nil {
+			return 0, wrapErr(AddressParseError, err)
+		}
+		account := &types.AccountIdentifier{
+			Address:  addr,
+			Metadata: accountMetadata,
+		}
+		if reward == nil {
+			transaction.Operations = append(transaction.Operations, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: optIndex,
+				},
+				Type:    ckb.RewardOpType,
+				Status:  "Success",
+				Account: account,
+				Amount: &types.Amount{
+					Value:    fmt.Sprintf("%d", output.Capacity),
+					Currency: CkbCurrency,
+				},
+			})
+			optIndex++
+			continue
+		}
+
+		components := []struct {
+			rewardType string
+			amount     *big.Int
+		}{
+			{ckb.PrimaryIssuanceReward, reward.Primary},
+			{ckb.SecondaryIssuanceReward, reward.Secondary},
+			{ckb.ProposalReward, reward.ProposalReward},
+			{ckb.CommitReward, reward.TxFee},
+		}
+		for _, component := range components {
+			if component.amount == nil || component.amount.Sign() == 0 {
+				continue
+			}
+			metadata, err := types.MarshalMap(&ckb.RewardOperationMetadata{
+				RewardType: component.rewardType,
+			})
+			if err != nil {
+				return 0, wrapErr(InvalidOperationMetadataError, err)
+			}
+			transaction.Operations = append(transaction.Operations, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: optIndex,
+				},
+				Type:    ckb.RewardOpType,
+				Status:  "Success",
+				Account: account,
+				Amount: &types.Amount{
+					Value:    component.amount.String(),
+					Currency: CkbCurrency,
+				},
+				Metadata: metadata,
+			})
+			optIndex++
+		}
 	}
 
 	return optIndex, nil
 }
+
+// appendDAOInterestOperation appends the interest of the withdrawing cell spent by the last DAO_WITHDRAW operation,
+// it has no account because the outputs of the transaction already pay it out.
+func (s *BlockAPIService) appendDAOInterestOperation(ctx context.Context, transaction *types.Transaction, optIndex int64, input *ckbTypes.CellInput, previousOutput *ResolvedOutput) (int64, *types.Error) {
+	withdrawOperation := transaction.Operations[len(transaction.Operations)-1]
+	if withdrawOperation.Type != ckb.DAOWithdrawOpType {
+		return optIndex, nil
+	}
+	// the deposit cell is the input at the index of the withdrawing cell in the withdraw transaction
+	withdrawTx, err := s.client.GetTransaction(ctx, input.PreviousOutput.TxHash)
+	if err != nil {
+		return 0, wrapErr(RpcError, err)
+	}
+	if withdrawTx == nil || withdrawTx.Transaction == nil || withdrawTx.TxStatus.BlockHash == nil ||
+		int(input.PreviousOutput.Index) >= len(withdrawTx.Transaction.Inputs) {
+		return 0, wrapErr(RpcError, fmt.Errorf("withdraw transaction %s not found", input.PreviousOutput.TxHash.String()))
+	}
+	depositOutPoint := withdrawTx.Transaction.Inputs[input.PreviousOutput.Index].PreviousOutput
+	maximumWithdraw, err := s.client.CalculateDaoMaximumWithdraw(ctx, depositOutPoint, *withdrawTx.TxStatus.BlockHash)
+	if err != nil {
+		return 0, wrapErr(RpcError, err)
+	}
+	capacity := previousOutput.Output.Capacity
+	if maximumWithdraw < capacity {
+		return 0, wrapErr(ServerError, fmt.Errorf("maximum withdraw %d is less than the capacity %d", maximumWithdraw, capacity))
+	}
+
+	transaction.Operations = append(transaction.Operations, &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index: optIndex,
+		},
+		RelatedOperations: []*types.OperationIdentifier{
+			withdrawOperation.OperationIdentifier,
+		},
+		Type:   ckb.DAOInterestOpType,
+		Status: "Success",
+		Amount: &types.Amount{
+			Value:    fmt.Sprintf("%d", maximumWithdraw-capacity),
+			Currency: CkbCurrency,
+		},
+	})
+
+	return optIndex + 1, nil
+}
+
+// inputOperationType returns DAO_WITHDRAW for the withdrawing cells and INPUT for other cells
+func inputOperationType(output *ckbTypes.CellOutput, data []byte, cfg *config.Config) string {
+	if getCellType(output, data, cfg) == ckb.DAOWithdrawingCell {
+		return ckb.DAOWithdrawOpType
+	}
+	return ckb.InputOpType
+}
+
+// outputOperationType returns DAO_DEPOSIT for the deposit cells and OUTPUT for other cells
+func outputOperationType(output *ckbTypes.CellOutput, data []byte, cfg *config.Config) string {
+	if getCellType(output, data, cfg) == ckb.DAODepositCell {
+		return ckb.DAODepositOpType
+	}
+	return ckb.OutputOpType
+}
+
+// appendTransactionMetadata appends the FEE operation of a non-cellbase transaction and sets its metadata
+func appendTransactionMetadata(transaction *types.Transaction, tx *ckbTypes.Transaction, optIndex int64, cellbase bool) *types.Error {
+	metadata := &ckb.TransactionMetadata{
+		CellDeps:    make([]*ckb.CellDep, len(tx.CellDeps)),
+		HeaderDeps:  make([]string, len(tx.HeaderDeps)),
+		InputsSince: make([]hexutil.Uint64, len(tx.Inputs)),
+	}
+	for i, cellDep := range tx.CellDeps {
+		metadata.CellDeps[i] = &ckb.CellDep{
+			OutPoint: getCoinIdentifier(cellDep.OutPoint).Identifier,
+			DepType:  string(cellDep.DepType),
+		}
+	}
+	for i, headerDep := range tx.HeaderDeps {
+		metadata.HeaderDeps[i] = headerDep.String()
+	}
+	for i, input := range tx.Inputs {
+		metadata.InputsSince[i] = hexutil.Uint64(input.Since)
+	}
+	if !cellbase {
+		fee, rErr := appendFeeOperation(transaction, optIndex)
+		if rErr != nil {
+			return rErr
+		}
+		metadata.Fee = fee.String()
+	}
+
+	var err error
+	transaction.Metadata, err = types.MarshalMap(metadata)
+	if err != nil {
+		return wrapErr(ServerError, err)
+	}
+	return nil
+}
+
+// appendFeeOperation appends the capacity left to the miner as a FEE operation, it has no account because the
+// INPUT and OUTPUT operations already account for it.
+func appendFeeOperation(transaction *types.Transaction, optIndex int64) (*big.Int, *types.Error) {
+	fee := new(big.Int)
+	for _, operation := range transaction.Operations {
+		switch operation.Type {
+		case ckb.InputOpType, ckb.OutputOpType, ckb.DAODepositOpType, ckb.DAOWithdrawOpType, ckb.DAOInterestOpType:
+		default:
+			continue
+		}
+		if types.Hash(operation.Amount.Currency) != types.Hash(CkbCurrency) {
+			continue
+		}
+		value, ok := new(big.Int).SetString(operation.Amount.Value, 10)
+		if !ok {
+			return nil, wrapErr(DataParseError, fmt.Errorf("invalid amount %s", operation.Amount.Value))
+		}
+		// the interest is paid by the DAO, it adds to the inputs
+		if operation.Type == ckb.DAOInterestOpType {
+			fee.Add(fee, value)
+		} else {
+			fee.Sub(fee, value)
+		}
+	}
+	transaction.Operations = append(transaction.Operations, &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{
+			Index: optIndex,
+		},
+		Type:   ckb.FeeOpType,
+		Status: "Success",
+		Amount: &types.Amount{
+			Value:    new(big.Int).Neg(fee).String(),
+			Currency: CkbCurrency,
+		},
+	})
+	return fee, nil
+}
+
+func blockMetadata(block *ckbTypes.Block) (map[string]interface{}, *types.Error) {
+	metadata, err := types.MarshalMap(&ckb.BlockMetadata{
+		Epoch:          hexutil.Uint64(block.Header.Epoch),
+		CompactTarget:  hexutil.Uint64(block.Header.CompactTarget),
+		Dao:            block.Header.Dao.String(),
+		Nonce:          (*hexutil.Big)(block.Header.Nonce),
+		ProposalsCount: len(block.Proposals),
+	})
+	if err != nil {
+		return nil, wrapErr(ServerError, err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// tipClient counts the tip fetches
+type tipClient struct {
+	rpc.Client
+	tip   uint64
+	calls int
+}
+
+func (c *tipClient) GetTipBlockNumber(ctx context.Context) (uint64, error) {
+	c.calls++
+	return c.tip, nil
+}
+
+func TestCacheBlock(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Cache.Blocks = 10
+	cfg.Cache.Confirmations = 5
+	client := &tipClient{tip: 100}
+	s := NewBlockAPIService(&types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}, client, cfg)
+
+	steps := []struct {
+		name       string
+		tip        uint64
+		number     uint64
+		wantCached bool
+		wantCalls  int
+	}{
+		{name: "confirmed block fetches the tip", number: 90, wantCached: true, wantCalls: 1},
+		{name: "older block reuses the tip", number: 50, wantCached: true, wantCalls: 1},
+		{name: "deepest confirmed block reuses the tip", number: 95, wantCached: true, wantCalls: 1},
+		{name: "unconfirmed block fetches the tip", number: 96, wantCalls: 2},
+		{name: "block confirmed by a new tip", tip: 101, number: 96, wantCached: true, wantCalls: 3},
+		{name: "new tip is reused", number: 96, wantCached: true, wantCalls: 3},
+	}
+	for _, step := range steps {
+		if step.tip > 0 {
+			client.tip = step.tip
+		}
+		header := &ckbTypes.Header{Number: step.number, Hash: ckbTypes.BytesToHash([]byte{byte(step.number)})}
+		response := &types.BlockResponse{}
+		s.blockCache = newLRUCache(cfg.Cache.Blocks * 2)
+		s.cacheBlock(context.Background(), header, response)
+		_, cached := s.blockCache.Get(fmt.Sprintf("%d", step.number))
+		_, cachedByHash := s.blockCache.Get(header.Hash.String())
+		if cached != step.wantCached || cachedByHash != step.wantCached {
+			t.Errorf("%s: cached = %v %v, want %v", step.name, cached, cachedByHash, step.wantCached)
+		}
+		if client.calls != step.wantCalls {
+			t.Errorf("%s: tip fetches = %d, want %d", step.name, client.calls, step.wantCalls)
+		}
+	}
+}
+
+// daoClient serves the withdraw transaction of a withdrawing cell and its maximum withdraw
+type daoClient struct {
+	rpc.Client
+	withdrawTx      *ckbTypes.TransactionWithStatus
+	maximumWithdraw uint64
+}
+
+func (c *daoClient) GetTransaction(ctx context.Context, hash ckbTypes.Hash) (*ckbTypes.TransactionWithStatus, error) {
+	return c.withdrawTx, nil
+}
+
+func (c *daoClient) CalculateDaoMaximumWithdraw(ctx context.Context, point *ckbTypes.OutPoint, hash ckbTypes.Hash) (uint64, error) {
+	return c.maximumWithdraw, nil
+}
+
+func TestAppendDAOInterestOperation(t *testing.T) {
+	cfg := testConfig(t)
+	blockHash := testTxHash(100)
+	client := &daoClient{
+		withdrawTx: &ckbTypes.TransactionWithStatus{
+			Transaction: &ckbTypes.Transaction{
+				Inputs: []*ckbTypes.CellInput{{PreviousOutput: &ckbTypes.OutPoint{TxHash: testTxHash(1)}}},
+			},
+			TxStatus: &ckbTypes.TxStatus{BlockHash: &blockHash},
+		},
+		maximumWithdraw: 15000000000,
+	}
+	s := NewBlockAPIService(&types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}, client, cfg)
+	daoType := builder.GetDAOTypeScript(cfg)
+	input := &ckbTypes.CellInput{PreviousOutput: &ckbTypes.OutPoint{TxHash: testTxHash(2)}}
+
+	tests := []struct {
+		name         string
+		output       *ResolvedOutput
+		wantType     string
+		wantInterest string
+		wantFee      string
+	}{
+		{
+			name:     "plain cell",
+			output:   &ResolvedOutput{Output: &ckbTypes.CellOutput{Capacity: 10000000000}},
+			wantType: ckb.InputOpType,
+			wantFee:  "-1000",
+		},
+		{
+			name:     "deposit cell",
+			output:   &ResolvedOutput{Output: &ckbTypes.CellOutput{Capacity: 10000000000, Type: daoType}, Data: make([]byte, 8)},
+			wantType: ckb.InputOpType,
+			wantFee:  "-1000",
+		},
+		{
+			name:         "withdrawing cell",
+			output:       &ResolvedOutput{Output: &ckbTypes.CellOutput{Capacity: 10000000000, Type: daoType}, Data: ckbTypes.SerializeUint64(1)},
+			wantType:     ckb.DAOWithdrawOpType,
+			wantInterest: "5000000000",
+			wantFee:      "-5000001000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operationType := inputOperationType(tt.output.Output, tt.output.Data, cfg)
+			if operationType != tt.wantType {
+				t.Fatalf("inputOperationType() = %s, want %s", operationType, tt.wantType)
+			}
+			transaction := &types.Transaction{
+				Operations: []*types.Operation{{
+					OperationIdentifier: &types.OperationIdentifier{Index: 0},
+					Type:                operationType,
+					Amount:              &types.Amount{Value: fmt.Sprintf("-%d", tt.output.Output.Capacity), Currency: CkbCurrency},
+				}},
+			}
+			optIndex, rErr := s.appendDAOInterestOperation(context.Background(), transaction, 1, input, tt.output)
+			assertError(t, rErr, nil)
+			if tt.wantInterest == "" {
+				if optIndex != 1 || len(transaction.Operations) != 1 {
+					t.Fatalf("unexpected interest operation %v", transaction.Operations[len(transaction.Operations)-1])
+				}
+			} else {
+				interest := transaction.Operations[len(transaction.Operations)-1]
+				if optIndex != 2 || interest.Type != ckb.DAOInterestOpType || interest.Amount.Value != tt.wantInterest {
+					t.Fatalf("interest operation = %v, want %s", interest, tt.wantInterest)
+				}
+				if len(interest.RelatedOperations) != 1 || interest.RelatedOperations[0].Index != 0 {
+					t.Errorf("interest related operations = %v, want the DAO_WITHDRAW", interest.RelatedOperations)
+				}
+			}
+
+			transaction.Operations = append(transaction.Operations, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{Index: optIndex},
+				Type:                ckb.OutputOpType,
+				Amount:              &types.Amount{Value: "9999999000", Currency: CkbCurrency},
+			})
+			fee, rErr := appendFeeOperation(transaction, optIndex+1)
+			assertError(t, rErr, nil)
+			feeOperation := transaction.Operations[len(transaction.Operations)-1]
+			if feeOperation.Amount.Value != tt.wantFee || fee.String() != tt.wantFee[1:] {
+				t.Errorf("fee = %s %s, want %s", feeOperation.Amount.Value, fee.String(), tt.wantFee)
+			}
+		})
+	}
+}
+
+func TestOutputOperationType(t *testing.T) {
+	cfg := testConfig(t)
+	daoType := builder.GetDAOTypeScript(cfg)
+	tests := []struct {
+		name   string
+		output *ckbTypes.CellOutput
+		data   []byte
+		want   string
+	}{
+		{name: "plain cell", output: &ckbTypes.CellOutput{}, want: ckb.OutputOpType},
+		{name: "deposit cell", output: &ckbTypes.CellOutput{Type: daoType}, data: make([]byte, 8), want: ckb.DAODepositOpType},
+		{name: "withdrawing cell", output: &ckbTypes.CellOutput{Type: daoType}, data: ckbTypes.SerializeUint64(1), want: ckb.OutputOpType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outputOperationType(tt.output, tt.data, cfg); got != tt.want {
+				t.Errorf("outputOperationType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendUDTOperation(t *testing.T) {
+	cfg := testConfig(t)
+	s := NewBlockAPIService(&types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}, nil, cfg)
+	udtType := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.UDT.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.UDT.Script.HashType),
+		Args:     []byte{1},
+	}
+	amount, err := builder.ParseUDTAmount("25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udtData := builder.GenerateUDTAmountData(amount)
+
+	tests := []struct {
+		name        string
+		opType      string
+		output      *ckbTypes.CellOutput
+		data        []byte
+		wantAmount  string
+		wantInvalid bool
+	}{
+		{name: "output", opType: ckb.OutputOpType, output: &ckbTypes.CellOutput{Type: udtType}, data: udtData, wantAmount: "25"},
+		{name: "input", opType: ckb.InputOpType, output: &ckbTypes.CellOutput{Type: udtType}, data: udtData, wantAmount: "-25"},
+		{name: "amount followed by other data", opType: ckb.OutputOpType, output: &ckbTypes.CellOutput{Type: udtType}, data: append(append([]byte{}, udtData...), 1), wantAmount: "25"},
+		{name: "data shorter than an amount", opType: ckb.OutputOpType, output: &ckbTypes.CellOutput{Type: udtType}, data: udtData[:15], wantInvalid: true},
+		{name: "no data", opType: ckb.InputOpType, output: &ckbTypes.CellOutput{Type: udtType}, wantInvalid: true},
+		{name: "plain cell", opType: ckb.OutputOpType, output: &ckbTypes.CellOutput{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.output.Lock = &ckbTypes.Script{HashType: ckbTypes.HashTypeType, Args: []byte{}}
+			metadata, rErr := getCellOperationMetadata(tt.output, tt.data, cfg)
+			assertError(t, rErr, nil)
+			transaction := &types.Transaction{
+				Operations: []*types.Operation{{
+					OperationIdentifier: &types.OperationIdentifier{Index: 0},
+					Type:                tt.opType,
+					Status:              "Success",
+					Amount:              &types.Amount{Value: "14200000000", Currency: CkbCurrency},
+					Metadata:            metadata,
+				}},
+			}
+			optIndex, rErr := s.appendUDTOperation(transaction, 1, tt.output, tt.data)
+			assertError(t, rErr, nil)
+			if tt.wantAmount == "" {
+				if optIndex != 1 || len(transaction.Operations) != 1 {
+					t.Fatalf("unexpected sUDT operation %v", transaction.Operations[len(transaction.Operations)-1])
+				}
+			} else {
+				udtOperation := transaction.Operations[len(transaction.Operations)-1]
+				if optIndex != 2 || udtOperation.Type != tt.opType || udtOperation.Amount.Value != tt.wantAmount {
+					t.Fatalf("sUDT operation = %v, want %s %s", udtOperation, tt.opType, tt.wantAmount)
+				}
+			}
+			var operationMetadata ckb.OperationMetadata
+			if err := types.UnmarshalMap(transaction.Operations[0].Metadata, &operationMetadata); err != nil {
+				t.Fatal(err)
+			}
+			if operationMetadata.InvalidUDTData != tt.wantInvalid {
+				t.Errorf("invalid_udt_data = %v, want %v", operationMetadata.InvalidUDTData, tt.wantInvalid)
+			}
+		})
+	}
+}
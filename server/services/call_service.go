@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	ethRpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+const (
+	// DryRunTransactionMethod verifies the signed_transaction parameter against the tip without submitting it
+	DryRunTransactionMethod = "dry_run_transaction"
+)
+
+// CallAPIService implements the api.CallAPIServicer interface.
+type CallAPIService struct {
+	network *types.NetworkIdentifier
+	client  rpc.Client
+	cfg     *config.Config
+}
+
+// NewCallAPIService creates a new instance of a CallAPIService.
+func NewCallAPIService(network *types.NetworkIdentifier, client rpc.Client, cfg *config.Config) api.CallAPIServicer {
+	return &CallAPIService{
+		network: network,
+		client:  client,
+		cfg:     cfg,
+	}
+}
+
+// Call implements the /call endpoint.
+func (s *CallAPIService) Call(
+	ctx context.Context,
+	request *api.CallRequest,
+) (*api.CallResponse, *types.Error) {
+	switch request.Method {
+	case DryRunTransactionMethod:
+		signedTransaction, ok := request.Parameters["signed_transaction"].(string)
+		if !ok {
+			return nil, wrapErr(InvalidCallParametersError, fmt.Errorf("%s requires the signed_transaction string parameter", request.Method))
+		}
+		tx, err := ToTransaction(signedTransaction)
+		if err != nil {
+			return nil, wrapErr(InvalidCallParametersError, err)
+		}
+		cycles, rErr := dryRunTransaction(ctx, s.client, tx)
+		if rErr != nil {
+			return nil, rErr
+		}
+		// the result depends on the live cells at the tip
+		return &api.CallResponse{
+			Result: map[string]interface{}{
+				"cycles": cycles,
+			},
+			Idempotent: false,
+		}, nil
+	default:
+		return nil, wrapErr(UnsupportedCallMethodError, fmt.Errorf("method %s not supported", request.Method))
+	}
+}
+
+// dryRunTransaction returns the cycles the scripts of the transaction consume
+func dryRunTransaction(ctx context.Context, client rpc.Client, tx *ckbTypes.Transaction) (uint64, *types.Error) {
+	result, err := client.DryRunTransaction(ctx, tx)
+	if err != nil {
+		return 0, verificationErr(err)
+	}
+	return result.Cycles, nil
+}
+
+// verificationErr maps the rejection of a transaction by the node to a Rosetta error, errors of the rpc
+// connection are RpcError.
+func verificationErr(err error) *types.Error {
+	if _, ok := err.(ethRpc.Error); !ok {
+		return wrapErr(RpcError, err)
+	}
+	message := err.Error()
+	containsAny := func(substrs ...string) bool {
+		for _, substr := range substrs {
+			if strings.Contains(message, substr) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case containsAny("PoolRejectedDuplicatedTransaction", "already exist in transaction_pool"):
+		return wrapErr(DuplicatedTransactionError, err)
+	case containsAny("PoolIsFull"):
+		return wrapErr(TxPoolFullError, err)
+	case containsAny("PoolRejectedTransactionByMinFeeRate", "LowFeeRate"):
+		return wrapErr(FeeTooLowError, err)
+	case containsAny("Dead("):
+		return wrapErr(DeadCellError, err)
+	case containsAny("Unknown("):
+		return wrapErr(UnknownCellError, err)
+	case containsAny("InsufficientCellCapacity", "OutputsSumOverflow", "CapacityOverflow"):
+		return wrapErr(InsufficientCapacityError, err)
+	case containsAny("ValidationFailure", "Script("):
+		return wrapErr(ScriptVerificationError, err)
+	default:
+		return wrapErr(SubmitError, err)
+	}
+}
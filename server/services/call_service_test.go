@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	ckbRpc "github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// rpcError is an error returned by the node
+type rpcError string
+
+func (e rpcError) Error() string {
+	return string(e)
+}
+
+func (e rpcError) ErrorCode() int {
+	return -301
+}
+
+// submitClient dry runs and sends transactions, recording the sent ones
+type submitClient struct {
+	Client
+	dryRunErr error
+	sendErr   error
+	sent      int
+}
+
+func (c *submitClient) DryRunTransaction(ctx context.Context, tx *ckbTypes.Transaction) (*ckbTypes.DryRunTransactionResult, error) {
+	if c.dryRunErr != nil {
+		return nil, c.dryRunErr
+	}
+	return &ckbTypes.DryRunTransactionResult{Cycles: 1000}, nil
+}
+
+func (c *submitClient) SendTransaction(ctx context.Context, tx *ckbTypes.Transaction) (*ckbTypes.Hash, error) {
+	if c.sendErr != nil {
+		return nil, c.sendErr
+	}
+	c.sent++
+	hash := testTxHash(1)
+	return &hash, nil
+}
+
+func testSignedTransaction(t *testing.T) string {
+	tx := &ckbTypes.Transaction{
+		CellDeps:   []*ckbTypes.CellDep{},
+		HeaderDeps: []ckbTypes.Hash{},
+		Inputs: []*ckbTypes.CellInput{{
+			PreviousOutput: &ckbTypes.OutPoint{TxHash: testTxHash(2), Index: 0},
+		}},
+		Outputs: []*ckbTypes.CellOutput{{
+			Capacity: 10000000000,
+			Lock:     &ckbTypes.Script{HashType: ckbTypes.HashTypeType, Args: []byte{}},
+		}},
+		OutputsData: [][]byte{{}},
+		Witnesses:   [][]byte{{}},
+	}
+	signedTransaction, err := ckbRpc.TransactionString(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signedTransaction
+}
+
+func TestVerificationErr(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		want          *types.Error
+		wantRetriable bool
+	}{
+		{name: "connection error", err: errors.New("connection refused"), want: RpcError, wantRetriable: true},
+		{name: "duplicated transaction", err: rpcError("PoolRejectedDuplicatedTransaction"), want: DuplicatedTransactionError},
+		{name: "pool full", err: rpcError("PoolIsFull"), want: TxPoolFullError, wantRetriable: true},
+		{name: "low fee rate", err: rpcError("PoolRejectedTransactionByMinFeeRate"), want: FeeTooLowError},
+		{name: "dead cell", err: rpcError("OutPoint: Dead(OutPoint(0x01))"), want: DeadCellError},
+		{name: "unknown cell", err: rpcError("OutPoint: Unknown([OutPoint(0x01)])"), want: UnknownCellError, wantRetriable: true},
+		{name: "insufficient capacity", err: rpcError("InsufficientCellCapacity"), want: InsufficientCapacityError},
+		{name: "script failure", err: rpcError("Script(ValidationFailure(-31))"), want: ScriptVerificationError},
+		{name: "other rejection", err: rpcError("Malformed"), want: SubmitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rErr := verificationErr(tt.err)
+			assertError(t, rErr, tt.want)
+			if rErr.Retriable != tt.wantRetriable {
+				t.Errorf("retriable = %v, want %v", rErr.Retriable, tt.wantRetriable)
+			}
+		})
+	}
+}
+
+func TestConstructionSubmit(t *testing.T) {
+	signedTransaction := testSignedTransaction(t)
+
+	tests := []struct {
+		name      string
+		dryRun    bool
+		dryRunErr error
+		sendErr   error
+		wantErr   *types.Error
+		wantSent  int
+	}{
+		{name: "without dry run", wantSent: 1},
+		{name: "dry run passes", dryRun: true, wantSent: 1},
+		{name: "dry run does not see the tx pool", dryRun: true, dryRunErr: rpcError("OutPoint: Unknown([OutPoint(0x01)])"), wantSent: 1},
+		{name: "dry run rejects", dryRun: true, dryRunErr: rpcError("OutPoint: Dead(OutPoint(0x01))"), wantErr: DeadCellError},
+		{name: "dry run is not asked", dryRunErr: rpcError("OutPoint: Dead(OutPoint(0x01))"), wantSent: 1},
+		{name: "node rejects", sendErr: rpcError("OutPoint: Unknown([OutPoint(0x01)])"), wantErr: UnknownCellError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.DryRunOnSubmit = tt.dryRun
+			client := &submitClient{dryRunErr: tt.dryRunErr, sendErr: tt.sendErr}
+			s := NewConstructionAPIService(&types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}, client, cfg)
+			_, rErr := s.ConstructionSubmit(context.Background(), &types.ConstructionSubmitRequest{SignedTransaction: signedTransaction})
+			assertError(t, rErr, tt.wantErr)
+			if client.sent != tt.wantSent {
+				t.Errorf("sent = %d, want %d", client.sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestCall(t *testing.T) {
+	cfg := testConfig(t)
+	signedTransaction := testSignedTransaction(t)
+
+	tests := []struct {
+		name       string
+		request    *api.CallRequest
+		dryRunErr  error
+		wantErr    *types.Error
+		wantCycles uint64
+	}{
+		{
+			name:       "dry run",
+			request:    &api.CallRequest{Method: DryRunTransactionMethod, Parameters: map[string]interface{}{"signed_transaction": signedTransaction}},
+			wantCycles: 1000,
+		},
+		{
+			name:      "dry run rejects",
+			request:   &api.CallRequest{Method: DryRunTransactionMethod, Parameters: map[string]interface{}{"signed_transaction": signedTransaction}},
+			dryRunErr: rpcError("Script(ValidationFailure(-31))"),
+			wantErr:   ScriptVerificationError,
+		},
+		{
+			name:    "missing transaction",
+			request: &api.CallRequest{Method: DryRunTransactionMethod, Parameters: map[string]interface{}{}},
+			wantErr: InvalidCallParametersError,
+		},
+		{
+			name:    "invalid transaction",
+			request: &api.CallRequest{Method: DryRunTransactionMethod, Parameters: map[string]interface{}{"signed_transaction": "{"}},
+			wantErr: InvalidCallParametersError,
+		},
+		{
+			name:    "unsupported method",
+			request: &api.CallRequest{Method: "get_tip_header"},
+			wantErr: UnsupportedCallMethodError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &submitClient{dryRunErr: tt.dryRunErr}
+			s := NewCallAPIService(&types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}, client, cfg)
+			response, rErr := s.Call(context.Background(), tt.request)
+			assertError(t, rErr, tt.wantErr)
+			if tt.wantErr == nil && response.Result["cycles"] != tt.wantCycles {
+				t.Errorf("cycles = %v, want %d", response.Result["cycles"], tt.wantCycles)
+			}
+		})
+	}
+}
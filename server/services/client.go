@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethRpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// Client extends rpc.Client with the CKB node RPC methods which ckb-sdk-go does not wrap yet.
+type Client interface {
+	rpc.Client
+
+	// GetRawTxPool returns the hashes of the pending and proposed transactions in the tx pool.
+	GetRawTxPool(ctx context.Context) (*RawTxPool, error)
+
+	// GetRawTxPoolVerbose returns the pending and proposed transactions in the tx pool with their size and fee.
+	GetRawTxPoolVerbose(ctx context.Context) (*RawTxPoolVerbose, error)
+}
+
+type RawTxPool struct {
+	Pending  []ckbTypes.Hash `json:"pending"`
+	Proposed []ckbTypes.Hash `json:"proposed"`
+}
+
+type RawTxPoolVerbose struct {
+	Pending  map[ckbTypes.Hash]*TxPoolEntry `json:"pending"`
+	Proposed map[ckbTypes.Hash]*TxPoolEntry `json:"proposed"`
+}
+
+type TxPoolEntry struct {
+	Cycles hexutil.Uint64 `json:"cycles"`
+	Size   hexutil.Uint64 `json:"size"`
+	Fee    hexutil.Uint64 `json:"fee"`
+}
+
+type client struct {
+	rpc.Client
+	c *ethRpc.Client
+}
+
+// DialWithIndexer connects to the CKB node and the ckb-indexer.
+func DialWithIndexer(ckbUrl string, indexerUrl string) (Client, error) {
+	c, err := ethRpc.Dial(ckbUrl)
+	if err != nil {
+		return nil, err
+	}
+	index, err := indexer.Dial(indexerUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		Client: rpc.NewClientWithIndexer(c, index),
+		c:      c,
+	}, nil
+}
+
+func (cli *client) GetRawTxPool(ctx context.Context) (*RawTxPool, error) {
+	var result RawTxPool
+	err := cli.c.CallContext(ctx, &result, "get_raw_tx_pool")
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (cli *client) GetRawTxPoolVerbose(ctx context.Context) (*RawTxPoolVerbose, error) {
+	var result RawTxPoolVerbose
+	err := cli.c.CallContext(ctx, &result, "get_raw_tx_pool", true)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
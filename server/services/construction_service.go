@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/coinbase/rosetta-sdk-go/server"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -10,7 +11,6 @@ import (
 	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 	"github.com/nervosnetwork/ckb-sdk-go/address"
 	"github.com/nervosnetwork/ckb-sdk-go/crypto/blake2b"
-	"github.com/nervosnetwork/ckb-sdk-go/rpc"
 	ckbRpc "github.com/nervosnetwork/ckb-sdk-go/rpc"
 	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
 )
@@ -18,17 +18,25 @@ import (
 // ConstructionAPIService implements the server.ConstructionAPIService interface.
 type ConstructionAPIService struct {
 	network *types.NetworkIdentifier
-	client  rpc.Client
+	client  Client
 	cfg     *config.Config
+	// outputResolver and txPool are nil in offline mode
+	outputResolver OutputResolver
+	txPool         *txPoolCache
 }
 
 // NewConstructionAPIService creates a new instance of a ConstructionAPIService.
-func NewConstructionAPIService(network *types.NetworkIdentifier, client rpc.Client, cfg *config.Config) server.ConstructionAPIServicer {
-	return &ConstructionAPIService{
+func NewConstructionAPIService(network *types.NetworkIdentifier, client Client, cfg *config.Config) server.ConstructionAPIServicer {
+	s := &ConstructionAPIService{
 		network: network,
 		client:  client,
 		cfg:     cfg,
 	}
+	if client != nil {
+		s.outputResolver = NewOutputResolver(client, cfg)
+		s.txPool = newTxPoolCache(client, txPoolCacheInterval)
+	}
+	return s
 }
 
 // ConstructionPreprocess implements the /construction/preprocess endpoint.
@@ -36,6 +44,16 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 	ctx context.Context,
 	request *types.ConstructionPreprocessRequest,
 ) (*types.ConstructionPreprocessResponse, *types.Error) {
+	var preprocessMetadata ckb.PreprocessMetadata
+	if request.Metadata != nil {
+		if err := types.UnmarshalMap(request.Metadata, &preprocessMetadata); err != nil {
+			return nil, wrapErr(InvalidPreprocessMetadataError, err)
+		}
+		if preprocessMetadata.Sender != "" && len(getInputOperations(request.Operations)) == 0 {
+			return s.preprocessWithInputSelection(request, preprocessMetadata.Sender)
+		}
+	}
+
 	inputTotalAmount, validateErr := validateInputOperations(request.Operations, s.cfg)
 	if validateErr != nil {
 		return nil, validateErr
@@ -46,20 +64,51 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 		return nil, validateErr
 	}
 
-	validateErr = validateCapacity(inputTotalAmount, outputTotalAmount)
+	constructionType, validateErr := getConstructionType(request.Operations, nil, s.cfg)
 	if validateErr != nil {
 		return nil, validateErr
 	}
-	constructionType, validateErr := getConstructionType(request.Operations, nil, s.cfg)
+
+	// the interest of withdrawing cells is only known once /construction/metadata resolves them
+	if constructionType != ckb.UnlockDAO {
+		validateErr = validateCapacity(inputTotalAmount, outputTotalAmount)
+		if validateErr != nil {
+			return nil, validateErr
+		}
+	}
+
+	validateErr = validateUDTAmounts(request.Operations, s.cfg)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+
+	validateErr = validateACPTopUp(request.Operations, s.cfg)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+	daoInputs, validateErr := getDAOInputCoins(request.Operations)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+	validateErr = validateCellDeps(preprocessMetadata.CellDeps, constructionType)
 	if validateErr != nil {
 		return nil, validateErr
 	}
 	txSizeEstimatorFactory := new(factory.TxSizeEstimatorFactory)
-	txSizeEstimator := txSizeEstimatorFactory.CreateTxSizeEstimator(constructionType)
+	txSizeEstimator := txSizeEstimatorFactory.CreateTxSizeEstimator(constructionType, s.cfg)
 	if txSizeEstimator == nil {
 		return nil, wrapErr(UnsupportedConstructionTypeError, fmt.Errorf("unsupported construction type: %s", constructionType))
 	}
-	estimatedTxSize, err := txSizeEstimator.EstimatedTxSize(request.Operations)
+	headerDeps, placeholderInputs, validateErr := placeholderDAOInputs(request.Operations)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+	estimatedTxSize, err := txSizeEstimator.EstimatedTxSize(request.Operations, &ckb.ConstructionMetadata{
+		ConstructionType: constructionType,
+		HeaderDeps:       headerDeps,
+		DAOInputs:        placeholderInputs,
+		CellDeps:         preprocessMetadata.CellDeps,
+	})
 	if err != nil {
 		return nil, wrapErr(DataParseError, err)
 	}
@@ -68,6 +117,8 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 		ConstructionType:       constructionType,
 		EstimatedTxSize:        estimatedTxSize,
 		SuggestedFeeMultiplier: request.SuggestedFeeMultiplier,
+		DAOInputs:              daoInputs,
+		CellDeps:               preprocessMetadata.CellDeps,
 	})
 	if err != nil {
 		return nil, InvalidPreprocessOptionsError
@@ -83,6 +134,9 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 	ctx context.Context,
 	request *types.ConstructionMetadataRequest,
 ) (*types.ConstructionMetadataResponse, *types.Error) {
+	if s.cfg.Offline {
+		return nil, UnavailableOfflineError
+	}
 	if request.Options == nil {
 		return nil, MissingOptionError
 	}
@@ -94,20 +148,42 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 	if !SupportedConstructionTypes[options.ConstructionType] {
 		return nil, wrapErr(UnsupportedConstructionTypeError, fmt.Errorf("unsupported construction type: %s", options.ConstructionType))
 	}
-	shannonsPerKB := float64(ckb.MinFeeRate)
+	shannonsPerKB := float64(s.suggestedFeeRate(ctx))
 	if options.SuggestedFeeMultiplier != nil {
 		shannonsPerKB *= *options.SuggestedFeeMultiplier
 	}
 	shannonsPerB := shannonsPerKB / ckb.BytesInKb
 	estimatedFee := shannonsPerB * float64(options.EstimatedTxSize)
+	constructionMetadata := &ckb.ConstructionMetadata{
+		ConstructionType: options.ConstructionType,
+		FeeRate:          uint64(shannonsPerKB),
+		CellDeps:         options.CellDeps,
+	}
+	if options.Sender != "" {
+		inputs, change, fee, rErr := s.selectInputs(ctx, &options, shannonsPerB)
+		if rErr != nil {
+			return nil, rErr
+		}
+		estimatedFee = float64(fee)
+		constructionMetadata.Sender = options.Sender
+		constructionMetadata.Inputs = inputs
+		constructionMetadata.Change = fmt.Sprintf("%d", change)
+		constructionMetadata.Fee = fmt.Sprintf("%d", fee)
+	}
+	if len(options.DAOInputs) > 0 {
+		headerDeps, daoInputs, rErr := s.resolveDAOInputs(ctx, &options)
+		if rErr != nil {
+			return nil, rErr
+		}
+		constructionMetadata.HeaderDeps = headerDeps
+		constructionMetadata.DAOInputs = daoInputs
+	}
 	suggestedFee := &types.Amount{
 		Value:    fmt.Sprintf("%d", uint64(estimatedFee)),
 		Currency: CkbCurrency,
 	}
 
-	metadata, err := types.MarshalMap(&ckb.ConstructionMetadata{
-		ConstructionType: options.ConstructionType,
-	})
+	metadata, err := types.MarshalMap(constructionMetadata)
 	if err != nil {
 		return nil, InvalidConstructionMetadataError
 	}
@@ -123,28 +199,56 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 	ctx context.Context,
 	request *types.ConstructionPayloadsRequest,
 ) (*types.ConstructionPayloadsResponse, *types.Error) {
-	inputTotalAmount, validateErr := validateInputOperations(request.Operations, s.cfg)
+	var constructionMetadata ckb.ConstructionMetadata
+	if err := types.UnmarshalMap(request.Metadata, &constructionMetadata); err != nil {
+		return nil, InvalidConstructionMetadataError
+	}
+	operations := request.Operations
+	if len(constructionMetadata.Inputs) > 0 {
+		var validateErr *types.Error
+		operations, validateErr = withSelectedInputs(request.Operations, &constructionMetadata)
+		if validateErr != nil {
+			return nil, validateErr
+		}
+	}
+
+	inputTotalAmount, validateErr := validateInputOperations(operations, s.cfg)
 	if validateErr != nil {
 		return nil, validateErr
 	}
 
-	outputTotalAmount, validateErr := validateOutputOperations(request.Operations, s.cfg)
+	outputTotalAmount, validateErr := validateOutputOperations(operations, s.cfg)
 	if validateErr != nil {
 		return nil, validateErr
 	}
 
+	if constructionMetadata.ConstructionType == ckb.UnlockDAO {
+		interest, validateErr := getDAOInterest(operations, &constructionMetadata)
+		if validateErr != nil {
+			return nil, validateErr
+		}
+		inputTotalAmount += interest
+	}
+
 	validateErr = validateCapacity(inputTotalAmount, outputTotalAmount)
 	if validateErr != nil {
 		return nil, validateErr
 	}
 
-	constructionType, validateErr := validateConstructionType(request.Metadata)
+	validateErr = validateUDTAmounts(operations, s.cfg)
 	if validateErr != nil {
 		return nil, validateErr
 	}
+
+	validateErr = validateACPTopUp(operations, s.cfg)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+
+	constructionType := constructionMetadata.ConstructionType
 	unsignedTxBuilderFactory := factory.UnsignedTxBuilderFactory{}
-	inputOperations, outputOperations := separateInputAndOutput(request.Operations)
-	unsignedTxBuilder := unsignedTxBuilderFactory.CreateUnsignedTxBuilder(constructionType, s.cfg, inputOperations, outputOperations)
+	inputOperations, outputOperations := separateInputAndOutput(operations)
+	unsignedTxBuilder := unsignedTxBuilderFactory.CreateUnsignedTxBuilder(constructionType, s.cfg, inputOperations, outputOperations, &constructionMetadata)
 	if unsignedTxBuilder == nil {
 		return nil, wrapErr(UnsupportedConstructionTypeError, fmt.Errorf("unsupported construction type: %s", constructionType))
 	}
@@ -159,7 +263,7 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		return nil, wrapErr(SigningPayloadBuildError, err)
 	}
 	txString, err := ckbRpc.TransactionString(unsignedTx)
-	rTxStr, validateErr := rTxStringForPayload(txString, request.Operations)
+	rTxStr, validateErr := rTxStringForPayload(txString, operations)
 	return &types.ConstructionPayloadsResponse{
 		UnsignedTransaction: rTxStr,
 		Payloads:            payloads,
@@ -235,14 +339,23 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 	ctx context.Context,
 	request *types.ConstructionSubmitRequest,
 ) (*types.TransactionIdentifierResponse, *types.Error) {
+	if s.cfg.Offline {
+		return nil, UnavailableOfflineError
+	}
 	tx, err := ToTransaction(request.SignedTransaction)
 	if err != nil {
 		return nil, wrapErr(SubmitError, err)
 	}
+	// the dry run only sees committed cells, the tx pool may still know the cells it reports as unknown
+	if s.cfg.DryRunOnSubmit {
+		if _, rErr := dryRunTransaction(ctx, s.client, tx); rErr != nil && rErr.Code != UnknownCellError.Code {
+			return nil, rErr
+		}
+	}
 
 	hash, err := s.client.SendTransaction(ctx, tx)
 	if err != nil {
-		return nil, wrapErr(SubmitError, err)
+		return nil, verificationErr(err)
 	}
 
 	return &types.TransactionIdentifierResponse{
@@ -266,12 +379,9 @@ func (s *ConstructionAPIService) ConstructionDerive(
 		return nil, wrapErr(ServerError, err)
 	}
 
-	if _, ok := SupportedNetworks[s.network.Network]; !ok {
-		return nil, wrapErr(UnsupportedNetworkError, fmt.Errorf("network %s not supported", s.network.Network))
-	}
-	prefix := address.Mainnet
-	if s.network.Network != "mainnet" {
-		prefix = address.Testnet
+	prefix, err := AddressMode(s.network)
+	if err != nil {
+		return nil, wrapErr(UnsupportedNetworkError, err)
 	}
 
 	var script *ckbTypes.Script
@@ -281,9 +391,29 @@ func (s *ConstructionAPIService) ConstructionDerive(
 		if err := types.UnmarshalMap(request.Metadata, &metadata); err != nil {
 			return nil, wrapErr(InvalidDeriveMetadataError, err)
 		}
-		script, err = toScript(metadata.Script)
-		if err != nil {
-			return nil, wrapErr(ServerError, err)
+		if metadata.MultisigScript != nil {
+			multisigArgs, err := metadata.MultisigScript.Args()
+			if err != nil {
+				return nil, wrapErr(InvalidMultisigScriptError, err)
+			}
+			script = &ckbTypes.Script{
+				CodeHash: ckbTypes.HexToHash(s.cfg.Secp256k1Blake160Mutisig.Script.CodeHash),
+				HashType: ckbTypes.ScriptHashType(s.cfg.Secp256k1Blake160Mutisig.Script.HashType),
+				Args:     multisigArgs,
+			}
+		} else if metadata.LockType == ckb.AnyoneCanPay.String() {
+			script = &ckbTypes.Script{
+				CodeHash: ckbTypes.HexToHash(s.cfg.ACP.Script.CodeHash),
+				HashType: ckbTypes.ScriptHashType(s.cfg.ACP.Script.HashType),
+				Args:     args,
+			}
+		} else if metadata.LockType != "" {
+			return nil, wrapErr(InvalidDeriveMetadataError, fmt.Errorf("unsupported lock type: %s", metadata.LockType))
+		} else {
+			script, err = toScript(metadata.Script)
+			if err != nil {
+				return nil, wrapErr(ServerError, err)
+			}
 		}
 		lockType = getLockType(script, s.cfg)
 	} else {
@@ -319,26 +449,66 @@ func (s *ConstructionAPIService) parseTransaction(request *types.ConstructionPar
 	}
 	var operations []*types.Operation
 
+	// the input cells are only known online, offline the input operations carry no cell metadata
+	var previousOutputs map[string]*ResolvedOutput
+	if s.outputResolver != nil {
+		outPoints := make([]*ckbTypes.OutPoint, len(signedTx.Inputs))
+		for i, input := range signedTx.Inputs {
+			outPoints[i] = input.PreviousOutput
+		}
+		var rErr *types.Error
+		previousOutputs, rErr = s.outputResolver.Resolve(outPoints)
+		if rErr != nil {
+			return nil, rErr
+		}
+	}
+	if len(signedTx.InputAccounts) != len(signedTx.Inputs) || len(signedTx.InputAmounts) != len(signedTx.Inputs) {
+		if rErr := s.resolveInputAccounts(signedTx, previousOutputs); rErr != nil {
+			return nil, rErr
+		}
+	}
+	if len(signedTx.OutputAccounts) != len(signedTx.Outputs) || len(signedTx.OutputAmounts) != len(signedTx.Outputs) {
+		return nil, wrapErr(TransactionParseError, errors.New("output accounts and amounts do not match the outputs"))
+	}
+	if len(signedTx.OutputsData) != len(signedTx.Outputs) {
+		return nil, wrapErr(TransactionParseError, errors.New("outputs data do not match the outputs"))
+	}
 	for i, input := range signedTx.Inputs {
+		previousOutput := previousOutputs[outPointKey(input.PreviousOutput)]
+		operationMetadata, rErr := getInputOperationMetadata(previousOutput, input.Since, s.cfg)
+		if rErr != nil {
+			return nil, rErr
+		}
+		// offline the withdrawing cells are not known, they are parsed as INPUT
+		operationType := ckb.InputOpType
+		if previousOutput != nil {
+			operationType = inputOperationType(previousOutput.Output, previousOutput.Data, s.cfg)
+		}
 		operations = append(operations, &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{Index: int64(len(operations))},
-			Type:                ckb.InputOpType,
+			Type:                operationType,
 			Account:             signedTx.InputAccounts[i],
 			Amount:              signedTx.InputAmounts[i],
 			CoinChange: &types.CoinChange{
 				CoinIdentifier: getCoinIdentifier(input.PreviousOutput),
 				CoinAction:     types.CoinSpent,
 			},
+			Metadata: operationMetadata,
 		})
 	}
-	for i := range signedTx.Outputs {
+	for i, output := range signedTx.Outputs {
+		operationMetadata, rErr := getCellOperationMetadata(output, signedTx.OutputsData[i], s.cfg)
+		if rErr != nil {
+			return nil, rErr
+		}
 		operations = append(operations, &types.Operation{
 			OperationIdentifier: &types.OperationIdentifier{
 				Index: int64(len(operations)),
 			},
-			Type:    ckb.OutputOpType,
-			Account: signedTx.OutputAccounts[i],
-			Amount:  signedTx.OutputAmounts[i],
+			Type:     outputOperationType(output, signedTx.OutputsData[i], s.cfg),
+			Account:  signedTx.OutputAccounts[i],
+			Amount:   signedTx.OutputAmounts[i],
+			Metadata: operationMetadata,
 		})
 	}
 
@@ -347,3 +517,36 @@ func (s *ConstructionAPIService) parseTransaction(request *types.ConstructionPar
 		AccountIdentifierSigners: signedTx.AccountIdentifierSigners,
 	}, nil
 }
+
+// resolveInputAccounts fills the input accounts and amounts of a transaction which does not carry them
+// from the previous outputs of its inputs.
+func (s *ConstructionAPIService) resolveInputAccounts(tx *rosettaTransaction, previousOutputs map[string]*ResolvedOutput) *types.Error {
+	if previousOutputs == nil {
+		return wrapErr(TransactionParseError, errors.New("input accounts and amounts do not match the inputs"))
+	}
+
+	tx.InputAccounts = make([]*types.AccountIdentifier, len(tx.Inputs))
+	tx.InputAmounts = make([]*types.Amount, len(tx.Inputs))
+	for i, input := range tx.Inputs {
+		previousOutput := previousOutputs[outPointKey(input.PreviousOutput)]
+		accountMetadata, err := types.MarshalMap(&ckb.AccountIdentifierMetadata{
+			LockType: getLockType(previousOutput.Output.Lock, s.cfg),
+		})
+		if err != nil {
+			return wrapErr(InvalidAccountIdentifierMetadataError, err)
+		}
+		addr, err := GenerateAddress(s.network, previousOutput.Output.Lock)
+		if err != nil {
+			return wrapErr(AddressParseError, err)
+		}
+		tx.InputAccounts[i] = &types.AccountIdentifier{
+			Address:  addr,
+			Metadata: accountMetadata,
+		}
+		tx.InputAmounts[i] = &types.Amount{
+			Value:    fmt.Sprintf("-%d", previousOutput.Output.Capacity),
+			Currency: CkbCurrency,
+		}
+	}
+	return nil
+}
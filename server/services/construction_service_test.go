@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	ckbTransaction "github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// resolvedOutputs resolves the cells it holds and leaves the others out
+type resolvedOutputs map[string]*ResolvedOutput
+
+func (r resolvedOutputs) Resolve(outPoints []*ckbTypes.OutPoint) (map[string]*ResolvedOutput, *types.Error) {
+	return r, nil
+}
+
+func TestParseTransactionDAO(t *testing.T) {
+	cfg := testConfig(t)
+	lock := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(ckbTransaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{1}, 20),
+	}
+	account := &types.AccountIdentifier{Address: testAddress(t, lock)}
+	daoType := builder.GetDAOTypeScript(cfg)
+	withdrawing := &ckbTypes.OutPoint{TxHash: testTxHash(1), Index: 0}
+	plain := &ckbTypes.OutPoint{TxHash: testTxHash(2), Index: 1}
+	amount := func(value string) *types.Amount {
+		return &types.Amount{Value: value, Currency: CkbCurrency}
+	}
+	tx, err := json.Marshal(inRosettaTransaction{
+		Inputs: fromInputs([]*ckbTypes.CellInput{{PreviousOutput: withdrawing}, {PreviousOutput: plain}}),
+		Outputs: fromOutputs([]*ckbTypes.CellOutput{
+			{Capacity: 10000000000, Lock: lock, Type: daoType},
+			{Capacity: 9000000000, Lock: lock},
+		}),
+		OutputsData:    fromBytesArray([][]byte{make([]byte, 8), {}}),
+		Witnesses:      fromBytesArray([][]byte{{}, {}}),
+		InputAmounts:   []*types.Amount{amount("-10000000000"), amount("-9000001000")},
+		InputAccounts:  []*types.AccountIdentifier{account, account},
+		OutputAmounts:  []*types.Amount{amount("10000000000"), amount("9000000000")},
+		OutputAccounts: []*types.AccountIdentifier{account, account},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		outputResolver OutputResolver
+		want           []string
+	}{
+		{
+			name: "online",
+			outputResolver: resolvedOutputs{
+				outPointKey(withdrawing): {Output: &ckbTypes.CellOutput{Capacity: 10000000000, Lock: lock, Type: daoType}, Data: ckbTypes.SerializeUint64(100)},
+				outPointKey(plain):       {Output: &ckbTypes.CellOutput{Capacity: 9000001000, Lock: lock}, Data: []byte{}},
+			},
+			want: []string{ckb.DAOWithdrawOpType, ckb.InputOpType, ckb.DAODepositOpType, ckb.OutputOpType},
+		},
+		{
+			name: "offline",
+			want: []string{ckb.InputOpType, ckb.InputOpType, ckb.DAODepositOpType, ckb.OutputOpType},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ConstructionAPIService{cfg: cfg, outputResolver: tt.outputResolver}
+			response, rErr := s.parseTransaction(&types.ConstructionParseRequest{Transaction: string(tx)})
+			assertError(t, rErr, nil)
+			if len(response.Operations) != len(tt.want) {
+				t.Fatalf("operations = %d, want %d", len(response.Operations), len(tt.want))
+			}
+			for i, operation := range response.Operations {
+				if operation.Type != tt.want[i] {
+					t.Errorf("operation %d type = %s, want %s", i, operation.Type, tt.want[i])
+				}
+			}
+
+			// the parsed operations are split into the inputs and outputs they were parsed from
+			inputOperations, outputOperations := separateInputAndOutput(response.Operations)
+			if len(inputOperations) != 2 || len(outputOperations) != 2 {
+				t.Errorf("inputs %d outputs %d, want 2 of each", len(inputOperations), len(outputOperations))
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// getDAOInputCoins returns the coin identifiers of the DAO cells spent by the operations
+func getDAOInputCoins(operations []*types.Operation) ([]string, *types.Error) {
+	var coins []string
+	for _, operation := range getInputOperations(operations) {
+		cellType, err := builder.GetOperationCellType(operation)
+		if err != nil {
+			return nil, wrapErr(DataParseError, err)
+		}
+		if cellType == ckb.DAODepositCell.String() || cellType == ckb.DAOWithdrawingCell.String() {
+			coins = append(coins, operation.CoinChange.CoinIdentifier.Identifier)
+		}
+	}
+	return coins, nil
+}
+
+// resolveDAOInputs looks up the blocks the DAO cells of options were committed in, a deposit cell depends on its
+// deposit block, a withdrawing cell on its deposit block and its withdraw block.
+func (s *ConstructionAPIService) resolveDAOInputs(ctx context.Context, options *ckb.PreprocessOptions) ([]string, []*ckb.DAOInput, *types.Error) {
+	var headerDeps []string
+	headerDepIndex := func(hash ckbTypes.Hash) uint64 {
+		for i, headerDep := range headerDeps {
+			if headerDep == hash.String() {
+				return uint64(i)
+			}
+		}
+		headerDeps = append(headerDeps, hash.String())
+		return uint64(len(headerDeps) - 1)
+	}
+
+	var daoInputs []*ckb.DAOInput
+	for _, coin := range options.DAOInputs {
+		outPoint, err := builder.GenerateOutPointFromCoinIdentifier(coin)
+		if err != nil {
+			return nil, nil, wrapErr(CoinIdentifierInvalidError, err)
+		}
+		tx, output, data, rErr := s.committedOutput(ctx, outPoint)
+		if rErr != nil {
+			return nil, nil, rErr
+		}
+		cellType := getCellType(output, data, s.cfg)
+		daoInput := &ckb.DAOInput{
+			CoinIdentifier: coin,
+			Capacity:       strconv.FormatUint(output.Capacity, 10),
+		}
+
+		switch {
+		case options.ConstructionType == ckb.WithdrawDAO && cellType == ckb.DAODepositCell:
+			header, err := s.client.GetHeader(ctx, *tx.TxStatus.BlockHash)
+			if err != nil {
+				return nil, nil, wrapErr(RpcError, err)
+			}
+			daoInput.DepositBlockNumber = header.Number
+			daoInput.DepositHeaderIndex = headerDepIndex(header.Hash)
+		case options.ConstructionType == ckb.UnlockDAO && cellType == ckb.DAOWithdrawingCell:
+			// the deposit cell is the input at the index of the withdrawing cell in the withdraw transaction
+			if int(outPoint.Index) >= len(tx.Transaction.Inputs) {
+				return nil, nil, wrapErr(InvalidDAOCellError, fmt.Errorf("withdrawing cell %s has no deposit input", coin))
+			}
+			depositOutPoint := tx.Transaction.Inputs[outPoint.Index].PreviousOutput
+			depositTx, _, _, rErr := s.committedOutput(ctx, depositOutPoint)
+			if rErr != nil {
+				return nil, nil, rErr
+			}
+			depositHeader, err := s.client.GetHeader(ctx, *depositTx.TxStatus.BlockHash)
+			if err != nil {
+				return nil, nil, wrapErr(RpcError, err)
+			}
+			if depositHeader.Number != binary.LittleEndian.Uint64(data) {
+				return nil, nil, wrapErr(InvalidDAOCellError, fmt.Errorf("withdrawing cell %s does not match its deposit block", coin))
+			}
+			withdrawHeader, err := s.client.GetHeader(ctx, *tx.TxStatus.BlockHash)
+			if err != nil {
+				return nil, nil, wrapErr(RpcError, err)
+			}
+			maximumWithdraw, err := s.client.CalculateDaoMaximumWithdraw(ctx, depositOutPoint, withdrawHeader.Hash)
+			if err != nil {
+				return nil, nil, wrapErr(RpcError, err)
+			}
+			daoInput.DepositBlockNumber = depositHeader.Number
+			daoInput.DepositHeaderIndex = headerDepIndex(depositHeader.Hash)
+			headerDepIndex(withdrawHeader.Hash)
+			daoInput.Since = hexutil.Uint64(daoUnlockSince(depositHeader.Epoch, withdrawHeader.Epoch))
+			daoInput.MaximumWithdraw = strconv.FormatUint(maximumWithdraw, 10)
+		default:
+			return nil, nil, wrapErr(InvalidDAOCellError, fmt.Errorf("%s cannot spend %s %s", options.ConstructionType, cellType.String(), coin))
+		}
+		daoInputs = append(daoInputs, daoInput)
+	}
+
+	return headerDeps, daoInputs, nil
+}
+
+// committedOutput returns the committed transaction of the out point and the DAO cell it creates
+func (s *ConstructionAPIService) committedOutput(ctx context.Context, outPoint *ckbTypes.OutPoint) (*ckbTypes.TransactionWithStatus, *ckbTypes.CellOutput, []byte, *types.Error) {
+	tx, err := s.client.GetTransaction(ctx, outPoint.TxHash)
+	if err != nil {
+		return nil, nil, nil, wrapErr(RpcError, err)
+	}
+	if tx == nil || tx.Transaction == nil || tx.TxStatus.Status != ckbTypes.TransactionStatusCommitted || tx.TxStatus.BlockHash == nil {
+		return nil, nil, nil, wrapErr(InvalidDAOCellError, fmt.Errorf("transaction %s is not committed", outPoint.TxHash.String()))
+	}
+	if int(outPoint.Index) >= len(tx.Transaction.Outputs) || int(outPoint.Index) >= len(tx.Transaction.OutputsData) {
+		return nil, nil, nil, wrapErr(InvalidDAOCellError, fmt.Errorf("output index %d out of range of transaction %s", outPoint.Index, outPoint.TxHash.String()))
+	}
+	output := tx.Transaction.Outputs[outPoint.Index]
+	data := tx.Transaction.OutputsData[outPoint.Index]
+	if !isDAOCell(output, s.cfg) || len(data) != ckb.DAODataSize {
+		return nil, nil, nil, wrapErr(InvalidDAOCellError, fmt.Errorf("cell %s:%d is not a DAO cell", outPoint.TxHash.String(), outPoint.Index))
+	}
+	return tx, output, data, nil
+}
+
+// daoUnlockSince returns the absolute epoch since at which the deposit can be unlocked, it is the first epoch
+// after the withdraw block that completes a multiple of DAOLockEpochs since the deposit block.
+func daoUnlockSince(depositEpoch uint64, withdrawEpoch uint64) uint64 {
+	deposit := ckbTypes.ParseEpoch(depositEpoch)
+	withdraw := ckbTypes.ParseEpoch(withdrawEpoch)
+
+	depositedEpochs := withdraw.Number - deposit.Number
+	if withdraw.Index*deposit.Length > deposit.Index*withdraw.Length {
+		depositedEpochs++
+	}
+	lockEpochs := (depositedEpochs + ckb.DAOLockEpochs - 1) / ckb.DAOLockEpochs * ckb.DAOLockEpochs
+	since := &ckbTypes.EpochParams{
+		Length: deposit.Length,
+		Index:  deposit.Index,
+		Number: deposit.Number + lockEpochs,
+	}
+	return since.Uint64()
+}
+
+// getDAOInterest returns the interest of the withdrawing cells which the unlock outputs can spend on top of the
+// input amounts
+func getDAOInterest(operations []*types.Operation, metadata *ckb.ConstructionMetadata) (uint64, *types.Error) {
+	var interest uint64
+	for _, operation := range getInputOperations(operations) {
+		cellType, err := builder.GetOperationCellType(operation)
+		if err != nil {
+			return 0, wrapErr(DataParseError, err)
+		}
+		if cellType != ckb.DAOWithdrawingCell.String() {
+			continue
+		}
+		daoInput := metadata.DAOInput(operation.CoinChange.CoinIdentifier.Identifier)
+		if daoInput == nil {
+			return 0, wrapErr(InvalidConstructionMetadataError, fmt.Errorf("missing DAO input %s", operation.CoinChange.CoinIdentifier.Identifier))
+		}
+		capacity, err := strconv.ParseUint(daoInput.Capacity, 10, 64)
+		if err != nil {
+			return 0, wrapErr(InvalidConstructionMetadataError, err)
+		}
+		maximumWithdraw, err := strconv.ParseUint(daoInput.MaximumWithdraw, 10, 64)
+		if err != nil || maximumWithdraw < capacity {
+			return 0, wrapErr(InvalidConstructionMetadataError, fmt.Errorf("invalid maximum withdraw of DAO input %s", daoInput.CoinIdentifier))
+		}
+		interest += maximumWithdraw - capacity
+	}
+	return interest, nil
+}
+
+// placeholderDAOInputs stands in for the DAO inputs /construction/metadata resolves so the transaction can be built
+// before the deposit blocks are known. Every DAO input counts its own header deps, which may overestimate the size
+// when inputs share blocks.
+func placeholderDAOInputs(operations []*types.Operation) ([]string, []*ckb.DAOInput, *types.Error) {
+	var headerDeps []string
+	var daoInputs []*ckb.DAOInput
+	for _, operation := range getInputOperations(operations) {
+		cellType, err := builder.GetOperationCellType(operation)
+		if err != nil {
+			return nil, nil, wrapErr(DataParseError, err)
+		}
+		daoInput := &ckb.DAOInput{
+			CoinIdentifier:     operation.CoinChange.CoinIdentifier.Identifier,
+			Capacity:           strings.TrimPrefix(operation.Amount.Value, "-"),
+			DepositHeaderIndex: uint64(len(headerDeps)),
+		}
+		switch cellType {
+		case ckb.DAODepositCell.String():
+			headerDeps = append(headerDeps, ckbTypes.Hash{}.String())
+		case ckb.DAOWithdrawingCell.String():
+			headerDeps = append(headerDeps, ckbTypes.Hash{}.String(), ckbTypes.Hash{}.String())
+			// a since declared on the operation must match the unlock since, so it stands in for it
+			since, err := builder.GetOperationSince(operation)
+			if err != nil {
+				return nil, nil, wrapErr(InvalidSinceError, err)
+			}
+			if since == 0 {
+				since = math.MaxUint64
+			}
+			daoInput.Since = hexutil.Uint64(since)
+			daoInput.MaximumWithdraw = daoInput.Capacity
+		default:
+			continue
+		}
+		daoInputs = append(daoInputs, daoInput)
+	}
+	return headerDeps, daoInputs, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func TestDAOUnlockSince(t *testing.T) {
+	epoch := func(number, index, length uint64) uint64 {
+		return (&ckbTypes.EpochParams{Length: length, Index: index, Number: number}).Uint64()
+	}
+
+	tests := []struct {
+		name     string
+		deposit  uint64
+		withdraw uint64
+		want     uint64
+	}{
+		{name: "withdraw in the deposit epoch", deposit: epoch(5, 100, 1000), withdraw: epoch(5, 200, 1000), want: epoch(185, 100, 1000)},
+		{name: "withdraw at the end of the period", deposit: epoch(5, 100, 1000), withdraw: epoch(185, 100, 1000), want: epoch(185, 100, 1000)},
+		{name: "withdraw after the end of the period", deposit: epoch(5, 100, 1000), withdraw: epoch(185, 101, 1000), want: epoch(365, 100, 1000)},
+		{name: "withdraw before the end of the period", deposit: epoch(5, 100, 1000), withdraw: epoch(184, 999, 1000), want: epoch(185, 100, 1000)},
+		{name: "epochs of other lengths", deposit: epoch(5, 1, 2), withdraw: epoch(185, 1, 4), want: epoch(185, 1, 2)},
+		{name: "later fraction of another length", deposit: epoch(5, 1, 2), withdraw: epoch(185, 3, 4), want: epoch(365, 1, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := daoUnlockSince(tt.deposit, tt.withdraw); got != tt.want {
+				t.Errorf("daoUnlockSince() = %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceholderDAOInputs(t *testing.T) {
+	unlockSince := hexutil.Uint64((&ckbTypes.EpochParams{Length: 1000, Index: 100, Number: 185}).Uint64())
+	input := func(i int, cellType ckb.CellType, since *hexutil.Uint64) *types.Operation {
+		return &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+			Type:                ckb.InputOpType,
+			Amount:              &types.Amount{Value: "-10000000000"},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: testTxHash(i).String() + ":0"},
+				CoinAction:     types.CoinSpent,
+			},
+			Metadata: testOperationMetadata(t, &ckb.OperationMetadata{CellType: cellType.String(), Since: since}),
+		}
+	}
+
+	headerDeps, daoInputs, err := placeholderDAOInputs([]*types.Operation{
+		input(0, ckb.DAOWithdrawingCell, nil),
+		input(1, ckb.DAOWithdrawingCell, &unlockSince),
+		input(2, ckb.DAODepositCell, nil),
+	})
+	assertError(t, err, nil)
+	if len(headerDeps) != 5 {
+		t.Fatalf("header deps = %d, want 5", len(headerDeps))
+	}
+	wantSince := []hexutil.Uint64{math.MaxUint64, unlockSince, 0}
+	wantHeaderIndex := []uint64{0, 2, 4}
+	for i, daoInput := range daoInputs {
+		if daoInput.Since != wantSince[i] {
+			t.Errorf("input %d since = %s, want %s", i, daoInput.Since.String(), wantSince[i].String())
+		}
+		if daoInput.DepositHeaderIndex != wantHeaderIndex[i] {
+			t.Errorf("input %d deposit header index = %d, want %d", i, daoInput.DepositHeaderIndex, wantHeaderIndex[i])
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// an event record is the event type byte, the block number and the block hash, it is keyed by its sequence
+	blockEventRecordSize = 1 + 8 + 32
+
+	blockAddedRecord   byte = 0
+	blockRemovedRecord byte = 1
+)
+
+var (
+	eventsBucket = []byte("events")
+	// chainBucket maps the block numbers of the canonical chain to their hash
+	chainBucket = []byte("chain")
+)
+
+// blockEventStore is an append only log of block events and the canonical chain it describes, kept in a bbolt
+// database. The chain starts at the block of the first event, which is the start height of the sync.
+//
+// Events are buffered until flush, which writes them with the chain in one transaction, so the database always
+// holds the state of a flush.
+type blockEventStore struct {
+	mu sync.RWMutex
+	db *bolt.DB
+	// eventCount and chainLength are flushed, readers only see flushed events
+	eventCount  int64
+	chainLength int64
+	// base is the number of the first block of the chain, it is only set once there is an event
+	base uint64
+	// pendingEvents are the records of the events added since the last flush, the pending chain is truncated to
+	// chainTruncate blocks and then extended with pendingChain
+	pendingEvents [][]byte
+	chainTruncate int64
+	pendingChain  []ckbTypes.Hash
+}
+
+func openBlockEventStore(dir string) (*blockEventStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// a second server on the same directory fails instead of waiting for the file lock
+	db, err := bolt.Open(filepath.Join(dir, "events.db"), 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	st := &blockEventStore{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		events, err := tx.CreateBucketIfNotExists(eventsBucket)
+		if err != nil {
+			return err
+		}
+		chain, err := tx.CreateBucketIfNotExists(chainBucket)
+		if err != nil {
+			return err
+		}
+		if key, _ := events.Cursor().Last(); key != nil {
+			st.eventCount = int64(binary.BigEndian.Uint64(key)) + 1
+		}
+		cursor := chain.Cursor()
+		first, _ := cursor.First()
+		last, _ := cursor.Last()
+		if first != nil {
+			st.base = binary.BigEndian.Uint64(first)
+			st.chainLength = int64(binary.BigEndian.Uint64(last)-st.base) + 1
+		}
+		if (st.eventCount == 0) != (st.chainLength == 0) {
+			return errors.New("the event log and the chain do not match")
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	st.chainTruncate = st.chainLength
+	return st, nil
+}
+
+func (st *blockEventStore) Close() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.db.Close()
+}
+
+// head returns the number and hash of the last block of the pending chain, ok is false when the chain is empty
+func (st *blockEventStore) head() (number uint64, hash ckbTypes.Hash, ok bool, err error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	length := st.pendingLength()
+	if length == 0 {
+		return 0, ckbTypes.Hash{}, false, nil
+	}
+	hash, err = st.chainHash(length - 1)
+	if err != nil {
+		return 0, ckbTypes.Hash{}, false, err
+	}
+	return st.base + uint64(length) - 1, hash, true, nil
+}
+
+// addBlock appends the block to the pending chain, it must be the child of the head. The first block added to
+// an empty store is the start of the chain.
+func (st *blockEventStore) addBlock(number uint64, hash ckbTypes.Hash) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	length := st.pendingLength()
+	if length == 0 {
+		st.base = number
+	}
+	if number != st.base+uint64(length) {
+		return fmt.Errorf("block %d is not the next block of the chain ending at %d", number, st.base+uint64(length)-1)
+	}
+	st.appendEvent(blockAddedRecord, number, hash)
+	st.pendingChain = append(st.pendingChain, hash)
+	return nil
+}
+
+// removeBlock removes the head of the pending chain, the first block of the chain is never removed
+func (st *blockEventStore) removeBlock() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	length := st.pendingLength()
+	if length <= 1 {
+		return fmt.Errorf("remove the first block %d of the chain", st.base)
+	}
+	hash, err := st.chainHash(length - 1)
+	if err != nil {
+		return err
+	}
+	st.appendEvent(blockRemovedRecord, st.base+uint64(length)-1, hash)
+	if len(st.pendingChain) > 0 {
+		st.pendingChain = st.pendingChain[:len(st.pendingChain)-1]
+	} else {
+		st.chainTruncate--
+	}
+	return nil
+}
+
+// pendingEventCount returns the number of events added since the last flush
+func (st *blockEventStore) pendingEventCount() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return len(st.pendingEvents)
+}
+
+// flush writes the pending events and the chain they describe in one transaction. A failed flush keeps the
+// pending events so the next flush writes them again.
+func (st *blockEventStore) flush() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.pendingEvents) == 0 {
+		return nil
+	}
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		events := tx.Bucket(eventsBucket)
+		for i, record := range st.pendingEvents {
+			if err := events.Put(uint64Key(uint64(st.eventCount)+uint64(i)), record); err != nil {
+				return err
+			}
+		}
+		chain := tx.Bucket(chainBucket)
+		for position := st.chainTruncate; position < st.chainLength; position++ {
+			if err := chain.Delete(uint64Key(st.base + uint64(position))); err != nil {
+				return err
+			}
+		}
+		for i, hash := range st.pendingChain {
+			if err := chain.Put(uint64Key(st.base+uint64(st.chainTruncate)+uint64(i)), hash.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	st.eventCount += int64(len(st.pendingEvents))
+	st.chainLength = st.pendingLength()
+	st.pendingEvents = nil
+	st.chainTruncate = st.chainLength
+	st.pendingChain = nil
+	return nil
+}
+
+// maxSequence returns the sequence of the last flushed event, -1 when there is no event
+func (st *blockEventStore) maxSequence() int64 {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.eventCount - 1
+}
+
+// blockEvents returns at most limit flushed events from offset
+func (st *blockEventStore) blockEvents(offset int64, limit int64) ([]*api.BlockEvent, error) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	events := make([]*api.BlockEvent, 0)
+	if offset >= st.eventCount {
+		return events, nil
+	}
+	err := st.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(eventsBucket).Cursor()
+		for key, record := cursor.Seek(uint64Key(uint64(offset))); key != nil && int64(len(events)) < limit; key, record = cursor.Next() {
+			event, err := parseBlockEvent(int64(binary.BigEndian.Uint64(key)), record)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func parseBlockEvent(sequence int64, record []byte) (*api.BlockEvent, error) {
+	if len(record) != blockEventRecordSize {
+		return nil, fmt.Errorf("invalid event %d of %d bytes", sequence, len(record))
+	}
+	eventType := api.BlockAdded
+	if record[0] == blockRemovedRecord {
+		eventType = api.BlockRemoved
+	}
+	return &api.BlockEvent{
+		Sequence: sequence,
+		BlockIdentifier: &types.BlockIdentifier{
+			Index: int64(binary.LittleEndian.Uint64(record[1:9])),
+			Hash:  ckbTypes.BytesToHash(record[9:]).String(),
+		},
+		Type: eventType,
+	}, nil
+}
+
+func (st *blockEventStore) appendEvent(eventType byte, number uint64, hash ckbTypes.Hash) {
+	record := make([]byte, blockEventRecordSize)
+	record[0] = eventType
+	binary.LittleEndian.PutUint64(record[1:9], number)
+	copy(record[9:], hash.Bytes())
+	st.pendingEvents = append(st.pendingEvents, record)
+}
+
+func (st *blockEventStore) pendingLength() int64 {
+	return st.chainTruncate + int64(len(st.pendingChain))
+}
+
+// chainHash returns the hash at the position of the pending chain
+func (st *blockEventStore) chainHash(position int64) (ckbTypes.Hash, error) {
+	if position >= st.chainTruncate {
+		return st.pendingChain[position-st.chainTruncate], nil
+	}
+	var hash ckbTypes.Hash
+	err := st.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(chainBucket).Get(uint64Key(st.base + uint64(position)))
+		if value == nil {
+			return fmt.Errorf("block %d is missing from the chain", st.base+uint64(position))
+		}
+		hash = ckbTypes.BytesToHash(value)
+		return nil
+	})
+	return hash, err
+}
+
+// uint64Key encodes the number big endian so the keys are ordered by number
+func uint64Key(number uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, number)
+	return key
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func testBlockHash(number uint64, fork byte) ckbTypes.Hash {
+	return ckbTypes.BytesToHash([]byte{fork, byte(number >> 8), byte(number)})
+}
+
+func TestBlockEventStoreReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := openBlockEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBlockEventStore(t, st, 0, nil)
+	// blocks 10 to 14 in the first flush, then 13 and 14 are replaced and 15 added on fork 1
+	for number := uint64(10); number < 15; number++ {
+		if err := st.addBlock(number, testBlockHash(number, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := st.removeBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for number := uint64(13); number < 16; number++ {
+		if err := st.addBlock(number, testBlockHash(number, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.flush(); err != nil {
+		t.Fatal(err)
+	}
+	// the events added after the last flush are lost on close
+	if err := st.removeBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.addBlock(15, testBlockHash(15, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = openBlockEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	finalChain := []ckbTypes.Hash{
+		testBlockHash(10, 0), testBlockHash(11, 0), testBlockHash(12, 0),
+		testBlockHash(13, 1), testBlockHash(14, 1), testBlockHash(15, 1),
+	}
+	assertBlockEventStore(t, st, 10, finalChain)
+	if got := st.pendingEventCount(); got != 0 {
+		t.Errorf("pending events = %d, want 0", got)
+	}
+
+	events, err := st.blockEvents(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		number    int64
+		eventType api.BlockEventType
+	}{{14, api.BlockAdded}, {14, api.BlockRemoved}, {13, api.BlockRemoved}}
+	if len(events) != len(want) {
+		t.Fatalf("events = %d, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.Sequence != int64(4+i) || event.BlockIdentifier.Index != want[i].number || event.Type != want[i].eventType {
+			t.Errorf("event %d = %d %d %s, want %d %s", i, event.Sequence, event.BlockIdentifier.Index, event.Type, want[i].number, want[i].eventType)
+		}
+	}
+
+	// the reopened store continues the chain and the sequence
+	if err := st.addBlock(16, testBlockHash(16, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.flush(); err != nil {
+		t.Fatal(err)
+	}
+	assertBlockEventStore(t, st, 11, append(finalChain, testBlockHash(16, 1)))
+}
+
+func assertBlockEventStore(t *testing.T, st *blockEventStore, wantEvents int64, wantChain []ckbTypes.Hash) {
+	t.Helper()
+	if got := st.maxSequence() + 1; got != wantEvents {
+		t.Fatalf("events = %d, want %d", got, wantEvents)
+	}
+	number, hash, ok, err := st.head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok != (len(wantChain) > 0) {
+		t.Fatalf("head ok = %v, want chain of %d blocks", ok, len(wantChain))
+	}
+	if !ok {
+		return
+	}
+	if number != 10+uint64(len(wantChain))-1 || hash != wantChain[len(wantChain)-1] {
+		t.Fatalf("head = %d %s, want %d %s", number, hash.String(), 10+len(wantChain)-1, wantChain[len(wantChain)-1].String())
+	}
+	for i, want := range wantChain {
+		got, err := st.chainHash(int64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("block %d = %s, want %s", 10+i, got.String(), want.String())
+		}
+	}
+}
+
+func TestBlockEventStoreFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := openBlockEventStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	steps := []struct {
+		name        string
+		apply       func() error
+		wantPending int
+		wantEvents  int64
+		wantHead    uint64
+		wantErr     bool
+	}{
+		{name: "start block", apply: func() error { return st.addBlock(5, testBlockHash(5, 0)) }, wantPending: 1, wantEvents: 0, wantHead: 5},
+		{name: "next block", apply: func() error { return st.addBlock(6, testBlockHash(6, 0)) }, wantPending: 2, wantEvents: 0, wantHead: 6},
+		{name: "flush", apply: st.flush, wantEvents: 2, wantHead: 6},
+		{name: "skipped block", apply: func() error { return st.addBlock(8, testBlockHash(8, 0)) }, wantEvents: 2, wantHead: 6, wantErr: true},
+		{name: "remove flushed block", apply: st.removeBlock, wantPending: 1, wantEvents: 2, wantHead: 5},
+		{name: "remove start block", apply: st.removeBlock, wantPending: 1, wantEvents: 2, wantHead: 5, wantErr: true},
+		{name: "fork block", apply: func() error { return st.addBlock(6, testBlockHash(6, 1)) }, wantPending: 2, wantEvents: 2, wantHead: 6},
+		{name: "flush fork", apply: st.flush, wantEvents: 4, wantHead: 6},
+		{name: "empty flush", apply: st.flush, wantEvents: 4, wantHead: 6},
+	}
+	for _, step := range steps {
+		if err := step.apply(); (err != nil) != step.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if got := st.pendingEventCount(); got != step.wantPending {
+			t.Errorf("%s: pending events = %d, want %d", step.name, got, step.wantPending)
+		}
+		if got := st.maxSequence() + 1; got != step.wantEvents {
+			t.Errorf("%s: events = %d, want %d", step.name, got, step.wantEvents)
+		}
+		if number, _, _, _ := st.head(); number != step.wantHead {
+			t.Errorf("%s: head = %d, want %d", step.name, number, step.wantHead)
+		}
+	}
+
+	events, err := st.blockEvents(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := []api.BlockEventType{api.BlockAdded, api.BlockAdded, api.BlockRemoved, api.BlockAdded}
+	for i, event := range events {
+		if event.Sequence != int64(i) || event.Type != wantTypes[i] {
+			t.Errorf("event %d = %d %s, want %s", i, event.Sequence, event.Type, wantTypes[i])
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+const (
+	defaultEventsPollInterval = 3 * time.Second
+	// eventsBatchSize is the number of events written in one transaction while catching up with the tip
+	eventsBatchSize = 1000
+)
+
+// EventsAPIService implements the api.EventsAPIServicer interface.
+type EventsAPIService struct {
+	network *types.NetworkIdentifier
+	client  rpc.Client
+	cfg     *config.Config
+	store   *blockEventStore
+}
+
+// NewEventsAPIService creates a new instance of an EventsAPIService, the event log is opened in Events.DataDir.
+func NewEventsAPIService(network *types.NetworkIdentifier, client rpc.Client, cfg *config.Config) (*EventsAPIService, error) {
+	store, err := openBlockEventStore(cfg.EventsDataDir())
+	if err != nil {
+		return nil, err
+	}
+	return &EventsAPIService{
+		network: network,
+		client:  client,
+		cfg:     cfg,
+		store:   store,
+	}, nil
+}
+
+// EventsBlocks implements the /events/blocks endpoint.
+func (s *EventsAPIService) EventsBlocks(
+	ctx context.Context,
+	request *api.EventsBlocksRequest,
+) (*api.EventsBlocksResponse, *types.Error) {
+	limit := int64(ckb.EventsBlocksLimit)
+	if request.Limit != nil && *request.Limit < limit {
+		limit = *request.Limit
+	}
+	maxSequence := s.store.maxSequence()
+	// without an offset the last limit events are returned
+	offset := maxSequence + 1 - limit
+	if request.Offset != nil {
+		offset = *request.Offset
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	events, err := s.store.blockEvents(offset, limit)
+	if err != nil {
+		return nil, wrapErr(ServerError, err)
+	}
+
+	return &api.EventsBlocksResponse{
+		MaxSequence: maxSequence,
+		Events:      events,
+	}, nil
+}
+
+// Sync follows the tip every Events.PollInterval until ctx is done, then the event log is closed.
+func (s *EventsAPIService) Sync(ctx context.Context) {
+	interval := s.cfg.Events.PollInterval
+	if interval <= 0 {
+		interval = defaultEventsPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer func() {
+		if err := s.store.Close(); err != nil {
+			log.Printf("close event log of %s error: %v", s.network.Network, err)
+		}
+	}()
+
+	for {
+		if err := s.syncOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("sync events of %s error: %v", s.network.Network, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncOnce records blocks until the chain reaches the tip, a block whose parent is not the head means
+// the head was reorganized out, so the head is removed and its parent is compared again. An empty event log
+// starts at Events.StartHeight and a reorg of its first block fails the sync. Events are flushed every eventsBatchSize events and when syncOnce returns.
+func (s *EventsAPIService) syncOnce(ctx context.Context) (err error) {
+	defer func() {
+		if flushErr := s.store.flush(); err == nil {
+			err = flushErr
+		}
+	}()
+	for ctx.Err() == nil {
+		if s.store.pendingEventCount() >= eventsBatchSize {
+			if err := s.store.flush(); err != nil {
+				return err
+			}
+		}
+		tip, err := s.client.GetTipBlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		number, hash, ok, err := s.store.head()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			start := s.cfg.Events.StartHeight
+			if start > tip {
+				return nil
+			}
+			header, err := s.client.GetHeaderByNumber(ctx, start)
+			if err != nil {
+				return err
+			}
+			if err := s.store.addBlock(start, header.Hash); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if number < tip {
+			next, err := s.client.GetHeaderByNumber(ctx, number+1)
+			if err != nil {
+				return err
+			}
+			// the tip moved while fetching, try again on the next poll
+			if next.Hash == (ckbTypes.Hash{}) {
+				return nil
+			}
+			if next.ParentHash == hash {
+				err = s.store.addBlock(number+1, next.Hash)
+			} else {
+				err = s.store.removeBlock()
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		header, err := s.client.GetHeaderByNumber(ctx, number)
+		if err != nil {
+			return err
+		}
+		if header.Hash == hash {
+			return nil
+		}
+		if err := s.store.removeBlock(); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// chainClient serves a chain whose blocks after forkAt belong to fork
+type chainClient struct {
+	rpc.Client
+	tip    uint64
+	forkAt uint64
+	fork   byte
+}
+
+func (c *chainClient) header(number uint64) *ckbTypes.Header {
+	fork := func(number uint64) byte {
+		if number > c.forkAt {
+			return c.fork
+		}
+		return 0
+	}
+	header := &ckbTypes.Header{Number: number, Hash: testBlockHash(number, fork(number))}
+	if number > 0 {
+		header.ParentHash = testBlockHash(number-1, fork(number-1))
+	}
+	return header
+}
+
+func (c *chainClient) GetTipBlockNumber(ctx context.Context) (uint64, error) {
+	return c.tip, nil
+}
+
+func (c *chainClient) GetHeaderByNumber(ctx context.Context, number uint64) (*ckbTypes.Header, error) {
+	if number > c.tip {
+		return &ckbTypes.Header{}, nil
+	}
+	return c.header(number), nil
+}
+
+func TestEventsSyncOnce(t *testing.T) {
+	tests := []struct {
+		name        string
+		startHeight uint64
+		tip         uint64
+		reorg       *chainClient
+		wantEvents  []string
+		// wantMaxSequence is checked instead of the events when there are too many to list
+		wantMaxSequence int64
+		wantErr         bool
+	}{
+		{
+			name:       "from genesis",
+			tip:        2,
+			wantEvents: []string{"+0", "+1", "+2"},
+		},
+		{
+			name:        "from start height",
+			startHeight: 1500,
+			tip:         1502,
+			wantEvents:  []string{"+1500", "+1501", "+1502"},
+		},
+		{
+			name:        "start height above tip",
+			startHeight: 10,
+			tip:         5,
+			wantEvents:  []string{},
+		},
+		{
+			name:       "reorg to a longer fork",
+			tip:        3,
+			reorg:      &chainClient{tip: 4, forkAt: 1, fork: 1},
+			wantEvents: []string{"+0", "+1", "+2", "+3", "-3", "-2", "+2", "+3", "+4"},
+		},
+		{
+			name:       "reorg to a fork of the same length",
+			tip:        3,
+			reorg:      &chainClient{tip: 3, forkAt: 2, fork: 1},
+			wantEvents: []string{"+0", "+1", "+2", "+3", "-3", "+3"},
+		},
+		{
+			name:        "reorg of the start block",
+			startHeight: 2,
+			tip:         3,
+			reorg:       &chainClient{tip: 3, forkAt: 1, fork: 1},
+			wantEvents:  []string{"+2", "+3", "-3"},
+			wantErr:     true,
+		},
+		{
+			name:            "batches while catching up",
+			tip:             eventsBatchSize + 10,
+			wantMaxSequence: eventsBatchSize + 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "events")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			cfg := testConfig(t)
+			cfg.Events.DataDir = dir
+			cfg.Events.StartHeight = tt.startHeight
+			client := &chainClient{tip: tt.tip}
+			s, err := NewEventsAPIService(&types.NetworkIdentifier{Blockchain: "CKB", Network: "Mainnet"}, client, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.store.Close()
+
+			if err := s.syncOnce(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if tt.reorg != nil {
+				*client = *tt.reorg
+				if err := s.syncOnce(context.Background()); (err != nil) != tt.wantErr {
+					t.Fatalf("syncOnce() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+			if s.store.pendingEventCount() != 0 {
+				t.Fatalf("%d events are not flushed", s.store.pendingEventCount())
+			}
+
+			response, rErr := s.EventsBlocks(context.Background(), &api.EventsBlocksRequest{Offset: new(int64)})
+			if rErr != nil {
+				t.Fatal(rErr)
+			}
+			if tt.wantEvents == nil {
+				if response.MaxSequence != tt.wantMaxSequence {
+					t.Fatalf("max sequence = %d, want %d", response.MaxSequence, tt.wantMaxSequence)
+				}
+				return
+			}
+			var got []string
+			for _, event := range response.Events {
+				sign := "+"
+				if event.Type == api.BlockRemoved {
+					sign = "-"
+				}
+				got = append(got, sign+strconv.FormatInt(event.BlockIdentifier.Index, 10))
+				wantHash := client.header(uint64(event.BlockIdentifier.Index)).Hash.String()
+				if event.Type == api.BlockAdded && event.Sequence == response.MaxSequence && event.BlockIdentifier.Hash != wantHash {
+					t.Errorf("last block = %s, want %s", event.BlockIdentifier.Hash, wantHash)
+				}
+			}
+			if len(got) != len(tt.wantEvents) {
+				t.Fatalf("events = %v, want %v", got, tt.wantEvents)
+			}
+			for i := range got {
+				if got[i] != tt.wantEvents[i] {
+					t.Fatalf("events = %v, want %v", got, tt.wantEvents)
+				}
+			}
+		})
+	}
+}
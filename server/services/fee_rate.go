@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// txPoolCacheInterval is how long a fetched tx pool is reused, the verbose tx pool is large on a busy node
+const txPoolCacheInterval = 5 * time.Second
+
+// suggestedFeeRate returns the configured percentile of the fee rates in the tx pool in shannons/KB,
+// bounded by the configured floor and cap. MinFeeRate is used when the tx pool is not available or empty.
+func (s *ConstructionAPIService) suggestedFeeRate(ctx context.Context) uint64 {
+	feeRate := uint64(ckb.MinFeeRate)
+	txPool, err := s.txPool.Get(ctx)
+	if err == nil {
+		var feeRates []uint64
+		for _, entries := range []map[ckbTypes.Hash]*TxPoolEntry{txPool.Pending, txPool.Proposed} {
+			for _, entry := range entries {
+				if entry.Size == 0 {
+					continue
+				}
+				feeRates = append(feeRates, uint64(entry.Fee)*ckb.BytesInKb/uint64(entry.Size))
+			}
+		}
+		if len(feeRates) > 0 {
+			sort.Slice(feeRates, func(i, j int) bool { return feeRates[i] < feeRates[j] })
+			feeRate = feeRates[percentileIndex(len(feeRates), s.cfg.FeeRate.Percentile)]
+		}
+	}
+
+	if feeRate < s.cfg.FeeRate.Floor {
+		feeRate = s.cfg.FeeRate.Floor
+	}
+	if s.cfg.FeeRate.Cap > 0 && feeRate > s.cfg.FeeRate.Cap {
+		feeRate = s.cfg.FeeRate.Cap
+	}
+	if feeRate < ckb.MinFeeRate {
+		feeRate = ckb.MinFeeRate
+	}
+	return feeRate
+}
+
+// percentileIndex returns the nearest rank index of the percentile in (0, 100], the config loader rejects other
+// percentiles except 0, which is treated as the median
+func percentileIndex(size int, percentile float64) int {
+	if percentile == 0 {
+		percentile = 50
+	}
+	index := int(math.Ceil(percentile/100*float64(size))) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= size {
+		index = size - 1
+	}
+	return index
+}
+
+// txPoolCache reuses the verbose tx pool for txPoolCacheInterval, failed fetches are not cached.
+type txPoolCache struct {
+	mu        sync.Mutex
+	client    Client
+	interval  time.Duration
+	now       func() time.Time
+	txPool    *RawTxPoolVerbose
+	fetchedAt time.Time
+}
+
+func newTxPoolCache(client Client, interval time.Duration) *txPoolCache {
+	return &txPoolCache{
+		client:   client,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+func (c *txPoolCache) Get(ctx context.Context) (*RawTxPoolVerbose, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.txPool != nil && c.now().Sub(c.fetchedAt) < c.interval {
+		return c.txPool, nil
+	}
+	txPool, err := c.client.GetRawTxPoolVerbose(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.txPool = txPool
+	c.fetchedAt = c.now()
+	return txPool, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPercentileIndex(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		percentile float64
+		want       int
+	}{
+		{name: "single entry", size: 1, percentile: 50, want: 0},
+		{name: "median of odd size", size: 5, percentile: 50, want: 2},
+		{name: "median of even size", size: 4, percentile: 50, want: 1},
+		{name: "unset percentile is the median", size: 5, percentile: 0, want: 2},
+		{name: "smallest percentile", size: 10, percentile: 0.1, want: 0},
+		{name: "nearest rank rounds up", size: 10, percentile: 75, want: 7},
+		{name: "maximum", size: 10, percentile: 100, want: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentileIndex(tt.size, tt.percentile); got != tt.want {
+				t.Errorf("percentileIndex(%d, %v) = %d, want %d", tt.size, tt.percentile, got, tt.want)
+			}
+		})
+	}
+}
+
+// txPoolClient counts the verbose tx pool fetches, it fails while err is set
+type txPoolClient struct {
+	Client
+	calls int
+	err   error
+}
+
+func (c *txPoolClient) GetRawTxPoolVerbose(ctx context.Context) (*RawTxPoolVerbose, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &RawTxPoolVerbose{}, nil
+}
+
+func TestTxPoolCache(t *testing.T) {
+	client := &txPoolClient{}
+	cache := newTxPoolCache(client, 5*time.Second)
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		err       error
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "first fetch", wantCalls: 1},
+		{name: "within interval", advance: 4 * time.Second, wantCalls: 1},
+		{name: "interval elapsed", advance: time.Second, wantCalls: 2},
+		{name: "failed fetch", advance: 5 * time.Second, err: errors.New("rpc error"), wantErr: true, wantCalls: 3},
+		{name: "failure not cached", wantCalls: 4},
+		{name: "refetched pool cached", advance: time.Second, wantCalls: 4},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		client.err = step.err
+		_, err := cache.Get(context.Background())
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: Get() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if client.calls != step.wantCalls {
+			t.Fatalf("%s: fetches = %d, want %d", step.name, client.calls, step.wantCalls)
+		}
+	}
+}
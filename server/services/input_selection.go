@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/factory"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// preprocessWithInputSelection estimates the size of a transfer without INPUT operations, the inputs are
+// collected from the live cells of the sender by /construction/metadata.
+func (s *ConstructionAPIService) preprocessWithInputSelection(
+	request *types.ConstructionPreprocessRequest,
+	sender string,
+) (*types.ConstructionPreprocessResponse, *types.Error) {
+	senderAddress, err := address.Parse(sender)
+	if err != nil {
+		return nil, AddressParseError
+	}
+	if !isBlake160SighashAllLock(senderAddress.Script, s.cfg) {
+		return nil, wrapErr(InvalidSenderError, errors.New("sender must be a secp256k1_blake160_sighash_all address"))
+	}
+
+	outputTotalAmount, validateErr := validateOutputOperations(request.Operations, s.cfg)
+	if validateErr != nil {
+		return nil, validateErr
+	}
+	outputOperations := getOutputOperations(request.Operations)
+	if ok, validateErr := isTransferCKB(nil, outputOperations, nil, s.cfg); !ok {
+		if validateErr != nil {
+			return nil, validateErr
+		}
+		return nil, wrapErr(UnsupportedConstructionTypeError, errors.New("input selection only supports CKB transfers"))
+	}
+
+	// the first selected input and the witness of the sender group are counted with a placeholder input, the
+	// change output is always counted, it is dropped when the change is zero
+	placeholderInput := &types.Operation{
+		Type:    ckb.InputOpType,
+		Account: &types.AccountIdentifier{Address: sender},
+		Amount: &types.Amount{
+			Value:    "-0",
+			Currency: CkbCurrency,
+		},
+		CoinChange: &types.CoinChange{
+			CoinIdentifier: &types.CoinIdentifier{Identifier: fmt.Sprintf("%s:0", ckbTypes.Hash{}.String())},
+			CoinAction:     types.CoinSpent,
+		},
+	}
+	changeOperation := &types.Operation{
+		Type:    ckb.OutputOpType,
+		Account: &types.AccountIdentifier{Address: sender},
+		Amount: &types.Amount{
+			Value:    "0",
+			Currency: CkbCurrency,
+		},
+	}
+	operations := append([]*types.Operation{placeholderInput}, outputOperations...)
+	txSizeEstimatorFactory := new(factory.TxSizeEstimatorFactory)
+	txSizeEstimator := txSizeEstimatorFactory.CreateTxSizeEstimator(ckb.TransferCKB, s.cfg)
+	estimatedTxSize, err := txSizeEstimator.EstimatedTxSize(append(operations, changeOperation), &ckb.ConstructionMetadata{
+		ConstructionType: ckb.TransferCKB,
+	})
+	if err != nil {
+		return nil, wrapErr(DataParseError, err)
+	}
+
+	options, err := types.MarshalMap(&ckb.PreprocessOptions{
+		ConstructionType:       ckb.TransferCKB,
+		EstimatedTxSize:        estimatedTxSize,
+		SuggestedFeeMultiplier: request.SuggestedFeeMultiplier,
+		Sender:                 sender,
+		OutputsCapacity:        outputTotalAmount,
+	})
+	if err != nil {
+		return nil, InvalidPreprocessOptionsError
+	}
+
+	return &types.ConstructionPreprocessResponse{
+		Options: options,
+	}, nil
+}
+
+// selectInputs collects plain live cells of the sender until they cover the outputs and the fee, the change
+// must be zero or at least the occupied capacity of a plain sender cell.
+func (s *ConstructionAPIService) selectInputs(
+	ctx context.Context,
+	options *ckb.PreprocessOptions,
+	shannonsPerB float64,
+) ([]*ckb.SelectedInput, uint64, uint64, *types.Error) {
+	senderAddress, err := address.Parse(options.Sender)
+	if err != nil {
+		return nil, 0, 0, AddressParseError
+	}
+	minChange := builder.OccupiedCapacity(&ckbTypes.CellOutput{Lock: senderAddress.Script}, 0)
+
+	var inputs []*ckb.SelectedInput
+	var inputTotalAmount uint64
+	txSize := options.EstimatedTxSize
+	var cursor string
+	for {
+		liveCells, err := s.client.GetCells(ctx, &indexer.SearchKey{
+			Script:     senderAddress.Script,
+			ScriptType: indexer.ScriptTypeLock,
+		}, indexer.SearchOrderAsc, ckb.SearchLimit, cursor)
+		if err != nil {
+			return nil, 0, 0, wrapErr(RpcError, err)
+		}
+		for _, cell := range liveCells.Objects {
+			if cell.Output.Type != nil || len(cell.OutputData) > 0 {
+				continue
+			}
+			// the estimated size already counts the first input, the others share its lock group
+			if len(inputs) > 0 {
+				txSize += ckb.InputSize + ckb.GroupWitnessSize
+			}
+			inputTotalAmount += cell.Output.Capacity
+			inputs = append(inputs, &ckb.SelectedInput{
+				CoinIdentifier: fmt.Sprintf("%s:%d", cell.OutPoint.TxHash, cell.OutPoint.Index),
+				Capacity:       strconv.FormatUint(cell.Output.Capacity, 10),
+			})
+
+			fee := uint64(shannonsPerB * float64(txSize))
+			if inputTotalAmount < options.OutputsCapacity+fee {
+				continue
+			}
+			change := inputTotalAmount - options.OutputsCapacity - fee
+			if change == 0 || change >= minChange {
+				return inputs, change, fee, nil
+			}
+		}
+		if len(liveCells.Objects) < ckb.SearchLimit || liveCells.LastCursor == "" {
+			break
+		}
+		cursor = liveCells.LastCursor
+	}
+
+	return nil, 0, 0, wrapErr(CapacityNotEnoughError, fmt.Errorf("live cells of %s can not cover %d shannons and the fee", options.Sender, options.OutputsCapacity))
+}
+
+// withSelectedInputs prepends the selected inputs to the operations and appends the change output
+func withSelectedInputs(operations []*types.Operation, metadata *ckb.ConstructionMetadata) ([]*types.Operation, *types.Error) {
+	if len(getInputOperations(operations)) > 0 {
+		return nil, wrapErr(InvalidConstructionMetadataError, errors.New("selected inputs can not be mixed with INPUT operations"))
+	}
+
+	var result []*types.Operation
+	for _, input := range metadata.Inputs {
+		result = append(result, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(len(result))},
+			Type:                ckb.InputOpType,
+			Account:             &types.AccountIdentifier{Address: metadata.Sender},
+			Amount: &types.Amount{
+				Value:    "-" + input.Capacity,
+				Currency: CkbCurrency,
+			},
+			CoinChange: &types.CoinChange{
+				CoinIdentifier: &types.CoinIdentifier{Identifier: input.CoinIdentifier},
+				CoinAction:     types.CoinSpent,
+			},
+		})
+	}
+	for _, operation := range getOutputOperations(operations) {
+		outputOperation := *operation
+		outputOperation.OperationIdentifier = &types.OperationIdentifier{Index: int64(len(result))}
+		result = append(result, &outputOperation)
+	}
+	if metadata.Change != "" && metadata.Change != "0" {
+		result = append(result, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(len(result))},
+			Type:                ckb.OutputOpType,
+			Account:             &types.AccountIdentifier{Address: metadata.Sender},
+			Amount: &types.Amount{
+				Value:    metadata.Change,
+				Currency: CkbCurrency,
+			},
+		})
+	}
+
+	return result, nil
+}
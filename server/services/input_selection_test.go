@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	ckbTransaction "github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// cellsClient pages the live cells of the sender, the cursor is the position of the last cell
+type cellsClient struct {
+	Client
+	cells   []*indexer.LiveCell
+	cursors []string
+}
+
+func (c *cellsClient) GetCells(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.LiveCells, error) {
+	c.cursors = append(c.cursors, afterCursor)
+	start := 0
+	if afterCursor != "" {
+		last, err := strconv.Atoi(afterCursor)
+		if err != nil {
+			return nil, err
+		}
+		start = last + 1
+	}
+	end := start + int(limit)
+	if end > len(c.cells) {
+		end = len(c.cells)
+	}
+	liveCells := &indexer.LiveCells{Objects: c.cells[start:end]}
+	if end > start {
+		liveCells.LastCursor = strconv.Itoa(end - 1)
+	}
+	return liveCells, nil
+}
+
+func TestSelectInputs(t *testing.T) {
+	cfg := testConfig(t)
+	sender := testAddress(t, &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(ckbTransaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{1}, 20),
+	})
+	const txSize = 464
+	const outputsCapacity = 10000000000
+	// one more input of the sender group grows the transaction by its input and empty witness
+	const inputGrowth = ckb.InputSize + ckb.GroupWitnessSize
+	plain := func(capacity uint64) *indexer.LiveCell {
+		return &indexer.LiveCell{Output: &ckbTypes.CellOutput{Capacity: capacity}}
+	}
+	typed := func(capacity uint64) *indexer.LiveCell {
+		return &indexer.LiveCell{Output: &ckbTypes.CellOutput{Capacity: capacity, Type: &ckbTypes.Script{}}}
+	}
+	withData := func(capacity uint64) *indexer.LiveCell {
+		return &indexer.LiveCell{Output: &ckbTypes.CellOutput{Capacity: capacity}, OutputData: []byte{1}}
+	}
+	// the first page is filled with cells input selection skips
+	var fullPage []*indexer.LiveCell
+	for i := 0; i < ckb.SearchLimit; i++ {
+		fullPage = append(fullPage, typed(100000000000))
+	}
+
+	tests := []struct {
+		name        string
+		cells       []*indexer.LiveCell
+		wantInputs  []int
+		wantChange  uint64
+		wantFee     uint64
+		wantCursors int
+		wantErr     *types.Error
+	}{
+		{
+			name:        "exact change",
+			cells:       []*indexer.LiveCell{plain(outputsCapacity + txSize)},
+			wantInputs:  []int{0},
+			wantChange:  0,
+			wantFee:     txSize,
+			wantCursors: 1,
+		},
+		{
+			name:        "change of a new cell",
+			cells:       []*indexer.LiveCell{plain(outputsCapacity + txSize + 6100000000), plain(outputsCapacity)},
+			wantInputs:  []int{0},
+			wantChange:  6100000000,
+			wantFee:     txSize,
+			wantCursors: 1,
+		},
+		{
+			name:        "change below the occupied capacity takes another input",
+			cells:       []*indexer.LiveCell{plain(outputsCapacity + txSize + 6099999999), plain(outputsCapacity)},
+			wantInputs:  []int{0, 1},
+			wantChange:  6099999999 + outputsCapacity - inputGrowth,
+			wantFee:     txSize + inputGrowth,
+			wantCursors: 1,
+		},
+		{
+			name:        "inputs add up",
+			cells:       []*indexer.LiveCell{plain(7000000000), plain(outputsCapacity)},
+			wantInputs:  []int{0, 1},
+			wantChange:  7000000000 - txSize - inputGrowth,
+			wantFee:     txSize + inputGrowth,
+			wantCursors: 1,
+		},
+		{
+			name:        "typed cells and cells with data are skipped",
+			cells:       []*indexer.LiveCell{typed(100000000000), withData(100000000000), plain(2 * outputsCapacity)},
+			wantInputs:  []int{2},
+			wantChange:  outputsCapacity - txSize,
+			wantFee:     txSize,
+			wantCursors: 1,
+		},
+		{
+			name:        "insufficient funds",
+			cells:       []*indexer.LiveCell{plain(outputsCapacity), typed(100000000000)},
+			wantErr:     CapacityNotEnoughError,
+			wantCursors: 1,
+		},
+		{
+			name:        "change below the occupied capacity without another input",
+			cells:       []*indexer.LiveCell{plain(outputsCapacity + txSize + 100)},
+			wantErr:     CapacityNotEnoughError,
+			wantCursors: 1,
+		},
+		{
+			name:        "selection across pages",
+			cells:       append(append([]*indexer.LiveCell{plain(outputsCapacity)}, fullPage...), plain(outputsCapacity)),
+			wantInputs:  []int{0, ckb.SearchLimit + 1},
+			wantChange:  outputsCapacity - txSize - inputGrowth,
+			wantFee:     txSize + inputGrowth,
+			wantCursors: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, cell := range tt.cells {
+				cell.OutPoint = &ckbTypes.OutPoint{TxHash: testTxHash(i), Index: uint(i)}
+			}
+			client := &cellsClient{cells: tt.cells}
+			s := &ConstructionAPIService{client: client, cfg: cfg}
+			inputs, change, fee, rErr := s.selectInputs(context.Background(), &ckb.PreprocessOptions{
+				EstimatedTxSize: txSize,
+				Sender:          sender,
+				OutputsCapacity: outputsCapacity,
+			}, 1)
+			assertError(t, rErr, tt.wantErr)
+			if len(client.cursors) != tt.wantCursors {
+				t.Errorf("pages = %d, want %d", len(client.cursors), tt.wantCursors)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(inputs) != len(tt.wantInputs) {
+				t.Fatalf("inputs = %d, want %d", len(inputs), len(tt.wantInputs))
+			}
+			for i, index := range tt.wantInputs {
+				cell := tt.cells[index]
+				if want := cell.OutPoint.TxHash.String() + ":" + strconv.Itoa(index); inputs[i].CoinIdentifier != want {
+					t.Errorf("input %d = %s, want %s", i, inputs[i].CoinIdentifier, want)
+				}
+				if want := strconv.FormatUint(cell.Output.Capacity, 10); inputs[i].Capacity != want {
+					t.Errorf("input %d capacity = %s, want %s", i, inputs[i].Capacity, want)
+				}
+			}
+			if change != tt.wantChange || fee != tt.wantFee {
+				t.Errorf("change, fee = %d, %d, want %d, %d", change, fee, tt.wantChange, tt.wantFee)
+			}
+		})
+	}
+}
+
+func TestWithSelectedInputs(t *testing.T) {
+	sender := "ckb1sender"
+	output := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: 0},
+		Type:                ckb.OutputOpType,
+		Account:             &types.AccountIdentifier{Address: "ckb1receiver"},
+		Amount:              &types.Amount{Value: "10000000000", Currency: CkbCurrency},
+	}
+	input := &types.Operation{
+		OperationIdentifier: &types.OperationIdentifier{Index: 1},
+		Type:                ckb.InputOpType,
+		Account:             &types.AccountIdentifier{Address: sender},
+		Amount:              &types.Amount{Value: "-10000000000", Currency: CkbCurrency},
+	}
+	selected := []*ckb.SelectedInput{
+		{CoinIdentifier: testTxHash(1).String() + ":0", Capacity: "6100000000"},
+		{CoinIdentifier: testTxHash(2).String() + ":1", Capacity: "20000000000"},
+	}
+
+	tests := []struct {
+		name       string
+		operations []*types.Operation
+		change     string
+		wantTypes  []string
+		wantValues []string
+		wantErr    *types.Error
+	}{
+		{
+			name:       "change",
+			operations: []*types.Operation{output},
+			change:     "16099999000",
+			wantTypes:  []string{ckb.InputOpType, ckb.InputOpType, ckb.OutputOpType, ckb.OutputOpType},
+			wantValues: []string{"-6100000000", "-20000000000", "10000000000", "16099999000"},
+		},
+		{
+			name:       "zero change is dropped",
+			operations: []*types.Operation{output},
+			change:     "0",
+			wantTypes:  []string{ckb.InputOpType, ckb.InputOpType, ckb.OutputOpType},
+			wantValues: []string{"-6100000000", "-20000000000", "10000000000"},
+		},
+		{
+			name:       "INPUT operations",
+			operations: []*types.Operation{output, input},
+			change:     "0",
+			wantErr:    InvalidConstructionMetadataError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operations, rErr := withSelectedInputs(tt.operations, &ckb.ConstructionMetadata{
+				Sender: sender,
+				Inputs: selected,
+				Change: tt.change,
+			})
+			assertError(t, rErr, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			if len(operations) != len(tt.wantTypes) {
+				t.Fatalf("operations = %d, want %d", len(operations), len(tt.wantTypes))
+			}
+			for i, operation := range operations {
+				if operation.OperationIdentifier.Index != int64(i) || operation.Type != tt.wantTypes[i] || operation.Amount.Value != tt.wantValues[i] {
+					t.Errorf("operation %d = %d %s %s, want %d %s %s", i, operation.OperationIdentifier.Index, operation.Type, operation.Amount.Value, i, tt.wantTypes[i], tt.wantValues[i])
+				}
+			}
+			for i, input := range selected {
+				if coin := operations[i].CoinChange; coin == nil || coin.CoinIdentifier.Identifier != input.CoinIdentifier || coin.CoinAction != types.CoinSpent {
+					t.Errorf("input %d coin change = %v, want %s spent", i, coin, input.CoinIdentifier)
+				}
+			}
+			if output.OperationIdentifier.Index != 0 {
+				t.Errorf("the OUTPUT operation of the request was modified")
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import "testing"
+
+func TestLRUCache(t *testing.T) {
+	type step struct {
+		add   string
+		get   string
+		value int
+		found bool
+	}
+	tests := []struct {
+		name  string
+		size  int
+		steps []step
+	}{
+		{
+			name:  "zero size keeps nothing",
+			size:  0,
+			steps: []step{{add: "a", value: 1}, {get: "a"}},
+		},
+		{
+			name: "evicts the least recently added",
+			size: 2,
+			steps: []step{
+				{add: "a", value: 1}, {add: "b", value: 2}, {add: "c", value: 3},
+				{get: "a"}, {get: "b", value: 2, found: true}, {get: "c", value: 3, found: true},
+			},
+		},
+		{
+			name: "get refreshes an entry",
+			size: 2,
+			steps: []step{
+				{add: "a", value: 1}, {add: "b", value: 2}, {get: "a", value: 1, found: true}, {add: "c", value: 3},
+				{get: "b"}, {get: "a", value: 1, found: true}, {get: "c", value: 3, found: true},
+			},
+		},
+		{
+			name: "add replaces and refreshes an entry",
+			size: 2,
+			steps: []step{
+				{add: "a", value: 1}, {add: "b", value: 2}, {add: "a", value: 10}, {add: "c", value: 3},
+				{get: "b"}, {get: "a", value: 10, found: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache(tt.size)
+			for i, s := range tt.steps {
+				if s.add != "" {
+					c.Add(s.add, s.value)
+					continue
+				}
+				value, found := c.Get(s.get)
+				if found != s.found || (found && value.(int) != s.value) {
+					t.Fatalf("step %d: Get(%s) = %v, %v, want %v, %v", i, s.get, value, found, s.value, s.found)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// MempoolAPIService implements the server.MempoolAPIServicer interface.
+type MempoolAPIService struct {
+	network         *types.NetworkIdentifier
+	client          Client
+	cfg             *config.Config
+	blockAPIService *BlockAPIService
+}
+
+// NewMempoolAPIService creates a new instance of a MempoolAPIService, transactions are rendered by the block service
+// of the network.
+func NewMempoolAPIService(network *types.NetworkIdentifier, client Client, cfg *config.Config, blockAPIService *BlockAPIService) server.MempoolAPIServicer {
+	return &MempoolAPIService{
+		network:         network,
+		client:          client,
+		cfg:             cfg,
+		blockAPIService: blockAPIService,
+	}
+}
+
+// Mempool implements the /mempool endpoint.
+func (s *MempoolAPIService) Mempool(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.MempoolResponse, *types.Error) {
+	txPool, err := s.client.GetRawTxPool(ctx)
+	if err != nil {
+		return nil, wrapErr(RpcError, err)
+	}
+
+	transactionIdentifiers := make([]*types.TransactionIdentifier, 0, len(txPool.Pending)+len(txPool.Proposed))
+	for _, hash := range txPool.Pending {
+		transactionIdentifiers = append(transactionIdentifiers, &types.TransactionIdentifier{
+			Hash: hash.String(),
+		})
+	}
+	for _, hash := range txPool.Proposed {
+		transactionIdentifiers = append(transactionIdentifiers, &types.TransactionIdentifier{
+			Hash: hash.String(),
+		})
+	}
+
+	return &types.MempoolResponse{
+		TransactionIdentifiers: transactionIdentifiers,
+	}, nil
+}
+
+// MempoolTransaction implements the /mempool/transaction endpoint.
+func (s *MempoolAPIService) MempoolTransaction(
+	ctx context.Context,
+	request *types.MempoolTransactionRequest,
+) (*types.MempoolTransactionResponse, *types.Error) {
+	tx, err := s.client.GetTransaction(ctx, ckbTypes.HexToHash(request.TransactionIdentifier.Hash))
+	if err != nil {
+		return nil, wrapErr(RpcError, err)
+	}
+	if tx.TxStatus.Status != ckbTypes.TransactionStatusPending && tx.TxStatus.Status != ckbTypes.TransactionStatusProposed {
+		return nil, wrapErr(TransactionNotInMempoolError, fmt.Errorf("transaction %s is not in mempool", request.TransactionIdentifier.Hash))
+	}
+
+	transaction, rErr := s.blockAPIService.buildTransaction(ctx, tx.Transaction, nil)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return &types.MempoolTransactionResponse{
+		Transaction: transaction,
+	}, nil
+}
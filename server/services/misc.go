@@ -3,8 +3,7 @@ package services
 import (
 	"fmt"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
-	"log"
-	"math"
+	"strings"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/nervosnetwork/ckb-sdk-go/address"
@@ -33,7 +32,7 @@ var (
 	SubmitError = &types.Error{
 		Code:      4,
 		Message:   "Submit transaction error.",
-		Retriable: true,
+		Retriable: false,
 	}
 
 	ServerError = &types.Error{
@@ -86,7 +85,7 @@ var (
 
 	LessThanMinCapacityError = &types.Error{
 		Code:      13,
-		Message:   fmt.Sprintf("Transfer amount must greater than %d CKB", MinCapacity/uint64(math.Pow10(8))),
+		Message:   "Output capacity is less than its occupied capacity.",
 		Retriable: false,
 	}
 
@@ -228,8 +227,141 @@ var (
 		Retriable: false,
 	}
 
+	TransactionNotInMempoolError = &types.Error{
+		Code:      38,
+		Message:   "transaction not in mempool error.",
+		Retriable: true,
+	}
+
+	InvalidMultisigScriptError = &types.Error{
+		Code:      39,
+		Message:   "invalid multisig script error.",
+		Retriable: false,
+	}
+
+	UnsupportedCurrencyError = &types.Error{
+		Code:      40,
+		Message:   "unsupported currency error.",
+		Retriable: false,
+	}
+
+	UDTAmountNotBalancedError = &types.Error{
+		Code:      41,
+		Message:   "sUDT input and output amounts are not balanced.",
+		Retriable: false,
+	}
+
+	BlockNotFoundError = &types.Error{
+		Code:      42,
+		Message:   "block not found error.",
+		Retriable: false,
+	}
+
+	BlockNotIndexedError = &types.Error{
+		Code:      43,
+		Message:   "block not indexed yet error.",
+		Retriable: true,
+	}
+
+	HistoricalBalanceUnavailableError = &types.Error{
+		Code:      44,
+		Message:   "historical balance unavailable error.",
+		Retriable: false,
+	}
+
+	InvalidACPTopUpError = &types.Error{
+		Code:      45,
+		Message:   "invalid anyone-can-pay top up error.",
+		Retriable: false,
+	}
+
+	InvalidSenderError = &types.Error{
+		Code:      46,
+		Message:   "invalid sender error.",
+		Retriable: false,
+	}
+
+	UnavailableOfflineError = &types.Error{
+		Code:      47,
+		Message:   "unavailable in offline mode error.",
+		Retriable: false,
+	}
+
+	EventsUnavailableError = &types.Error{
+		Code:      48,
+		Message:   "events unavailable error.",
+		Retriable: false,
+	}
+
+	InvalidDAOCellError = &types.Error{
+		Code:      49,
+		Message:   "invalid dao cell error.",
+		Retriable: false,
+	}
+
+	InvalidSinceError = &types.Error{
+		Code:      50,
+		Message:   "invalid since error.",
+		Retriable: false,
+	}
+
+	ScriptVerificationError = &types.Error{
+		Code:      51,
+		Message:   "script verification error.",
+		Retriable: false,
+	}
+
+	InsufficientCapacityError = &types.Error{
+		Code:      52,
+		Message:   "insufficient capacity error.",
+		Retriable: false,
+	}
+
+	DeadCellError = &types.Error{
+		Code:      53,
+		Message:   "dead cell error.",
+		Retriable: false,
+	}
+
+	// the cell may be created by a transaction which is not committed yet
+	UnknownCellError = &types.Error{
+		Code:      54,
+		Message:   "unknown cell error.",
+		Retriable: true,
+	}
+
+	DuplicatedTransactionError = &types.Error{
+		Code:      55,
+		Message:   "duplicated transaction error.",
+		Retriable: false,
+	}
+
+	TxPoolFullError = &types.Error{
+		Code:      56,
+		Message:   "tx pool full error.",
+		Retriable: true,
+	}
+
+	FeeTooLowError = &types.Error{
+		Code:      57,
+		Message:   "fee too low error.",
+		Retriable: false,
+	}
+
+	UnsupportedCallMethodError = &types.Error{
+		Code:      58,
+		Message:   "unsupported call method error.",
+		Retriable: false,
+	}
+
+	InvalidCallParametersError = &types.Error{
+		Code:      59,
+		Message:   "invalid call parameters error.",
+		Retriable: false,
+	}
+
 	CkbCurrency = &types.Currency{
-		Symbol:   "CKB",
+		Symbol:   ckb.CkbSymbol,
 		Decimals: 8,
 	}
 
@@ -237,20 +369,31 @@ var (
 		ckb.InputOpType,
 		ckb.OutputOpType,
 		ckb.RewardOpType,
+		ckb.FeeOpType,
+		ckb.DAODepositOpType,
+		ckb.DAOWithdrawOpType,
+		ckb.DAOInterestOpType,
 	}
 
-	SupportedNetworks = map[string]bool{
-		"mainnet": true,
-		"testnet": true,
-		"dev":     true,
+	// SupportedNetworks maps the lowercase network names to their address prefix
+	SupportedNetworks = map[string]address.Mode{
+		"mainnet": address.Mainnet,
+		"testnet": address.Testnet,
+		"dev":     address.Testnet,
 	}
 
 	SupportedConstructionTypes = map[string]bool{
-		ckb.TransferCKB: true,
+		ckb.TransferCKB:             true,
+		ckb.TransferCKBFromMultisig: true,
+		ckb.TransferUDT:             true,
+		ckb.TopUpACP:                true,
+		ckb.GenericCell:             true,
+		ckb.DepositDAO:              true,
+		ckb.WithdrawDAO:             true,
+		ckb.UnlockDAO:               true,
 	}
 
-	MinCapacity   uint64 = 6100000000
-	AllErrorTypes        = []*types.Error{
+	AllErrorTypes = []*types.Error{
 		NoImplementError,
 		RpcError,
 		AddressParseError,
@@ -285,19 +428,45 @@ var (
 		InvalidAccountIdentifierMetadataError,
 		AddressGenerationError,
 		UnsupportedNetworkError,
+		TransactionNotInMempoolError,
+		InvalidMultisigScriptError,
+		UnsupportedCurrencyError,
+		UDTAmountNotBalancedError,
+		BlockNotFoundError,
+		BlockNotIndexedError,
+		HistoricalBalanceUnavailableError,
+		InvalidACPTopUpError,
+		InvalidSenderError,
+		UnavailableOfflineError,
+		EventsUnavailableError,
+		InvalidDAOCellError,
+		InvalidSinceError,
+		ScriptVerificationError,
+		InsufficientCapacityError,
+		DeadCellError,
+		UnknownCellError,
+		DuplicatedTransactionError,
+		TxPoolFullError,
+		FeeTooLowError,
+		UnsupportedCallMethodError,
+		InvalidCallParametersError,
 	}
 )
 
-func GenerateAddress(network *types.NetworkIdentifier, script *typesCKB.Script) string {
-	var mode = address.Mainnet
-	if network.Network != "Mainnet" {
-		mode = address.Testnet
+// AddressMode returns the address prefix of the network, network names are case insensitive
+func AddressMode(network *types.NetworkIdentifier) (address.Mode, error) {
+	mode, ok := SupportedNetworks[strings.ToLower(network.Network)]
+	if !ok {
+		return "", fmt.Errorf("network %s not supported", network.Network)
 	}
+	return mode, nil
+}
 
-	addr, err := address.Generate(mode, script)
+// GenerateAddress encodes the lock script with the address prefix of the network
+func GenerateAddress(network *types.NetworkIdentifier, script *typesCKB.Script) (string, error) {
+	mode, err := AddressMode(network)
 	if err != nil {
-		log.Fatalf("generate address error: %v", err)
+		return "", err
 	}
-
-	return addr
+	return address.Generate(mode, script)
 }
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	ckbTransaction "github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func TestGenerateAddress(t *testing.T) {
+	script := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(ckbTransaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{1}, 20),
+	}
+	tests := []struct {
+		name       string
+		network    string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{name: "mainnet", network: "Mainnet", wantPrefix: "ckb1"},
+		{name: "testnet", network: "Testnet", wantPrefix: "ckt1"},
+		{name: "dev chain", network: "dev", wantPrefix: "ckt1"},
+		{name: "case insensitive", network: "MAINNET", wantPrefix: "ckb1"},
+		{name: "unsupported network", network: "Devnet", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := GenerateAddress(&types.NetworkIdentifier{Blockchain: "CKB", Network: tt.network}, script)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(addr, tt.wantPrefix) {
+				t.Errorf("GenerateAddress() = %s, want prefix %s", addr, tt.wantPrefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+)
+
+var (
+	_ server.NetworkAPIServicer      = (*MultiNetworkAPIService)(nil)
+	_ server.BlockAPIServicer        = (*MultiNetworkAPIService)(nil)
+	_ server.AccountAPIServicer      = (*MultiNetworkAPIService)(nil)
+	_ server.ConstructionAPIServicer = (*MultiNetworkAPIService)(nil)
+	_ server.MempoolAPIServicer      = (*MultiNetworkAPIService)(nil)
+	_ api.EventsAPIServicer          = (*MultiNetworkAPIService)(nil)
+	_ api.SearchAPIServicer          = (*MultiNetworkAPIService)(nil)
+	_ api.CallAPIServicer            = (*MultiNetworkAPIService)(nil)
+)
+
+// NetworkAPIServices are the API services of one hosted network.
+type NetworkAPIServices struct {
+	identifier *types.NetworkIdentifier
+
+	Network      server.NetworkAPIServicer
+	Block        server.BlockAPIServicer
+	Account      server.AccountAPIServicer
+	Construction server.ConstructionAPIServicer
+	Mempool      server.MempoolAPIServicer
+	// Events is nil when the network does not record block events
+	Events api.EventsAPIServicer
+	Search api.SearchAPIServicer
+	Call   api.CallAPIServicer
+}
+
+// MultiNetworkAPIService routes every request to the services of its NetworkIdentifier.
+type MultiNetworkAPIService struct {
+	networks []*types.NetworkIdentifier
+	services map[string]*NetworkAPIServices
+}
+
+// NewMultiNetworkAPIService creates a new instance of a MultiNetworkAPIService.
+func NewMultiNetworkAPIService() *MultiNetworkAPIService {
+	return &MultiNetworkAPIService{
+		services: make(map[string]*NetworkAPIServices),
+	}
+}
+
+// AddNetwork hosts the services of the network, it fails when the network is added twice or has no address prefix.
+func (s *MultiNetworkAPIService) AddNetwork(network *types.NetworkIdentifier, services *NetworkAPIServices) error {
+	if _, err := AddressMode(network); err != nil {
+		return err
+	}
+	if _, ok := s.services[network.Network]; ok {
+		return fmt.Errorf("network %s is configured twice", network.Network)
+	}
+	services.identifier = network
+	s.networks = append(s.networks, network)
+	s.services[network.Network] = services
+	return nil
+}
+
+// Networks returns the hosted networks in the order they were added.
+func (s *MultiNetworkAPIService) Networks() []*types.NetworkIdentifier {
+	return s.networks
+}
+
+func (s *MultiNetworkAPIService) lookup(network *types.NetworkIdentifier) (*NetworkAPIServices, *types.Error) {
+	if network == nil {
+		return nil, wrapErr(UnsupportedNetworkError, fmt.Errorf("missing network identifier"))
+	}
+	services, ok := s.services[network.Network]
+	if !ok {
+		return nil, wrapErr(UnsupportedNetworkError, fmt.Errorf("network %s not supported", network.Network))
+	}
+	if types.Hash(services.identifier) != types.Hash(network) {
+		return nil, wrapErr(UnsupportedNetworkError, fmt.Errorf("network identifier %s does not match %s", types.PrettyPrintStruct(network), types.PrettyPrintStruct(services.identifier)))
+	}
+	return services, nil
+}
+
+// NetworkList implements the /network/list endpoint.
+func (s *MultiNetworkAPIService) NetworkList(
+	ctx context.Context,
+	request *types.MetadataRequest,
+) (*types.NetworkListResponse, *types.Error) {
+	networkIdentifiers := make([]*types.NetworkIdentifier, 0, len(s.networks))
+	for _, network := range s.networks {
+		response, rErr := s.services[network.Network].Network.NetworkList(ctx, request)
+		if rErr != nil {
+			return nil, rErr
+		}
+		networkIdentifiers = append(networkIdentifiers, response.NetworkIdentifiers...)
+	}
+
+	return &types.NetworkListResponse{
+		NetworkIdentifiers: networkIdentifiers,
+	}, nil
+}
+
+// NetworkStatus implements the /network/status endpoint.
+func (s *MultiNetworkAPIService) NetworkStatus(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkStatusResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Network.NetworkStatus(ctx, request)
+}
+
+// NetworkOptions implements the /network/options endpoint.
+func (s *MultiNetworkAPIService) NetworkOptions(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkOptionsResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Network.NetworkOptions(ctx, request)
+}
+
+// Block implements the /block endpoint.
+func (s *MultiNetworkAPIService) Block(
+	ctx context.Context,
+	request *types.BlockRequest,
+) (*types.BlockResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Block.Block(ctx, request)
+}
+
+// BlockTransaction implements the /block/transaction endpoint.
+func (s *MultiNetworkAPIService) BlockTransaction(
+	ctx context.Context,
+	request *types.BlockTransactionRequest,
+) (*types.BlockTransactionResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Block.BlockTransaction(ctx, request)
+}
+
+// AccountBalance implements the /account/balance endpoint.
+func (s *MultiNetworkAPIService) AccountBalance(
+	ctx context.Context,
+	request *types.AccountBalanceRequest,
+) (*types.AccountBalanceResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Account.AccountBalance(ctx, request)
+}
+
+// ConstructionPreprocess implements the /construction/preprocess endpoint.
+func (s *MultiNetworkAPIService) ConstructionPreprocess(
+	ctx context.Context,
+	request *types.ConstructionPreprocessRequest,
+) (*types.ConstructionPreprocessResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionPreprocess(ctx, request)
+}
+
+// ConstructionMetadata implements the /construction/metadata endpoint.
+func (s *MultiNetworkAPIService) ConstructionMetadata(
+	ctx context.Context,
+	request *types.ConstructionMetadataRequest,
+) (*types.ConstructionMetadataResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionMetadata(ctx, request)
+}
+
+// ConstructionPayloads implements the /construction/payloads endpoint.
+func (s *MultiNetworkAPIService) ConstructionPayloads(
+	ctx context.Context,
+	request *types.ConstructionPayloadsRequest,
+) (*types.ConstructionPayloadsResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionPayloads(ctx, request)
+}
+
+// ConstructionCombine implements the /construction/combine endpoint.
+func (s *MultiNetworkAPIService) ConstructionCombine(
+	ctx context.Context,
+	request *types.ConstructionCombineRequest,
+) (*types.ConstructionCombineResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionCombine(ctx, request)
+}
+
+// ConstructionParse implements the /construction/parse endpoint.
+func (s *MultiNetworkAPIService) ConstructionParse(
+	ctx context.Context,
+	request *types.ConstructionParseRequest,
+) (*types.ConstructionParseResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionParse(ctx, request)
+}
+
+// ConstructionHash implements the /construction/hash endpoint.
+func (s *MultiNetworkAPIService) ConstructionHash(
+	ctx context.Context,
+	request *types.ConstructionHashRequest,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionHash(ctx, request)
+}
+
+// ConstructionSubmit implements the /construction/submit endpoint.
+func (s *MultiNetworkAPIService) ConstructionSubmit(
+	ctx context.Context,
+	request *types.ConstructionSubmitRequest,
+) (*types.TransactionIdentifierResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionSubmit(ctx, request)
+}
+
+// ConstructionDerive implements the /construction/derive endpoint.
+func (s *MultiNetworkAPIService) ConstructionDerive(
+	ctx context.Context,
+	request *types.ConstructionDeriveRequest,
+) (*types.ConstructionDeriveResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return services.Construction.ConstructionDerive(ctx, request)
+}
+
+// Mempool implements the /mempool endpoint.
+func (s *MultiNetworkAPIService) Mempool(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.MempoolResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	if services.Mempool == nil {
+		return nil, UnavailableOfflineError
+	}
+	return services.Mempool.Mempool(ctx, request)
+}
+
+// MempoolTransaction implements the /mempool/transaction endpoint.
+func (s *MultiNetworkAPIService) MempoolTransaction(
+	ctx context.Context,
+	request *types.MempoolTransactionRequest,
+) (*types.MempoolTransactionResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	if services.Mempool == nil {
+		return nil, UnavailableOfflineError
+	}
+	return services.Mempool.MempoolTransaction(ctx, request)
+}
+
+// EventsBlocks implements the /events/blocks endpoint.
+func (s *MultiNetworkAPIService) EventsBlocks(
+	ctx context.Context,
+	request *api.EventsBlocksRequest,
+) (*api.EventsBlocksResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	if services.Events == nil {
+		return nil, EventsUnavailableError
+	}
+	return services.Events.EventsBlocks(ctx, request)
+}
+
+// SearchTransactions implements the /search/transactions endpoint.
+func (s *MultiNetworkAPIService) SearchTransactions(
+	ctx context.Context,
+	request *api.SearchTransactionsRequest,
+) (*api.SearchTransactionsResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	if services.Search == nil {
+		return nil, UnavailableOfflineError
+	}
+	return services.Search.SearchTransactions(ctx, request)
+}
+
+// Call implements the /call endpoint.
+func (s *MultiNetworkAPIService) Call(
+	ctx context.Context,
+	request *api.CallRequest,
+) (*api.CallResponse, *types.Error) {
+	services, rErr := s.lookup(request.NetworkIdentifier)
+	if rErr != nil {
+		return nil, rErr
+	}
+	if services.Call == nil {
+		return nil, UnavailableOfflineError
+	}
+	return services.Call.Call(ctx, request)
+}
@@ -103,8 +103,9 @@ func (s *NetworkAPIService) NetworkOptions(
 					Successful: true,
 				},
 			},
-			OperationTypes: SupportedOperationTypes,
-			Errors:         AllErrorTypes,
+			OperationTypes:          SupportedOperationTypes,
+			Errors:                  AllErrorTypes,
+			HistoricalBalanceLookup: true,
 		},
 	}, nil
 }
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+
+	"github.com/coinbase/rosetta-sdk-go/server"
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+var (
+	_ server.NetworkAPIServicer = (*OfflineAPIService)(nil)
+	_ server.BlockAPIServicer   = (*OfflineAPIService)(nil)
+	_ server.AccountAPIServicer = (*OfflineAPIService)(nil)
+)
+
+// OfflineAPIService implements the endpoints which need a node when the server runs in offline mode,
+// every request fails with UnavailableOfflineError.
+type OfflineAPIService struct{}
+
+// NewOfflineAPIService creates a new instance of an OfflineAPIService.
+func NewOfflineAPIService() *OfflineAPIService {
+	return &OfflineAPIService{}
+}
+
+// NetworkList implements the /network/list endpoint.
+func (s *OfflineAPIService) NetworkList(
+	ctx context.Context,
+	request *types.MetadataRequest,
+) (*types.NetworkListResponse, *types.Error) {
+	return nil, UnavailableOfflineError
+}
+
+// NetworkStatus implements the /network/status endpoint.
+func (s *OfflineAPIService) NetworkStatus(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkStatusResponse, *types.Error) {
+	return nil, UnavailableOfflineError
+}
+
+// NetworkOptions implements the /network/options endpoint.
+func (s *OfflineAPIService) NetworkOptions(
+	ctx context.Context,
+	request *types.NetworkRequest,
+) (*types.NetworkOptionsResponse, *types.Error) {
+	return nil, UnavailableOfflineError
+}
+
+// Block implements the /block endpoint.
+func (s *OfflineAPIService) Block(
+	ctx context.Context,
+	request *types.BlockRequest,
+) (*types.BlockResponse, *types.Error) {
+	return nil, UnavailableOfflineError
+}
+
+// BlockTransaction implements the /block/transaction endpoint.
+func (s *OfflineAPIService) BlockTransaction(
+	ctx context.Context,
+	request *types.BlockTransactionRequest,
+) (*types.BlockTransactionResponse, *types.Error) {
+	return nil, UnavailableOfflineError
+}
+
+// AccountBalance implements the /account/balance endpoint.
+func (s *OfflineAPIService) AccountBalance(
+	ctx context.Context,
+	request *types.AccountBalanceRequest,
+) (*types.AccountBalanceResponse, *types.Error) {
+	return nil, UnavailableOfflineError
+}
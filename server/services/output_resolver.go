@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// OutputResolver resolves the cells referenced by out points, which are usually the previous outputs of inputs.
+type OutputResolver interface {
+	// Resolve returns the cells keyed by tx_hash:index
+	Resolve(outPoints []*ckbTypes.OutPoint) (map[string]*ResolvedOutput, *types.Error)
+}
+
+type ResolvedOutput struct {
+	Output *ckbTypes.CellOutput
+	Data   []byte
+}
+
+var _ OutputResolver = (*outputResolver)(nil)
+
+type outputResolver struct {
+	client      rpc.Client
+	cache       *lruCache
+	concurrency int
+}
+
+// NewOutputResolver creates an OutputResolver which memoizes Cache.Outputs cells and fetches the transactions
+// of missing cells in batches of BatchTransactionsLimit with at most Cache.Concurrency batches in flight.
+// The node has no rpc returning a spent cell, so the whole transaction is fetched to read the outputs an input
+// spends, the transaction is fetched once however many of its outputs are resolved.
+func NewOutputResolver(client rpc.Client, cfg *config.Config) OutputResolver {
+	concurrency := cfg.Cache.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &outputResolver{
+		client:      client,
+		cache:       newLRUCache(cfg.Cache.Outputs),
+		concurrency: concurrency,
+	}
+}
+
+func outPointKey(outPoint *ckbTypes.OutPoint) string {
+	return fmt.Sprintf("%s:%d", outPoint.TxHash.String(), outPoint.Index)
+}
+
+func (r *outputResolver) Resolve(outPoints []*ckbTypes.OutPoint) (map[string]*ResolvedOutput, *types.Error) {
+	outputs := make(map[string]*ResolvedOutput)
+	missing := make(map[ckbTypes.Hash][]*ckbTypes.OutPoint)
+	var batchReq []ckbTypes.BatchTransactionItem
+	for _, outPoint := range outPoints {
+		key := outPointKey(outPoint)
+		if _, ok := outputs[key]; ok {
+			continue
+		}
+		if cached, ok := r.cache.Get(key); ok {
+			outputs[key] = cached.(*ResolvedOutput)
+			continue
+		}
+		outputs[key] = nil
+		if _, ok := missing[outPoint.TxHash]; !ok {
+			batchReq = append(batchReq, ckbTypes.BatchTransactionItem{
+				Hash:   outPoint.TxHash,
+				Result: &ckbTypes.TransactionWithStatus{},
+			})
+		}
+		missing[outPoint.TxHash] = append(missing[outPoint.TxHash], outPoint)
+	}
+
+	if err := r.batchTransactions(batchReq); err != nil {
+		return nil, wrapErr(RpcError, err)
+	}
+
+	for _, req := range batchReq {
+		if req.Error != nil {
+			return nil, wrapErr(RpcError, req.Error)
+		}
+		if req.Result.Transaction == nil {
+			return nil, wrapErr(RpcError, fmt.Errorf("transaction %s not found", req.Hash.String()))
+		}
+		tx := req.Result.Transaction
+		for _, outPoint := range missing[req.Hash] {
+			if int(outPoint.Index) >= len(tx.Outputs) || int(outPoint.Index) >= len(tx.OutputsData) {
+				return nil, wrapErr(ServerError, fmt.Errorf("output index %d out of range of transaction %s", outPoint.Index, req.Hash.String()))
+			}
+			key := outPointKey(outPoint)
+			outputs[key] = &ResolvedOutput{
+				Output: tx.Outputs[outPoint.Index],
+				Data:   tx.OutputsData[outPoint.Index],
+			}
+			r.cache.Add(key, outputs[key])
+		}
+	}
+
+	return outputs, nil
+}
+
+func (r *outputResolver) batchTransactions(batchReq []ckbTypes.BatchTransactionItem) error {
+	semaphore := make(chan struct{}, r.concurrency)
+	errs := make(chan error, len(batchReq)/ckb.BatchTransactionsLimit+1)
+	var wg sync.WaitGroup
+	for start := 0; start < len(batchReq); start += ckb.BatchTransactionsLimit {
+		end := start + ckb.BatchTransactionsLimit
+		if end > len(batchReq) {
+			end = len(batchReq)
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(batch []ckbTypes.BatchTransactionItem) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if err := r.client.BatchTransactions(context.Background(), batch); err != nil {
+				errs <- err
+			}
+		}(batchReq[start:end])
+	}
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
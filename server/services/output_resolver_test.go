@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// batchClient serves the transactions it holds and records the hashes of every batch
+type batchClient struct {
+	rpc.Client
+	txs     map[ckbTypes.Hash]*ckbTypes.Transaction
+	batches [][]ckbTypes.Hash
+}
+
+func (c *batchClient) BatchTransactions(ctx context.Context, batch []ckbTypes.BatchTransactionItem) error {
+	var hashes []ckbTypes.Hash
+	for i := range batch {
+		hashes = append(hashes, batch[i].Hash)
+		if tx, ok := c.txs[batch[i].Hash]; ok {
+			batch[i].Result.Transaction = tx
+		}
+	}
+	c.batches = append(c.batches, hashes)
+	return nil
+}
+
+func TestOutputResolverResolve(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Cache.Outputs = 10
+	txA := ckbTypes.BytesToHash([]byte{1})
+	txB := ckbTypes.BytesToHash([]byte{2})
+	missingTx := ckbTypes.BytesToHash([]byte{3})
+	newClient := func() *batchClient {
+		tx := func(capacities ...uint64) *ckbTypes.Transaction {
+			tx := &ckbTypes.Transaction{}
+			for _, capacity := range capacities {
+				tx.Outputs = append(tx.Outputs, &ckbTypes.CellOutput{Capacity: capacity})
+				tx.OutputsData = append(tx.OutputsData, []byte{})
+			}
+			return tx
+		}
+		return &batchClient{txs: map[ckbTypes.Hash]*ckbTypes.Transaction{txA: tx(1, 2, 3), txB: tx(4)}}
+	}
+	outPoint := func(hash ckbTypes.Hash, index uint) *ckbTypes.OutPoint {
+		return &ckbTypes.OutPoint{TxHash: hash, Index: index}
+	}
+
+	tests := []struct {
+		name           string
+		outPoints      []*ckbTypes.OutPoint
+		wantCapacities []uint64
+		wantFetches    int
+		wantErr        *types.Error
+	}{
+		{name: "no out points"},
+		{
+			name:           "outputs of one transaction are fetched once",
+			outPoints:      []*ckbTypes.OutPoint{outPoint(txA, 0), outPoint(txA, 2), outPoint(txB, 0)},
+			wantCapacities: []uint64{1, 3, 4},
+			wantFetches:    2,
+		},
+		{
+			name:           "duplicated out point",
+			outPoints:      []*ckbTypes.OutPoint{outPoint(txA, 1), outPoint(txA, 1)},
+			wantCapacities: []uint64{2, 2},
+			wantFetches:    1,
+		},
+		{name: "unknown transaction", outPoints: []*ckbTypes.OutPoint{outPoint(missingTx, 0)}, wantErr: RpcError},
+		{name: "index out of range", outPoints: []*ckbTypes.OutPoint{outPoint(txB, 1)}, wantErr: ServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient()
+			resolver := NewOutputResolver(client, cfg)
+			outputs, rErr := resolver.Resolve(tt.outPoints)
+			assertError(t, rErr, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+			for i, outPoint := range tt.outPoints {
+				if got := outputs[outPointKey(outPoint)].Output.Capacity; got != tt.wantCapacities[i] {
+					t.Errorf("output %s capacity = %d, want %d", outPointKey(outPoint), got, tt.wantCapacities[i])
+				}
+			}
+			var fetches int
+			for _, batch := range client.batches {
+				fetches += len(batch)
+			}
+			if fetches != tt.wantFetches {
+				t.Errorf("fetched %d transactions, want %d", fetches, tt.wantFetches)
+			}
+
+			// resolved outputs are served from the cache
+			client.batches = nil
+			if _, rErr := resolver.Resolve(tt.outPoints); rErr != nil {
+				t.Fatal(rErr)
+			}
+			if len(client.batches) != 0 {
+				t.Errorf("cached outputs fetched again: %v", client.batches)
+			}
+		})
+	}
+}
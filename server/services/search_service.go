@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/api"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// searchCursorsSize is the number of next pages whose indexer position is kept
+const searchCursorsSize = 1000
+
+// SearchAPIService implements the api.SearchAPIServicer interface.
+type SearchAPIService struct {
+	network         *types.NetworkIdentifier
+	client          rpc.Client
+	cfg             *config.Config
+	blockAPIService *BlockAPIService
+	// cursors keeps the indexer position of the next page of lock searches by query and offset
+	cursors *lruCache
+}
+
+// NewSearchAPIService creates a new instance of a SearchAPIService, transactions are rendered by the block service
+// of the network.
+func NewSearchAPIService(network *types.NetworkIdentifier, client rpc.Client, cfg *config.Config, blockAPIService *BlockAPIService) api.SearchAPIServicer {
+	return &SearchAPIService{
+		network:         network,
+		client:          client,
+		cfg:             cfg,
+		blockAPIService: blockAPIService,
+		cursors:         newLRUCache(searchCursorsSize),
+	}
+}
+
+type searchMatch struct {
+	blockNumber uint64
+	txHash      ckbTypes.Hash
+}
+
+// searchCursor is a position in the transactions of a lock, the walk continues with the indexer page after
+// cursor by skipping the skip transactions already walked. lastTxHash is the last transaction matched,
+// the entries of one transaction are adjacent.
+type searchCursor struct {
+	cursor     string
+	skip       int
+	lastTxHash ckbTypes.Hash
+}
+
+// SearchTransactions implements the /search/transactions endpoint.
+func (s *SearchAPIService) SearchTransactions(
+	ctx context.Context,
+	request *api.SearchTransactionsRequest,
+) (*api.SearchTransactionsResponse, *types.Error) {
+	limit := int64(ckb.SearchTransactionsLimit)
+	if request.Limit != nil && *request.Limit > 0 && *request.Limit < limit {
+		limit = *request.Limit
+	}
+	var offset int64
+	if request.Offset != nil {
+		offset = *request.Offset
+	}
+	maxBlock := uint64(math.MaxUint64)
+	if request.MaxBlock != nil {
+		maxBlock = uint64(*request.MaxBlock)
+	}
+	var txHash *ckbTypes.Hash
+	if request.TransactionIdentifier != nil {
+		hash := ckbTypes.HexToHash(request.TransactionIdentifier.Hash)
+		txHash = &hash
+	}
+
+	var lock *ckbTypes.Script
+	if request.AccountIdentifier != nil {
+		addr, err := address.Parse(request.AccountIdentifier.Address)
+		if err != nil {
+			return nil, wrapErr(AddressParseError, err)
+		}
+		lock = addr.Script
+	}
+	var coin *ckbTypes.OutPoint
+	if request.CoinIdentifier != nil {
+		outPoint, err := builder.GenerateOutPointFromCoinIdentifier(request.CoinIdentifier.Identifier)
+		if err != nil {
+			return nil, wrapErr(CoinIdentifierInvalidError, err)
+		}
+		coinLock, rErr := s.coinLock(ctx, outPoint)
+		if rErr != nil {
+			return nil, rErr
+		}
+		// the coin does not exist or belongs to another account
+		if coinLock == nil || (lock != nil && !reflect.DeepEqual(lock, coinLock)) {
+			return &api.SearchTransactionsResponse{Transactions: []*api.BlockTransaction{}}, nil
+		}
+		coin = outPoint
+		lock = coinLock
+	}
+
+	var matches []*searchMatch
+	var total int64
+	var rErr *types.Error
+	if lock == nil {
+		matches, rErr = s.searchTransaction(ctx, *txHash, maxBlock)
+		total = int64(len(matches))
+		if total <= offset {
+			matches = nil
+		} else {
+			matches = matches[offset:]
+		}
+	} else {
+		matches, total, rErr = s.searchLockPage(ctx, lock, coin, txHash, maxBlock, offset, limit)
+	}
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	response := &api.SearchTransactionsResponse{
+		Transactions: []*api.BlockTransaction{},
+		TotalCount:   total,
+	}
+	for _, match := range matches {
+		blockTransaction, rErr := s.buildBlockTransaction(ctx, match)
+		if rErr != nil {
+			return nil, rErr
+		}
+		response.Transactions = append(response.Transactions, blockTransaction)
+	}
+	if offset+limit < total {
+		nextOffset := offset + limit
+		response.NextOffset = &nextOffset
+	}
+
+	return response, nil
+}
+
+// searchLockPage returns the matches of the page at offset and the number of all matches. The walk resumes from
+// the position a previous page recorded for offset, otherwise it starts from the newest transaction and skips
+// offset matches. The matches after the page are walked to the oldest transaction to count them.
+func (s *SearchAPIService) searchLockPage(
+	ctx context.Context,
+	lock *ckbTypes.Script,
+	coin *ckbTypes.OutPoint,
+	txHash *ckbTypes.Hash,
+	maxBlock uint64,
+	offset int64,
+	limit int64,
+) ([]*searchMatch, int64, *types.Error) {
+	lockHash, err := lock.Hash()
+	if err != nil {
+		return nil, 0, wrapErr(ComputeHashError, err)
+	}
+	query := fmt.Sprintf("%s:%d", lockHash.String(), maxBlock)
+	if coin != nil {
+		query = fmt.Sprintf("%s:%s", query, outPointKey(coin))
+	}
+	if txHash != nil {
+		query = fmt.Sprintf("%s:%s", query, txHash.String())
+	}
+	from := &searchCursor{}
+	skip := offset
+	if cached, ok := s.cursors.Get(fmt.Sprintf("%s:%d", query, offset)); ok {
+		from = cached.(*searchCursor)
+		skip = 0
+	}
+
+	// one more match than the page tells whether there is a next page
+	matches, cursors, rErr := s.searchLockTransactions(ctx, lock, coin, txHash, maxBlock, from, skip+limit+1)
+	if rErr != nil {
+		return nil, 0, rErr
+	}
+	// the walk started after the offset - skip matches before from
+	total := offset - skip + int64(len(matches))
+	if int64(len(matches)) > skip+limit {
+		s.cursors.Add(fmt.Sprintf("%s:%d", query, offset+limit), cursors[skip+limit-1])
+		rest, _, rErr := s.searchLockTransactions(ctx, lock, coin, txHash, maxBlock, cursors[len(cursors)-1], math.MaxInt64)
+		if rErr != nil {
+			return nil, 0, rErr
+		}
+		total += int64(len(rest))
+	}
+	if int64(len(matches)) <= skip {
+		return nil, total, nil
+	}
+	matches = matches[skip:]
+	if int64(len(matches)) > limit {
+		matches = matches[:limit]
+	}
+	return matches, total, nil
+}
+
+// coinLock returns the lock of the cell, nil when its transaction is not committed
+func (s *SearchAPIService) coinLock(ctx context.Context, outPoint *ckbTypes.OutPoint) (*ckbTypes.Script, *types.Error) {
+	tx, err := s.client.GetTransaction(ctx, outPoint.TxHash)
+	if err != nil {
+		return nil, wrapErr(RpcError, err)
+	}
+	if tx == nil || tx.Transaction == nil || tx.TxStatus.Status != ckbTypes.TransactionStatusCommitted {
+		return nil, nil
+	}
+	if int(outPoint.Index) >= len(tx.Transaction.Outputs) {
+		return nil, nil
+	}
+	return tx.Transaction.Outputs[outPoint.Index].Lock, nil
+}
+
+// searchTransaction matches the transaction when it is committed at or below maxBlock
+func (s *SearchAPIService) searchTransaction(ctx context.Context, txHash ckbTypes.Hash, maxBlock uint64) ([]*searchMatch, *types.Error) {
+	tx, err := s.client.GetTransaction(ctx, txHash)
+	if err != nil {
+		return nil, wrapErr(RpcError, err)
+	}
+	if tx == nil || tx.Transaction == nil || tx.TxStatus.Status != ckbTypes.TransactionStatusCommitted {
+		return nil, nil
+	}
+	header, err := s.client.GetHeader(ctx, *tx.TxStatus.BlockHash)
+	if err != nil {
+		return nil, wrapErr(RpcError, err)
+	}
+	if header.Number > maxBlock {
+		return nil, nil
+	}
+	return []*searchMatch{{blockNumber: header.Number, txHash: txHash}}, nil
+}
+
+// searchLockTransactions walks the transactions of the lock from the position from towards older ones until count
+// matches are found, it returns the position after every match. A coin is matched by the transaction creating it
+// and the transaction spending it.
+func (s *SearchAPIService) searchLockTransactions(
+	ctx context.Context,
+	lock *ckbTypes.Script,
+	coin *ckbTypes.OutPoint,
+	txHash *ckbTypes.Hash,
+	maxBlock uint64,
+	from *searchCursor,
+	count int64,
+) ([]*searchMatch, []*searchCursor, *types.Error) {
+	var matches []*searchMatch
+	var cursors []*searchCursor
+	cursor := from.cursor
+	skip := from.skip
+	lastTxHash := from.lastTxHash
+	for int64(len(matches)) < count {
+		transactions, err := s.client.GetTransactions(ctx, &indexer.SearchKey{
+			Script:     lock,
+			ScriptType: indexer.ScriptTypeLock,
+		}, indexer.SearchOrderDesc, ckb.SearchLimit, cursor)
+		if err != nil {
+			return nil, nil, wrapErr(RpcError, err)
+		}
+		if skip > len(transactions.Objects) {
+			skip = len(transactions.Objects)
+		}
+		var spent map[int]bool
+		if coin != nil {
+			var rErr *types.Error
+			spent, rErr = s.spends(ctx, transactions.Objects[skip:], coin)
+			if rErr != nil {
+				return nil, nil, rErr
+			}
+		}
+		for i := skip; i < len(transactions.Objects) && int64(len(matches)) < count; i++ {
+			transaction := transactions.Objects[i]
+			if transaction.TxHash == lastTxHash || transaction.BlockNumber > maxBlock {
+				continue
+			}
+			if txHash != nil && transaction.TxHash != *txHash {
+				continue
+			}
+			created := false
+			if coin != nil {
+				created = transaction.IoType == indexer.IOTypeOut && transaction.TxHash == coin.TxHash && transaction.IoIndex == coin.Index
+				if !created && !spent[i-skip] {
+					continue
+				}
+			}
+			lastTxHash = transaction.TxHash
+			matches = append(matches, &searchMatch{blockNumber: transaction.BlockNumber, txHash: transaction.TxHash})
+			cursors = append(cursors, &searchCursor{cursor: cursor, skip: i + 1, lastTxHash: lastTxHash})
+			// the spending transaction is newer, nothing older touches the coin
+			if created {
+				return matches, cursors, nil
+			}
+		}
+		if len(transactions.Objects) < ckb.SearchLimit || transactions.LastCursor == "" {
+			break
+		}
+		cursor = transactions.LastCursor
+		skip = 0
+	}
+
+	return matches, cursors, nil
+}
+
+// spends fetches the transactions of the input entries in one batch and returns the positions of the entries
+// whose input spends the coin
+func (s *SearchAPIService) spends(ctx context.Context, transactions []*indexer.Transaction, coin *ckbTypes.OutPoint) (map[int]bool, *types.Error) {
+	var batchReq []ckbTypes.BatchTransactionItem
+	batchIndex := make(map[ckbTypes.Hash]int)
+	for _, transaction := range transactions {
+		if transaction.IoType != indexer.IOTypeIn {
+			continue
+		}
+		if _, ok := batchIndex[transaction.TxHash]; ok {
+			continue
+		}
+		batchIndex[transaction.TxHash] = len(batchReq)
+		batchReq = append(batchReq, ckbTypes.BatchTransactionItem{
+			Hash:   transaction.TxHash,
+			Result: &ckbTypes.TransactionWithStatus{},
+		})
+	}
+	for start := 0; start < len(batchReq); start += ckb.BatchTransactionsLimit {
+		end := start + ckb.BatchTransactionsLimit
+		if end > len(batchReq) {
+			end = len(batchReq)
+		}
+		if err := s.client.BatchTransactions(ctx, batchReq[start:end]); err != nil {
+			return nil, wrapErr(RpcError, err)
+		}
+	}
+
+	spent := make(map[int]bool)
+	for i, transaction := range transactions {
+		if transaction.IoType != indexer.IOTypeIn {
+			continue
+		}
+		req := batchReq[batchIndex[transaction.TxHash]]
+		if req.Error != nil {
+			return nil, wrapErr(RpcError, req.Error)
+		}
+		tx := req.Result.Transaction
+		if tx == nil || int(transaction.IoIndex) >= len(tx.Inputs) {
+			return nil, wrapErr(RpcError, fmt.Errorf("transaction %s not found", transaction.TxHash.String()))
+		}
+		previousOutput := tx.Inputs[transaction.IoIndex].PreviousOutput
+		spent[i] = previousOutput.TxHash == coin.TxHash && previousOutput.Index == coin.Index
+	}
+	return spent, nil
+}
+
+// buildBlockTransaction renders the transaction with the operations of /block/transaction
+func (s *SearchAPIService) buildBlockTransaction(ctx context.Context, match *searchMatch) (*api.BlockTransaction, *types.Error) {
+	tx, err := s.client.GetTransaction(ctx, match.txHash)
+	if err != nil {
+		return nil, wrapErr(RpcError, err)
+	}
+	if tx == nil || tx.Transaction == nil || tx.TxStatus.BlockHash == nil {
+		return nil, wrapErr(RpcError, fmt.Errorf("transaction %s not found", match.txHash.String()))
+	}
+	transaction, rErr := s.blockAPIService.buildTransaction(ctx, tx.Transaction, tx.TxStatus.BlockHash)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	return &api.BlockTransaction{
+		BlockIdentifier: &types.BlockIdentifier{
+			Index: int64(match.blockNumber),
+			Hash:  tx.TxStatus.BlockHash.String(),
+		},
+		Transaction: transaction,
+	}, nil
+}
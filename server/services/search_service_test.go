@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/nervosnetwork/ckb-sdk-go/indexer"
+	"github.com/nervosnetwork/ckb-sdk-go/rpc"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+// indexerClient pages the indexer entries of one lock, newest first, the cursor is the position of the last entry
+type indexerClient struct {
+	rpc.Client
+	entries []*indexer.Transaction
+	txs     map[ckbTypes.Hash]*ckbTypes.Transaction
+	// cursors are the cursors of the GetTransactions calls, batches counts the BatchTransactions calls
+	cursors []string
+	batches int
+}
+
+func (c *indexerClient) GetTransactions(ctx context.Context, searchKey *indexer.SearchKey, order indexer.SearchOrder, limit uint64, afterCursor string) (*indexer.Transactions, error) {
+	c.cursors = append(c.cursors, afterCursor)
+	start := 0
+	if afterCursor != "" {
+		last, err := strconv.Atoi(afterCursor)
+		if err != nil {
+			return nil, err
+		}
+		start = last + 1
+	}
+	end := start + int(limit)
+	if end > len(c.entries) {
+		end = len(c.entries)
+	}
+	transactions := &indexer.Transactions{Objects: c.entries[start:end]}
+	if end > start {
+		transactions.LastCursor = strconv.Itoa(end - 1)
+	}
+	return transactions, nil
+}
+
+func (c *indexerClient) BatchTransactions(ctx context.Context, batch []ckbTypes.BatchTransactionItem) error {
+	c.batches++
+	for i := range batch {
+		batch[i].Result.Transaction = c.txs[batch[i].Hash]
+	}
+	return nil
+}
+
+func testTxHash(i int) ckbTypes.Hash {
+	return ckbTypes.BytesToHash([]byte{0x10, byte(i >> 8), byte(i)})
+}
+
+func TestSearchLockPage(t *testing.T) {
+	lock := &ckbTypes.Script{CodeHash: ckbTypes.HexToHash("0x01"), HashType: ckbTypes.HashTypeType}
+	// transaction i is committed in block 3000 - i with an output entry, every third one also has an input entry
+	var entries []*indexer.Transaction
+	for i := 0; i < 2500; i++ {
+		entries = append(entries, &indexer.Transaction{BlockNumber: uint64(3000 - i), TxHash: testTxHash(i), IoType: indexer.IOTypeOut})
+		if i%3 == 0 {
+			entries = append(entries, &indexer.Transaction{BlockNumber: uint64(3000 - i), TxHash: testTxHash(i), IoType: indexer.IOTypeIn})
+		}
+	}
+	// transaction 5 creates the coin at output 1, transaction 2 spends it at input 0 and transaction 1 has another input
+	coin := &ckbTypes.OutPoint{TxHash: testTxHash(5), Index: 1}
+	coinEntries := []*indexer.Transaction{
+		{BlockNumber: 2999, TxHash: testTxHash(1), IoType: indexer.IOTypeIn},
+		{BlockNumber: 2998, TxHash: testTxHash(2), IoType: indexer.IOTypeOut},
+		{BlockNumber: 2998, TxHash: testTxHash(2), IoType: indexer.IOTypeIn},
+		{BlockNumber: 2997, TxHash: testTxHash(3), IoType: indexer.IOTypeOut},
+		{BlockNumber: 2995, TxHash: testTxHash(5), IoType: indexer.IOTypeOut},
+		{BlockNumber: 2995, TxHash: testTxHash(5), IoType: indexer.IOTypeOut, IoIndex: 1},
+		{BlockNumber: 2990, TxHash: testTxHash(10), IoType: indexer.IOTypeOut},
+	}
+	coinTxs := map[ckbTypes.Hash]*ckbTypes.Transaction{
+		testTxHash(1): {Inputs: []*ckbTypes.CellInput{{PreviousOutput: &ckbTypes.OutPoint{TxHash: testTxHash(5), Index: 0}}}},
+		testTxHash(2): {Inputs: []*ckbTypes.CellInput{{PreviousOutput: coin}}},
+	}
+	txHash := testTxHash(3)
+
+	type page struct {
+		offset int64
+		limit  int64
+		// want are the transaction numbers of the page
+		want []int
+		// wantCursor is the cursor the walk starts from, a page resumed beyond the first indexer page does not
+		// start from the newest transaction
+		wantCursor string
+	}
+	tests := []struct {
+		name     string
+		entries  []*indexer.Transaction
+		txs      map[ckbTypes.Hash]*ckbTypes.Transaction
+		coin     *ckbTypes.OutPoint
+		txHash   *ckbTypes.Hash
+		maxBlock uint64
+		pages    []page
+		// wantTotal is the number of all matches
+		wantTotal int64
+		// wantBatches counts the batches of every page, each page is searched twice
+		wantBatches int
+	}{
+		{
+			name:     "pages resume from the cursor",
+			entries:  entries,
+			maxBlock: 3000,
+			pages: []page{
+				{offset: 0, limit: 2, want: []int{0, 1}},
+				{offset: 2, limit: 2, want: []int{2, 3}},
+				{offset: 4, limit: 100, want: seq(4, 104)},
+			},
+			wantTotal: 2500,
+		},
+		{
+			name:     "page across indexer pages",
+			entries:  entries,
+			maxBlock: 3000,
+			pages: []page{
+				{offset: 0, limit: 100, want: seq(0, 100)},
+				{offset: 100, limit: 100, want: seq(100, 200)},
+				{offset: 700, limit: 100, want: seq(700, 800)},
+				{offset: 800, limit: 100, want: seq(800, 900), wantCursor: "999"},
+			},
+			wantTotal: 2500,
+		},
+		{
+			name:     "last page",
+			entries:  entries,
+			maxBlock: 3000,
+			pages: []page{
+				{offset: 2400, limit: 100, want: seq(2400, 2500)},
+				{offset: 2500, limit: 100},
+			},
+			wantTotal: 2500,
+		},
+		{
+			name:      "max block",
+			entries:   entries,
+			maxBlock:  2990,
+			pages:     []page{{offset: 0, limit: 2, want: []int{10, 11}}},
+			wantTotal: 2490,
+		},
+		{
+			name:        "coin",
+			entries:     coinEntries,
+			txs:         coinTxs,
+			coin:        coin,
+			maxBlock:    3000,
+			pages:       []page{{offset: 0, limit: 10, want: []int{2, 5}}},
+			wantTotal:   2,
+			wantBatches: 1,
+		},
+		{
+			name:      "transaction",
+			entries:   coinEntries,
+			txHash:    &txHash,
+			maxBlock:  3000,
+			pages:     []page{{offset: 0, limit: 10, want: []int{3}}},
+			wantTotal: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &indexerClient{entries: tt.entries, txs: tt.txs}
+			s := &SearchAPIService{client: client, cursors: newLRUCache(searchCursorsSize)}
+			for _, p := range tt.pages {
+				client.cursors = nil
+				matches, total, rErr := s.searchLockPage(context.Background(), lock, tt.coin, tt.txHash, tt.maxBlock, p.offset, p.limit)
+				if rErr != nil {
+					t.Fatal(rErr)
+				}
+				if len(matches) != len(p.want) {
+					t.Fatalf("page at %d has %d matches, want %d", p.offset, len(matches), len(p.want))
+				}
+				for i, match := range matches {
+					if match.txHash != testTxHash(p.want[i]) {
+						t.Fatalf("page at %d match %d = %s, want transaction %d", p.offset, i, match.txHash.String(), p.want[i])
+					}
+				}
+				if total != tt.wantTotal {
+					t.Errorf("page at %d total = %d, want %d", p.offset, total, tt.wantTotal)
+				}
+				if client.cursors[0] != p.wantCursor {
+					t.Errorf("page at %d starts from cursor %q, want %q", p.offset, client.cursors[0], p.wantCursor)
+				}
+
+				// a page without a recorded position is found by skipping the matches before it
+				fresh := &SearchAPIService{client: client, cursors: newLRUCache(searchCursorsSize)}
+				freshMatches, freshTotal, rErr := fresh.searchLockPage(context.Background(), lock, tt.coin, tt.txHash, tt.maxBlock, p.offset, p.limit)
+				if rErr != nil {
+					t.Fatal(rErr)
+				}
+				if freshTotal != total {
+					t.Errorf("page at %d total = %d without a cursor, want %d", p.offset, freshTotal, total)
+				}
+				if len(freshMatches) != len(matches) {
+					t.Fatalf("page at %d has %d matches without a cursor, want %d", p.offset, len(freshMatches), len(matches))
+				}
+				for i := range matches {
+					if freshMatches[i].txHash != matches[i].txHash {
+						t.Fatalf("page at %d match %d = %s without a cursor, want %s", p.offset, i, freshMatches[i].txHash.String(), matches[i].txHash.String())
+					}
+				}
+			}
+			if client.batches != tt.wantBatches*2 {
+				t.Errorf("batches = %d, want %d", client.batches, tt.wantBatches)
+			}
+		})
+	}
+}
+
+// seq returns the numbers from start to end exclusive
+func seq(start int, end int) []int {
+	var numbers []int
+	for i := start; i < end; i++ {
+		numbers = append(numbers, i)
+	}
+	return numbers
+}
@@ -2,11 +2,16 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 	"github.com/nervosnetwork/ckb-sdk-go/address"
+	"github.com/nervosnetwork/ckb-sdk-go/crypto/blake2b"
 	ckbRpc "github.com/nervosnetwork/ckb-sdk-go/rpc"
 	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
 )
@@ -31,10 +36,15 @@ func isBlake160MultisigAllLock(script *ckbTypes.Script, cfg *config.Config) bool
 		script.CodeHash.String() == cfg.Secp256k1Blake160Mutisig.Script.CodeHash
 }
 
+func isAnyoneCanPayLock(script *ckbTypes.Script, cfg *config.Config) bool {
+	return builder.IsAnyoneCanPayLock(script, cfg)
+}
+
 func wrapErr(rErr *types.Error, err error) *types.Error {
 	newErr := &types.Error{
-		Code:    rErr.Code,
-		Message: rErr.Message,
+		Code:      rErr.Code,
+		Message:   rErr.Message,
+		Retriable: rErr.Retriable,
 	}
 	if err != nil {
 		newErr.Details = map[string]interface{}{
@@ -53,28 +63,60 @@ func separateInputAndOutput(operations []*types.Operation) (inputOperations []*t
 }
 
 func getInputOperations(operations []*types.Operation) []*types.Operation {
-	return operationFilter(operations, func(operation *types.Operation) bool {
-		return operation.Type == ckb.InputOpType
-	})
+	return operationFilter(operations, builder.IsInputOperation)
 }
 
 func getOutputOperations(operations []*types.Operation) []*types.Operation {
-	return operationFilter(operations, func(operation *types.Operation) bool {
-		return operation.Type == ckb.OutputOpType
-	})
+	return operationFilter(operations, builder.IsOutputOperation)
 }
 
 func getConstructionType(operations []*types.Operation, signatures []*types.Signature, cfg *config.Config) (string, *types.Error) {
 	inputOperations := getInputOperations(operations)
 	outputOperations := getOutputOperations(operations)
+	if daoType, err := getDAOConstructionType(inputOperations, outputOperations, signatures, cfg); daoType != "" || err != nil {
+		return daoType, err
+	}
+	if ok, err := isTransferUDT(inputOperations, outputOperations, signatures, cfg); ok {
+		if err != nil {
+			return "", err
+		}
+		return ckb.TransferUDT, nil
+	} else if err != nil {
+		return "", err
+	}
+	if ok, err := isGenericCell(inputOperations, outputOperations, signatures, cfg); ok {
+		if err != nil {
+			return "", err
+		}
+		return ckb.GenericCell, nil
+	} else if err != nil {
+		return "", err
+	}
 	if ok, err := isTransferCKB(inputOperations, outputOperations, signatures, cfg); ok {
 		if err != nil {
 			return "", err
 		}
 		return ckb.TransferCKB, nil
-	} else {
-		return "", UnsupportedConstructionTypeError
+	} else if err != nil {
+		return "", err
+	}
+	if ok, err := isTransferCKBFromMultisig(inputOperations, outputOperations, signatures, cfg); ok {
+		if err != nil {
+			return "", err
+		}
+		return ckb.TransferCKBFromMultisig, nil
+	} else if err != nil {
+		return "", err
 	}
+	if ok, err := isTopUpACP(inputOperations, outputOperations, signatures, cfg); ok {
+		if err != nil {
+			return "", err
+		}
+		return ckb.TopUpACP, nil
+	} else if err != nil {
+		return "", err
+	}
+	return "", UnsupportedConstructionTypeError
 }
 
 func isTransferCKB(inputOperations []*types.Operation, outputOperations []*types.Operation, signatures []*types.Signature, cfg *config.Config) (bool, *types.Error) {
@@ -89,13 +131,17 @@ func isTransferCKB(inputOperations []*types.Operation, outputOperations []*types
 			}
 		}
 
+		if hasUDTOperation(inputOperations) || hasUDTOperation(outputOperations) {
+			return false, nil
+		}
+
 		for _, operation := range outputOperations {
 			var metadata ckb.OperationMetadata
 			err := types.UnmarshalMap(operation.Metadata, &metadata)
 			if err != nil {
 				return false, wrapErr(DataParseError, err)
 			}
-			if metadata.Type != nil {
+			if isGenericCellOutput(&metadata) {
 				return false, nil
 			}
 		}
@@ -115,6 +161,209 @@ func isTransferCKB(inputOperations []*types.Operation, outputOperations []*types
 	return true, nil
 }
 
+func isTransferCKBFromMultisig(inputOperations []*types.Operation, outputOperations []*types.Operation, signatures []*types.Signature, cfg *config.Config) (bool, *types.Error) {
+	if signatures == nil {
+		for _, operation := range inputOperations {
+			parsedAddress, err := address.Parse(operation.Account.Address)
+			if err != nil {
+				return false, AddressParseError
+			}
+			if !isBlake160MultisigAllLock(parsedAddress.Script, cfg) {
+				return false, nil
+			}
+		}
+
+		if hasUDTOperation(inputOperations) || hasUDTOperation(outputOperations) {
+			return false, nil
+		}
+
+		for _, operation := range outputOperations {
+			var metadata ckb.OperationMetadata
+			err := types.UnmarshalMap(operation.Metadata, &metadata)
+			if err != nil {
+				return false, wrapErr(DataParseError, err)
+			}
+			if isGenericCellOutput(&metadata) {
+				return false, nil
+			}
+		}
+	} else {
+		var metadata ckb.AccountIdentifierMetadata
+		for _, signature := range signatures {
+			err := types.UnmarshalMap(signature.SigningPayload.AccountIdentifier.Metadata, &metadata)
+			if err != nil {
+				return false, wrapErr(InvalidAccountIdentifierMetadataError, err)
+			}
+			if metadata.LockType != ckb.Secp256k1Blake160Multisig.String() {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// isTransferUDT only applies to operations, signed sUDT transfers are combined as sighash transfers
+func isTransferUDT(inputOperations []*types.Operation, outputOperations []*types.Operation, signatures []*types.Signature, cfg *config.Config) (bool, *types.Error) {
+	if signatures != nil {
+		return false, nil
+	}
+	if !hasUDTOperation(inputOperations) && !hasUDTOperation(outputOperations) {
+		return false, nil
+	}
+	for _, operation := range inputOperations {
+		parsedAddress, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return false, AddressParseError
+		}
+		if !isBlake160SighashAllLock(parsedAddress.Script, cfg) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// isTopUpACP only applies to operations, the anyone-can-pay inputs need no signature so signed top-ups are
+// combined as sighash transfers
+func isTopUpACP(inputOperations []*types.Operation, outputOperations []*types.Operation, signatures []*types.Signature, cfg *config.Config) (bool, *types.Error) {
+	if signatures != nil {
+		return false, nil
+	}
+	if hasUDTOperation(inputOperations) || hasUDTOperation(outputOperations) {
+		return false, nil
+	}
+	hasACPInput := false
+	for _, operation := range inputOperations {
+		parsedAddress, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return false, AddressParseError
+		}
+		if isAnyoneCanPayLock(parsedAddress.Script, cfg) {
+			hasACPInput = true
+		} else if !isBlake160SighashAllLock(parsedAddress.Script, cfg) {
+			return false, nil
+		}
+	}
+	if !hasACPInput {
+		return false, nil
+	}
+
+	for _, operation := range outputOperations {
+		var metadata ckb.OperationMetadata
+		err := types.UnmarshalMap(operation.Metadata, &metadata)
+		if err != nil {
+			return false, wrapErr(DataParseError, err)
+		}
+		if isGenericCellOutput(&metadata) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// getDAOConstructionType only applies to operations, the DAO cells are locked by secp256k1_blake160_sighash_all
+// so signed DAO transactions are combined as sighash transfers. An operation spends or creates a DAO cell when
+// it is a DAO_WITHDRAW or DAO_DEPOSIT or its metadata declares the cell type.
+func getDAOConstructionType(inputOperations []*types.Operation, outputOperations []*types.Operation, signatures []*types.Signature, cfg *config.Config) (string, *types.Error) {
+	if signatures != nil {
+		return "", nil
+	}
+	inputCellTypes, outputCellTypes := make(map[string]bool), make(map[string]bool)
+	for _, operation := range inputOperations {
+		cellType, err := builder.GetOperationCellType(operation)
+		if err != nil {
+			return "", wrapErr(DataParseError, err)
+		}
+		inputCellTypes[cellType] = true
+	}
+	for _, operation := range outputOperations {
+		cellType, err := builder.GetOperationCellType(operation)
+		if err != nil {
+			return "", wrapErr(DataParseError, err)
+		}
+		outputCellTypes[cellType] = true
+	}
+
+	var constructionType string
+	switch {
+	case inputCellTypes[ckb.DAODepositCell.String()] && inputCellTypes[ckb.DAOWithdrawingCell.String()]:
+		return "", wrapErr(UnsupportedConstructionTypeError, errors.New("deposit and withdrawing DAO cells cannot be spent together"))
+	case inputCellTypes[ckb.DAOWithdrawingCell.String()]:
+		constructionType = ckb.UnlockDAO
+	case inputCellTypes[ckb.DAODepositCell.String()]:
+		constructionType = ckb.WithdrawDAO
+	case outputCellTypes[ckb.DAODepositCell.String()]:
+		constructionType = ckb.DepositDAO
+	default:
+		return "", nil
+	}
+	if hasUDTOperation(inputOperations) || hasUDTOperation(outputOperations) {
+		return "", wrapErr(UnsupportedConstructionTypeError, errors.New("DAO transactions cannot transfer sUDT"))
+	}
+	for _, operation := range inputOperations {
+		parsedAddress, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return "", AddressParseError
+		}
+		if !isBlake160SighashAllLock(parsedAddress.Script, cfg) {
+			return "", wrapErr(UnsupportedConstructionTypeError, errors.New("DAO transactions only spend secp256k1_blake160_sighash_all cells"))
+		}
+	}
+
+	return constructionType, nil
+}
+
+// isGenericCell only applies to operations, the inputs are secp256k1_blake160_sighash_all cells so signed
+// transactions are combined as sighash transfers
+func isGenericCell(inputOperations []*types.Operation, outputOperations []*types.Operation, signatures []*types.Signature, cfg *config.Config) (bool, *types.Error) {
+	if signatures != nil {
+		return false, nil
+	}
+	if hasUDTOperation(inputOperations) || hasUDTOperation(outputOperations) {
+		return false, nil
+	}
+	hasGenericCellOutput := false
+	for _, operation := range outputOperations {
+		var metadata ckb.OperationMetadata
+		if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
+			return false, wrapErr(DataParseError, err)
+		}
+		if isGenericCellOutput(&metadata) {
+			hasGenericCellOutput = true
+		}
+	}
+	if !hasGenericCellOutput {
+		return false, nil
+	}
+	for _, operation := range inputOperations {
+		parsedAddress, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return false, AddressParseError
+		}
+		if !isBlake160SighashAllLock(parsedAddress.Script, cfg) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// isGenericCellOutput reports whether the OUTPUT operation declares a type script or data
+func isGenericCellOutput(metadata *ckb.OperationMetadata) bool {
+	return metadata.Type != nil || (metadata.Data != "" && metadata.Data != "0x")
+}
+
+func hasUDTOperation(operations []*types.Operation) bool {
+	for _, operation := range operations {
+		if builder.IsUDTOperation(operation) {
+			return true
+		}
+	}
+	return false
+}
+
 func toRosettaTransaction(rTx inRosettaTransaction) *rosettaTransaction {
 	return &rosettaTransaction{
 		Version:                  uint(rTx.Version),
@@ -248,7 +497,131 @@ func getLockType(script *ckbTypes.Script, cfg *config.Config) string {
 		return ckb.Secp256k1Blake160Lock.String()
 	} else if isBlake160MultisigAllLock(script, cfg) {
 		return ckb.Secp256k1Blake160Multisig.String()
+	} else if isAnyoneCanPayLock(script, cfg) {
+		return ckb.AnyoneCanPay.String()
 	} else {
 		return ckb.UnknownLock.String()
 	}
 }
+
+func isUDTCell(output *ckbTypes.CellOutput, cfg *config.Config) bool {
+	return output.Type != nil &&
+		output.Type.CodeHash.String() == cfg.UDT.Script.CodeHash &&
+		string(output.Type.HashType) == cfg.UDT.Script.HashType
+}
+
+func isDAOCell(output *ckbTypes.CellOutput, cfg *config.Config) bool {
+	return output.Type != nil &&
+		output.Type.CodeHash.String() == cfg.DAO.Script.CodeHash &&
+		string(output.Type.HashType) == cfg.DAO.Script.HashType
+}
+
+// getCellType classifies the cell, the 8 bytes data of a DAO cell is zero for a deposit and the deposit
+// block number for a withdrawing cell
+func getCellType(output *ckbTypes.CellOutput, data []byte, cfg *config.Config) ckb.CellType {
+	switch {
+	case output.Type == nil:
+		return ckb.PlainCell
+	case isUDTCell(output, cfg):
+		return ckb.SUDTCell
+	case isDAOCell(output, cfg) && len(data) == 8:
+		for _, b := range data {
+			if b != 0 {
+				return ckb.DAOWithdrawingCell
+			}
+		}
+		return ckb.DAODepositCell
+	default:
+		return ckb.UnknownTypeCell
+	}
+}
+
+// getCellOperationMetadata describes the type script and data of the cell an INPUT or OUTPUT operation
+// spends or creates
+func getCellOperationMetadata(output *ckbTypes.CellOutput, data []byte, cfg *config.Config) (map[string]interface{}, *types.Error) {
+	metadata, rErr := cellOperationMetadata(output, data, cfg)
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	result, err := types.MarshalMap(metadata)
+	if err != nil {
+		return nil, wrapErr(InvalidOperationMetadataError, err)
+	}
+	return result, nil
+}
+
+// getInputOperationMetadata adds the since of the input to the metadata of its previous output, which is nil
+// when the cell is unknown. It returns nil when there is nothing to describe.
+func getInputOperationMetadata(previousOutput *ResolvedOutput, since uint64, cfg *config.Config) (map[string]interface{}, *types.Error) {
+	metadata := &ckb.OperationMetadata{}
+	if previousOutput != nil {
+		var rErr *types.Error
+		metadata, rErr = cellOperationMetadata(previousOutput.Output, previousOutput.Data, cfg)
+		if rErr != nil {
+			return nil, rErr
+		}
+	} else if since == 0 {
+		return nil, nil
+	}
+	if since != 0 {
+		rawSince := hexutil.Uint64(since)
+		metadata.Since = &rawSince
+		// a since with undefined flags is only shown raw
+		metadata.TimeLock, _ = ckb.ParseSince(since)
+	}
+
+	result, err := types.MarshalMap(metadata)
+	if err != nil {
+		return nil, wrapErr(InvalidOperationMetadataError, err)
+	}
+	return result, nil
+}
+
+func cellOperationMetadata(output *ckbTypes.CellOutput, data []byte, cfg *config.Config) (*ckb.OperationMetadata, *types.Error) {
+	metadata := &ckb.OperationMetadata{
+		Type:       output.Type,
+		DataLength: len(data),
+		CellType:   getCellType(output, data, cfg).String(),
+	}
+	if isUDTCell(output, cfg) {
+		_, err := builder.ParseUDTAmountData(data)
+		metadata.InvalidUDTData = err != nil
+	}
+	if len(data) <= ckb.MaxOperationDataSize {
+		metadata.Data = hexutil.Encode(data)
+	}
+	if len(data) > 0 {
+		dataHash, err := blake2b.Blake256(data)
+		if err != nil {
+			return nil, wrapErr(ComputeHashError, err)
+		}
+		metadata.DataHash = hexutil.Encode(dataHash)
+	}
+	return metadata, nil
+}
+
+// getUDTCurrency returns the currency of the token issued by the sUDT type script,
+// tokens missing in config are identified by the type script hash
+func getUDTCurrency(typeScript *ckbTypes.Script, cfg *config.Config) (*types.Currency, error) {
+	args := hexutil.Encode(typeScript.Args)
+	for typeArgs, token := range cfg.UDT.Tokens {
+		if strings.EqualFold(typeArgs, args) {
+			return &types.Currency{
+				Symbol:   token.Symbol,
+				Decimals: int32(token.Decimal),
+			}, nil
+		}
+	}
+	typeHash, err := typeScript.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return &types.Currency{
+		Symbol:   typeHash.String(),
+		Decimals: 0,
+		Metadata: map[string]interface{}{
+			"type_hash": typeHash.String(),
+		},
+	}, nil
+}
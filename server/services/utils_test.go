@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-sdk-go/crypto/blake2b"
+	ckbTransaction "github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+)
+
+func TestGetCellType(t *testing.T) {
+	cfg := testConfig(t)
+	udtType := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.UDT.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.UDT.Script.HashType),
+		Args:     []byte{1},
+	}
+	daoType := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.DAO.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.DAO.Script.HashType),
+		Args:     []byte{},
+	}
+	// the DAO code hash with another hash type is not the DAO
+	otherType := &ckbTypes.Script{CodeHash: daoType.CodeHash, HashType: ckbTypes.HashTypeData, Args: []byte{}}
+
+	tests := []struct {
+		name       string
+		typeScript *ckbTypes.Script
+		data       []byte
+		want       ckb.CellType
+	}{
+		{name: "plain", want: ckb.PlainCell},
+		{name: "plain with data", data: []byte{1, 2, 3}, want: ckb.PlainCell},
+		{name: "sUDT", typeScript: udtType, data: make([]byte, 16), want: ckb.SUDTCell},
+		{name: "sUDT without an amount", typeScript: udtType, want: ckb.SUDTCell},
+		{name: "DAO deposit", typeScript: daoType, data: make([]byte, 8), want: ckb.DAODepositCell},
+		{name: "DAO withdrawing", typeScript: daoType, data: []byte{0x10, 0x27, 0, 0, 0, 0, 0, 0}, want: ckb.DAOWithdrawingCell},
+		{name: "DAO withdrawing at a high block", typeScript: daoType, data: []byte{0, 0, 0, 0, 0, 0, 0, 1}, want: ckb.DAOWithdrawingCell},
+		{name: "DAO with short data", typeScript: daoType, data: make([]byte, 7), want: ckb.UnknownTypeCell},
+		{name: "DAO with long data", typeScript: daoType, data: make([]byte, 9), want: ckb.UnknownTypeCell},
+		{name: "unknown type", typeScript: otherType, data: make([]byte, 8), want: ckb.UnknownTypeCell},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &ckbTypes.CellOutput{Capacity: 20000000000, Type: tt.typeScript}
+			if got := getCellType(output, tt.data, cfg); got != tt.want {
+				t.Errorf("getCellType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCellOperationMetadata(t *testing.T) {
+	cfg := testConfig(t)
+	udtType := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.UDT.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.UDT.Script.HashType),
+		Args:     []byte{1},
+	}
+	daoType := &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(cfg.DAO.Script.CodeHash),
+		HashType: ckbTypes.ScriptHashType(cfg.DAO.Script.HashType),
+		Args:     []byte{},
+	}
+	dataHash := func(data []byte) string {
+		hash, err := blake2b.Blake256(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hexutil.Encode(hash)
+	}
+	maxData := bytes.Repeat([]byte{0xab}, ckb.MaxOperationDataSize)
+	largeData := bytes.Repeat([]byte{0xab}, ckb.MaxOperationDataSize+1)
+
+	tests := []struct {
+		name       string
+		typeScript *ckbTypes.Script
+		data       []byte
+		want       *ckb.OperationMetadata
+	}{
+		{
+			name: "plain without data",
+			want: &ckb.OperationMetadata{Data: "0x", CellType: "PlainCell"},
+		},
+		{
+			name: "plain with data",
+			data: []byte{1, 2, 3},
+			want: &ckb.OperationMetadata{Data: "0x010203", DataHash: dataHash([]byte{1, 2, 3}), DataLength: 3, CellType: "PlainCell"},
+		},
+		{
+			name:       "sUDT",
+			typeScript: udtType,
+			data:       make([]byte, 16),
+			want: &ckb.OperationMetadata{
+				Data:       hexutil.Encode(make([]byte, 16)),
+				Type:       udtType,
+				DataHash:   dataHash(make([]byte, 16)),
+				DataLength: 16,
+				CellType:   "SUDTCell",
+			},
+		},
+		{
+			name:       "sUDT without an amount",
+			typeScript: udtType,
+			data:       []byte{1},
+			want: &ckb.OperationMetadata{
+				Data:           "0x01",
+				Type:           udtType,
+				DataHash:       dataHash([]byte{1}),
+				DataLength:     1,
+				CellType:       "SUDTCell",
+				InvalidUDTData: true,
+			},
+		},
+		{
+			name:       "DAO deposit",
+			typeScript: daoType,
+			data:       make([]byte, 8),
+			want: &ckb.OperationMetadata{
+				Data:       "0x0000000000000000",
+				Type:       daoType,
+				DataHash:   dataHash(make([]byte, 8)),
+				DataLength: 8,
+				CellType:   "DAODepositCell",
+			},
+		},
+		{
+			name: "data of the maximum size",
+			data: maxData,
+			want: &ckb.OperationMetadata{
+				Data:       hexutil.Encode(maxData),
+				DataHash:   dataHash(maxData),
+				DataLength: ckb.MaxOperationDataSize,
+				CellType:   "PlainCell",
+			},
+		},
+		{
+			name: "data above the maximum size",
+			data: largeData,
+			want: &ckb.OperationMetadata{
+				DataHash:   dataHash(largeData),
+				DataLength: ckb.MaxOperationDataSize + 1,
+				CellType:   "PlainCell",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := &ckbTypes.CellOutput{Capacity: 20000000000, Type: tt.typeScript}
+			got, rErr := cellOperationMetadata(output, tt.data, cfg)
+			if rErr != nil {
+				t.Fatal(rErr)
+			}
+			assertOperationMetadata(t, got, tt.want)
+
+			// the map form carries the same fields, data_hash is omitted for empty data
+			metadata, rErr := getCellOperationMetadata(output, tt.data, cfg)
+			if rErr != nil {
+				t.Fatal(rErr)
+			}
+			if hash, ok := metadata["data_hash"]; ok != (tt.want.DataHash != "") || (ok && hash != tt.want.DataHash) {
+				t.Errorf("data_hash = %v, want %q", hash, tt.want.DataHash)
+			}
+		})
+	}
+}
+
+func assertOperationMetadata(t *testing.T, got *ckb.OperationMetadata, want *ckb.OperationMetadata) {
+	t.Helper()
+	if got.Data != want.Data {
+		t.Errorf("Data = %.20s, want %.20s", got.Data, want.Data)
+	}
+	if (got.Type == nil) != (want.Type == nil) || (got.Type != nil && !got.Type.Equals(want.Type)) {
+		t.Errorf("Type = %v, want %v", got.Type, want.Type)
+	}
+	if got.DataHash != want.DataHash {
+		t.Errorf("DataHash = %s, want %s", got.DataHash, want.DataHash)
+	}
+	if got.DataLength != want.DataLength {
+		t.Errorf("DataLength = %d, want %d", got.DataLength, want.DataLength)
+	}
+	if got.CellType != want.CellType {
+		t.Errorf("CellType = %s, want %s", got.CellType, want.CellType)
+	}
+	if got.InvalidUDTData != want.InvalidUDTData {
+		t.Errorf("InvalidUDTData = %v, want %v", got.InvalidUDTData, want.InvalidUDTData)
+	}
+}
+
+func TestGetDAOConstructionType(t *testing.T) {
+	cfg := testConfig(t)
+	sighash := testAddress(t, &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(ckbTransaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{1}, 20),
+	})
+	operation := func(operationType string, cellType ckb.CellType) *types.Operation {
+		operation := &types.Operation{
+			Type:    operationType,
+			Account: &types.AccountIdentifier{Address: sighash},
+			Amount:  &types.Amount{Value: "10000000000", Currency: CkbCurrency},
+		}
+		if cellType != ckb.PlainCell {
+			operation.Metadata = testOperationMetadata(t, &ckb.OperationMetadata{CellType: cellType.String()})
+		}
+		return operation
+	}
+
+	tests := []struct {
+		name       string
+		operations []*types.Operation
+		want       string
+		wantErr    *types.Error
+	}{
+		{
+			name:       "transfer",
+			operations: []*types.Operation{operation(ckb.InputOpType, ckb.PlainCell), operation(ckb.OutputOpType, ckb.PlainCell)},
+		},
+		{
+			name:       "DAO_DEPOSIT",
+			operations: []*types.Operation{operation(ckb.InputOpType, ckb.PlainCell), operation(ckb.DAODepositOpType, ckb.PlainCell)},
+			want:       ckb.DepositDAO,
+		},
+		{
+			name:       "OUTPUT of a deposit cell",
+			operations: []*types.Operation{operation(ckb.InputOpType, ckb.PlainCell), operation(ckb.OutputOpType, ckb.DAODepositCell)},
+			want:       ckb.DepositDAO,
+		},
+		{
+			name:       "withdraw",
+			operations: []*types.Operation{operation(ckb.InputOpType, ckb.DAODepositCell), operation(ckb.OutputOpType, ckb.DAOWithdrawingCell)},
+			want:       ckb.WithdrawDAO,
+		},
+		{
+			name:       "DAO_WITHDRAW",
+			operations: []*types.Operation{operation(ckb.DAOWithdrawOpType, ckb.PlainCell), operation(ckb.OutputOpType, ckb.PlainCell)},
+			want:       ckb.UnlockDAO,
+		},
+		{
+			name:       "INPUT of a withdrawing cell",
+			operations: []*types.Operation{operation(ckb.InputOpType, ckb.DAOWithdrawingCell), operation(ckb.OutputOpType, ckb.PlainCell)},
+			want:       ckb.UnlockDAO,
+		},
+		{
+			name:       "DAO_WITHDRAW with a deposit cell",
+			operations: []*types.Operation{operation(ckb.DAOWithdrawOpType, ckb.PlainCell), operation(ckb.InputOpType, ckb.DAODepositCell), operation(ckb.OutputOpType, ckb.PlainCell)},
+			wantErr:    UnsupportedConstructionTypeError,
+		},
+		{
+			name:       "DAO_WITHDRAW declaring a deposit cell",
+			operations: []*types.Operation{operation(ckb.DAOWithdrawOpType, ckb.DAODepositCell), operation(ckb.OutputOpType, ckb.PlainCell)},
+			wantErr:    DataParseError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputOperations, outputOperations := separateInputAndOutput(tt.operations)
+			got, rErr := getDAOConstructionType(inputOperations, outputOperations, nil, cfg)
+			assertError(t, rErr, tt.wantErr)
+			if got != tt.want {
+				t.Errorf("getDAOConstructionType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
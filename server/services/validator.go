@@ -1,11 +1,18 @@
 package services
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/builder"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
 	"github.com/nervosnetwork/ckb-rosetta-sdk/server/config"
 	"github.com/nervosnetwork/ckb-sdk-go/address"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
 	"strconv"
 )
 
@@ -18,9 +25,7 @@ func validateCapacity(inputTotalAmount uint64, outputTotalAmount uint64) *types.
 
 func validateOutputOperations(operations []*types.Operation, cfg *config.Config) (uint64, *types.Error) {
 	var outputTotalAmount uint64
-	outputOperations := operationFilter(operations, func(operation *types.Operation) bool {
-		return operation.Type == ckb.OutputOpType
-	})
+	outputOperations := getOutputOperations(operations)
 	if len(outputOperations) == 0 {
 		return 0, MissingOutputOperationsError
 	}
@@ -30,44 +35,84 @@ func validateOutputOperations(operations []*types.Operation, cfg *config.Config)
 		if operation.Amount.Value[0:1] == "-" {
 			return 0, InvalidOutputOperationAmountValueError
 		}
-		amount, err := strconv.ParseUint(operation.Amount.Value, 10, 64)
-		if err != nil {
-			return 0, InvalidOutputOperationAmountValueError
-		}
 		addr, err := address.Parse(operation.Account.Address)
 		if err != nil {
 			return 0, AddressParseError
 		}
-		if isBlake160SighashAllLock(addr.Script, cfg) {
-			if i == operationSize-1 && amount == 0 {
-				continue
+		var amount uint64
+		if builder.IsUDTOperation(operation) {
+			capacity, validateErr := validateUDTOperation(operation, cfg)
+			if validateErr != nil {
+				return 0, validateErr
 			}
-			if amount < MinCapacity {
-				return 0, LessThanMinCapacityError
+			amount = capacity
+		} else {
+			amount, err = strconv.ParseUint(operation.Amount.Value, 10, 64)
+			if err != nil {
+				return 0, InvalidOutputOperationAmountValueError
+			}
+			if isBlake160SighashAllLock(addr.Script, cfg) && i == operationSize-1 && amount == 0 {
+				continue
 			}
 		}
+		if validateErr := validateOccupiedCapacity(i, operation, amount, cfg); validateErr != nil {
+			return 0, validateErr
+		}
 
 		outputTotalAmount += amount
 	}
 	return outputTotalAmount, nil
 }
 
+// validateOccupiedCapacity checks the output holds the capacity its lock, type script and data occupy, the required
+// minimum is reported in the error details
+func validateOccupiedCapacity(index int, operation *types.Operation, capacity uint64, cfg *config.Config) *types.Error {
+	typeScript, _, err := builder.GetOperationCellOutput(operation)
+	if err != nil {
+		return wrapErr(InvalidOperationMetadataError, err)
+	}
+	if typeScript != nil && typeScript.HashType != ckbTypes.HashTypeData && typeScript.HashType != ckbTypes.HashTypeType {
+		return wrapErr(InvalidOperationMetadataError, fmt.Errorf("invalid hash type %s of output %d", typeScript.HashType, index))
+	}
+	occupiedCapacity, err := builder.GetOperationOccupiedCapacity(cfg, operation)
+	if err != nil {
+		return wrapErr(InvalidOperationMetadataError, err)
+	}
+	if capacity < occupiedCapacity {
+		rErr := wrapErr(LessThanMinCapacityError, fmt.Errorf("output %d capacity %d is less than its occupied capacity %d", index, capacity, occupiedCapacity))
+		rErr.Details["minimum_capacity"] = strconv.FormatUint(occupiedCapacity, 10)
+		return rErr
+	}
+	return nil
+}
+
 func validateInputOperations(operations []*types.Operation, cfg *config.Config) (uint64, *types.Error) {
 	var inputTotalAmount uint64
-	inputOperations := operationFilter(operations, func(operation *types.Operation) bool {
-		return operation.Type == ckb.InputOpType
-	})
+	inputOperations := getInputOperations(operations)
 
 	if len(inputOperations) == 0 {
 		return 0, MissingInputOperationsError
 	}
 
 	for _, operation := range inputOperations {
-		amount, err := strconv.ParseUint(operation.Amount.Value[1:], 10, 64)
-		if err != nil || operation.Amount.Value[0:1] != "-" {
+		if operation.Amount.Value[0:1] != "-" {
 			return 0, InvalidInputOperationAmountValueError
 		}
-		err = asserter.CoinChange(operation.CoinChange)
+		var amount uint64
+		if builder.IsUDTOperation(operation) {
+			capacity, validateErr := validateUDTOperation(operation, cfg)
+			if validateErr != nil {
+				return 0, validateErr
+			}
+			amount = capacity
+		} else {
+			var err error
+			amount, err = strconv.ParseUint(operation.Amount.Value[1:], 10, 64)
+			if err != nil {
+				return 0, InvalidInputOperationAmountValueError
+			}
+		}
+		err := asserter.CoinChange(operation.CoinChange)
 		if err != nil {
 			return 0, InvalidCoinChangeError
 		}
@@ -75,9 +120,13 @@ func validateInputOperations(operations []*types.Operation, cfg *config.Config)
 		if err != nil {
 			return 0, AddressParseError
 		}
-		// do not support send to multisig all lock
 		if isBlake160MultisigAllLock(addr.Script, cfg) {
-			return 0, NotSupportMultisigAllLockError
+			if validateErr := validateMultisigScript(operation, addr.Script); validateErr != nil {
+				return 0, validateErr
+			}
+		}
+		if _, err := builder.GetOperationSince(operation); err != nil {
+			return 0, wrapErr(InvalidSinceError, err)
 		}
 
 		inputTotalAmount += amount
@@ -85,12 +134,139 @@ func validateInputOperations(operations []*types.Operation, cfg *config.Config)
 	return inputTotalAmount, nil
 }
 
-func validateConstructionType(metadata map[string]interface{}) (string, *types.Error) {
-	var constructionMetadata ckb.ConstructionMetadata
-	err := types.UnmarshalMap(metadata, &constructionMetadata)
+// validateUDTOperation checks the token is configured and returns the cell capacity declared in operation metadata
+func validateUDTOperation(operation *types.Operation, cfg *config.Config) (uint64, *types.Error) {
+	if _, err := builder.GetUDTTypeScript(cfg, operation.Amount.Currency); err != nil {
+		return 0, wrapErr(UnsupportedCurrencyError, err)
+	}
+	if _, err := builder.ParseUDTAmount(operation.Amount.Value); err != nil {
+		if builder.IsInputOperation(operation) {
+			return 0, wrapErr(InvalidInputOperationAmountValueError, err)
+		}
+		return 0, wrapErr(InvalidOutputOperationAmountValueError, err)
+	}
+	capacity, err := builder.GetOperationCapacity(operation)
+	if err != nil {
+		return 0, wrapErr(InvalidOperationMetadataError, err)
+	}
+	return capacity, nil
+}
+
+// validateUDTAmounts checks the input and output amounts of every sUDT token are equal,
+// tokens are told apart by the hash of their type script
+func validateUDTAmounts(operations []*types.Operation, cfg *config.Config) *types.Error {
+	balances := make(map[ckbTypes.Hash]*big.Int)
+	for _, operation := range operations {
+		if !builder.IsUDTOperation(operation) {
+			continue
+		}
+		amount, err := builder.ParseUDTAmount(operation.Amount.Value)
+		if err != nil {
+			return wrapErr(DataParseError, err)
+		}
+		typeScript, err := builder.GetUDTTypeScript(cfg, operation.Amount.Currency)
+		if err != nil {
+			return wrapErr(UnsupportedCurrencyError, err)
+		}
+		typeHash, err := typeScript.Hash()
+		if err != nil {
+			return wrapErr(ComputeHashError, err)
+		}
+		if _, ok := balances[typeHash]; !ok {
+			balances[typeHash] = new(big.Int)
+		}
+		switch {
+		case builder.IsInputOperation(operation):
+			balances[typeHash].Add(balances[typeHash], amount)
+		case builder.IsOutputOperation(operation):
+			balances[typeHash].Sub(balances[typeHash], amount)
+		}
+	}
+	for typeHash, balance := range balances {
+		if balance.Sign() != 0 {
+			return wrapErr(UDTAmountNotBalancedError, fmt.Errorf("inputs and outputs of token %s differ by %s", typeHash.String(), balance.String()))
+		}
+	}
+	return nil
+}
+
+// validateACPTopUp checks every anyone-can-pay input is recreated by an output of the same lock script with more
+// capacity, which is what the lock accepts without the owner's signature. The lock rejects several inputs or outputs
+// of the same lock, so each lock of an input has exactly one input and one output. Locks are compared by script hash
+// since the same lock is encoded by several addresses.
+func validateACPTopUp(operations []*types.Operation, cfg *config.Config) *types.Error {
+	inputCapacities := make(map[ckbTypes.Hash][]uint64)
+	outputCapacities := make(map[ckbTypes.Hash][]uint64)
+	for _, operation := range operations {
+		addr, err := address.Parse(operation.Account.Address)
+		if err != nil {
+			return AddressParseError
+		}
+		if !isAnyoneCanPayLock(addr.Script, cfg) {
+			continue
+		}
+		lockHash, err := addr.Script.Hash()
+		if err != nil {
+			return wrapErr(ComputeHashError, err)
+		}
+		switch {
+		case builder.IsInputOperation(operation):
+			amount, err := strconv.ParseUint(operation.Amount.Value[1:], 10, 64)
+			if err != nil {
+				return InvalidInputOperationAmountValueError
+			}
+			inputCapacities[lockHash] = append(inputCapacities[lockHash], amount)
+		case builder.IsOutputOperation(operation):
+			amount, err := strconv.ParseUint(operation.Amount.Value, 10, 64)
+			if err != nil {
+				return InvalidOutputOperationAmountValueError
+			}
+			outputCapacities[lockHash] = append(outputCapacities[lockHash], amount)
+		}
+	}
+	for lockHash, inputs := range inputCapacities {
+		outputs := outputCapacities[lockHash]
+		if len(inputs) != 1 || len(outputs) != 1 {
+			return wrapErr(InvalidACPTopUpError, fmt.Errorf("lock %s has %d inputs and %d outputs, it must have one of each", lockHash.String(), len(inputs), len(outputs)))
+		}
+		if outputs[0] <= inputs[0] {
+			return wrapErr(InvalidACPTopUpError, fmt.Errorf("output of lock %s must have more capacity than its input", lockHash.String()))
+		}
+	}
+	return nil
+}
+
+// validateMultisigScript checks the multisig script in operation metadata matches the lock args
+func validateMultisigScript(operation *types.Operation, lock *ckbTypes.Script) *types.Error {
+	var metadata ckb.OperationMetadata
+	if err := types.UnmarshalMap(operation.Metadata, &metadata); err != nil {
+		return wrapErr(InvalidOperationMetadataError, err)
+	}
+	if metadata.MultisigScript == nil {
+		return wrapErr(InvalidMultisigScriptError, errors.New("missing multisig script in operation metadata"))
+	}
+	args, err := metadata.MultisigScript.Args()
 	if err != nil {
-		return "", InvalidConstructionMetadataError
+		return wrapErr(InvalidMultisigScriptError, err)
 	}
+	if len(lock.Args) < len(args) || !bytes.Equal(lock.Args[:len(args)], args) {
+		return wrapErr(InvalidMultisigScriptError, fmt.Errorf("multisig script does not match lock args of %s", operation.Account.Address))
+	}
+	if _, err := builder.GetMultisigInputSince(operation); err != nil {
+		return wrapErr(InvalidSinceError, err)
+	}
+	return nil
+}
 
-	return constructionMetadata.ConstructionType, nil
+// validateCellDeps checks the extra cell deps of the preprocess metadata, they are only used by GenericCell
+func validateCellDeps(cellDeps []*ckb.CellDep, constructionType string) *types.Error {
+	if len(cellDeps) > 0 && constructionType != ckb.GenericCell {
+		return wrapErr(InvalidPreprocessMetadataError, fmt.Errorf("%s does not accept cell deps", constructionType))
+	}
+	for _, cellDep := range cellDeps {
+		if _, err := builder.GenerateCellDep(cellDep); err != nil {
+			return wrapErr(InvalidPreprocessMetadataError, err)
+		}
+	}
+	return nil
 }
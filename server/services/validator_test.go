@@ -0,0 +1,256 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/nervosnetwork/ckb-rosetta-sdk/ckb"
+	"github.com/nervosnetwork/ckb-sdk-go/address"
+	ckbTransaction "github.com/nervosnetwork/ckb-sdk-go/transaction"
+	ckbTypes "github.com/nervosnetwork/ckb-sdk-go/types"
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidateMultisigScript(t *testing.T) {
+	multisigScript := &ckb.MultisigScript{Threshold: 1, PubKeyHashes: []string{hexutil.Encode(bytes.Repeat([]byte{1}, 20))}}
+	args, err := multisigScript.Args()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherArgs := append([]byte{}, args...)
+	otherArgs[0]++
+	lockSince := make([]byte, 8)
+	binary.LittleEndian.PutUint64(lockSince, 1000)
+	sinceArgs := append(append([]byte{}, args...), lockSince...)
+
+	tests := []struct {
+		name           string
+		args           []byte
+		multisigScript *ckb.MultisigScript
+		since          *hexutil.Uint64
+		wantErr        *types.Error
+	}{
+		{name: "plain args", args: args, multisigScript: multisigScript},
+		{name: "missing multisig script", args: args, wantErr: InvalidMultisigScriptError},
+		{name: "other args", args: otherArgs, multisigScript: multisigScript, wantErr: InvalidMultisigScriptError},
+		{name: "since args", args: sinceArgs, multisigScript: multisigScript},
+		{name: "since args with matching since", args: sinceArgs, multisigScript: multisigScript, since: testSince(1000)},
+		{name: "since args with other since", args: sinceArgs, multisigScript: multisigScript, since: testSince(1001), wantErr: InvalidSinceError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock := &ckbTypes.Script{
+				CodeHash: ckbTypes.HexToHash(ckbTransaction.SECP256K1_BLAKE160_MULTISIG_ALL_TYPE_HASH),
+				HashType: ckbTypes.HashTypeType,
+				Args:     tt.args,
+			}
+			operation := &types.Operation{
+				Type:     ckb.InputOpType,
+				Account:  &types.AccountIdentifier{Address: testAddress(t, lock)},
+				Metadata: testOperationMetadata(t, &ckb.OperationMetadata{MultisigScript: tt.multisigScript, Since: tt.since}),
+			}
+			assertError(t, validateMultisigScript(operation, lock), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateOccupiedCapacity(t *testing.T) {
+	cfg := testConfig(t)
+	addr := testAddress(t, &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(ckbTransaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{1}, 20),
+	})
+	output := func(metadata *ckb.OperationMetadata) *types.Operation {
+		return &types.Operation{
+			Type:     ckb.OutputOpType,
+			Account:  &types.AccountIdentifier{Address: addr},
+			Amount:   &types.Amount{Value: "10000000000", Currency: CkbCurrency},
+			Metadata: testOperationMetadata(t, metadata),
+		}
+	}
+	typeScript := func(hashType ckbTypes.ScriptHashType) *ckbTypes.Script {
+		return &ckbTypes.Script{HashType: hashType, Args: bytes.Repeat([]byte{2}, 20)}
+	}
+
+	tests := []struct {
+		name        string
+		operation   *types.Operation
+		capacity    uint64
+		wantErr     *types.Error
+		wantMinimum string
+	}{
+		{name: "plain cell", operation: output(&ckb.OperationMetadata{}), capacity: 6100000000},
+		{name: "plain cell below its occupied capacity", operation: output(&ckb.OperationMetadata{}), capacity: 6099999999, wantErr: LessThanMinCapacityError, wantMinimum: "6100000000"},
+		{name: "typed cell", operation: output(&ckb.OperationMetadata{Type: typeScript(ckbTypes.HashTypeData), Data: "0x01"}), capacity: 11500000000},
+		{name: "typed cell below its occupied capacity", operation: output(&ckb.OperationMetadata{Type: typeScript(ckbTypes.HashTypeType), Data: "0x01"}), capacity: 11400000000, wantErr: LessThanMinCapacityError, wantMinimum: "11500000000"},
+		{name: "invalid hash type", operation: output(&ckb.OperationMetadata{Type: typeScript("code")}), capacity: 11400000000, wantErr: InvalidOperationMetadataError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rErr := validateOccupiedCapacity(0, tt.operation, tt.capacity, cfg)
+			assertError(t, rErr, tt.wantErr)
+			if tt.wantMinimum != "" && rErr.Details["minimum_capacity"] != tt.wantMinimum {
+				t.Errorf("minimum capacity = %v, want %s", rErr.Details["minimum_capacity"], tt.wantMinimum)
+			}
+		})
+	}
+}
+
+func TestValidateUDTAmounts(t *testing.T) {
+	cfg := testConfig(t)
+	// the same symbol with other decimals is another token
+	if err := yaml.Unmarshal([]byte(`
+'0x01':
+  symbol: AAA
+  decimal: 8
+'0x02':
+  symbol: AAA
+  decimal: 6
+`), &cfg.UDT.Tokens); err != nil {
+		t.Fatal(err)
+	}
+	operation := func(opType string, value string, decimals int32) *types.Operation {
+		return &types.Operation{
+			Type:   opType,
+			Amount: &types.Amount{Value: value, Currency: &types.Currency{Symbol: "AAA", Decimals: decimals}},
+		}
+	}
+	ckbOutput := &types.Operation{
+		Type:   ckb.OutputOpType,
+		Amount: &types.Amount{Value: "10000000000", Currency: &types.Currency{Symbol: ckb.CkbSymbol, Decimals: 8}},
+	}
+
+	tests := []struct {
+		name       string
+		operations []*types.Operation
+		wantErr    *types.Error
+	}{
+		{
+			name:       "balanced",
+			operations: []*types.Operation{operation(ckb.InputOpType, "-100", 8), operation(ckb.OutputOpType, "60", 8), operation(ckb.OutputOpType, "40", 8), ckbOutput},
+		},
+		{
+			name:       "balanced per token",
+			operations: []*types.Operation{operation(ckb.InputOpType, "-100", 8), operation(ckb.InputOpType, "-5", 6), operation(ckb.OutputOpType, "5", 6), operation(ckb.OutputOpType, "100", 8)},
+		},
+		{
+			name:       "unbalanced",
+			operations: []*types.Operation{operation(ckb.InputOpType, "-100", 8), operation(ckb.OutputOpType, "99", 8)},
+			wantErr:    UDTAmountNotBalancedError,
+		},
+		{
+			name:       "amounts moved across tokens of the same symbol",
+			operations: []*types.Operation{operation(ckb.InputOpType, "-100", 8), operation(ckb.OutputOpType, "100", 6)},
+			wantErr:    UDTAmountNotBalancedError,
+		},
+		{
+			name:       "unknown token",
+			operations: []*types.Operation{operation(ckb.InputOpType, "-100", 2), operation(ckb.OutputOpType, "100", 2)},
+			wantErr:    UnsupportedCurrencyError,
+		},
+		{
+			name:       "invalid amount",
+			operations: []*types.Operation{operation(ckb.InputOpType, "-1.5", 8)},
+			wantErr:    DataParseError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, validateUDTAmounts(tt.operations, cfg), tt.wantErr)
+		})
+	}
+}
+
+func TestValidateACPTopUp(t *testing.T) {
+	cfg := testConfig(t)
+	acpLock := func(i byte) *ckbTypes.Script {
+		return &ckbTypes.Script{
+			CodeHash: ckbTypes.HexToHash(cfg.ACP.Script.CodeHash),
+			HashType: ckbTypes.ScriptHashType(cfg.ACP.Script.HashType),
+			Args:     bytes.Repeat([]byte{i}, 20),
+		}
+	}
+	acpA := testAddress(t, acpLock(1))
+	// the testnet address encodes the same lock as acpA
+	acpATestnet, err := address.Generate(address.Testnet, acpLock(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acpB := testAddress(t, acpLock(2))
+	sighash := testAddress(t, &ckbTypes.Script{
+		CodeHash: ckbTypes.HexToHash(ckbTransaction.SECP256K1_BLAKE160_SIGHASH_ALL_TYPE_HASH),
+		HashType: ckbTypes.HashTypeType,
+		Args:     bytes.Repeat([]byte{3}, 20),
+	})
+	input := func(addr string, value string) *types.Operation {
+		return &types.Operation{
+			Type:    ckb.InputOpType,
+			Account: &types.AccountIdentifier{Address: addr},
+			Amount:  &types.Amount{Value: "-" + value, Currency: CkbCurrency},
+		}
+	}
+	output := func(addr string, value string) *types.Operation {
+		return &types.Operation{
+			Type:    ckb.OutputOpType,
+			Account: &types.AccountIdentifier{Address: addr},
+			Amount:  &types.Amount{Value: value, Currency: CkbCurrency},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		operations []*types.Operation
+		wantErr    *types.Error
+	}{
+		{
+			name:       "top up",
+			operations: []*types.Operation{input(acpA, "10000000000"), input(sighash, "20000000000"), output(acpA, "15000000000")},
+		},
+		{
+			name:       "top up to another address of the same lock",
+			operations: []*types.Operation{input(acpA, "10000000000"), input(sighash, "20000000000"), output(acpATestnet, "15000000000")},
+		},
+		{
+			name:       "capacity not increased",
+			operations: []*types.Operation{input(acpA, "10000000000"), output(acpA, "10000000000")},
+			wantErr:    InvalidACPTopUpError,
+		},
+		{
+			name:       "capacity moved to another lock",
+			operations: []*types.Operation{input(acpA, "10000000000"), input(sighash, "20000000000"), output(acpB, "15000000000")},
+			wantErr:    InvalidACPTopUpError,
+		},
+		{
+			name:       "capacity increased across inputs",
+			operations: []*types.Operation{input(acpA, "10000000000"), input(acpA, "10000000000"), input(sighash, "20000000000"), output(acpATestnet, "25000000000")},
+			wantErr:    InvalidACPTopUpError,
+		},
+		{
+			name:       "capacity split across outputs",
+			operations: []*types.Operation{input(acpA, "10000000000"), input(sighash, "20000000000"), output(acpA, "11000000000"), output(acpATestnet, "11000000000")},
+			wantErr:    InvalidACPTopUpError,
+		},
+		{
+			name:       "two locks topped up",
+			operations: []*types.Operation{input(acpA, "10000000000"), input(acpB, "10000000000"), input(sighash, "20000000000"), output(acpA, "15000000000"), output(acpB, "15000000000")},
+		},
+		{
+			name:       "new anyone-can-pay cell",
+			operations: []*types.Operation{input(sighash, "20000000000"), output(acpB, "15000000000")},
+		},
+		{
+			name:       "invalid address",
+			operations: []*types.Operation{input("ckb1invalid", "10000000000")},
+			wantErr:    AddressParseError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, validateACPTopUp(tt.operations, cfg), tt.wantErr)
+		})
+	}
+}